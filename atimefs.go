@@ -0,0 +1,164 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// AtimeFS returns a file system which records the time returned by now as the
+// access time of the files of fsys when they are opened or read, like a file
+// system mounted with strictatime would. This allows testing code relying on
+// access times (e.g. evicting the least recently used entries of a cache)
+// without depending on the mount options of real file systems, which often
+// disable or delay the updates. The time.Now function is used if now is nil.
+//
+// Opening a file, reading it, or reading its content with ReadFile records its
+// access time, and listing a directory records the access time of the
+// directory. The recorded times are reported by the AccessTime method of the
+// file information returned by the file system, which the comparisons of this
+// package honor; files which were never accessed report the access time of
+// fsys. Other information, including the value returned by Sys, is left
+// unchanged.
+func AtimeFS(fsys fs.FS, now func() time.Time) fs.FS {
+	if now == nil {
+		now = time.Now
+	}
+	return &atimeFS{base: fsys, now: now, atimes: make(map[string]time.Time)}
+}
+
+type atimeFS struct {
+	base   fs.FS
+	now    func() time.Time
+	mutex  sync.Mutex
+	atimes map[string]time.Time
+}
+
+func (fsys *atimeFS) touch(name string) {
+	t := fsys.now()
+	fsys.mutex.Lock()
+	fsys.atimes[name] = t
+	fsys.mutex.Unlock()
+}
+
+func (fsys *atimeFS) info(name string, info fs.FileInfo) fs.FileInfo {
+	fsys.mutex.Lock()
+	atime, ok := fsys.atimes[name]
+	fsys.mutex.Unlock()
+	if !ok {
+		return info
+	}
+	return &atimeInfo{info, atime}
+}
+
+func (fsys *atimeFS) Open(name string) (fs.File, error) {
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fsys.touch(name)
+	return &atimeFile{f, name, fsys}, nil
+}
+
+func (fsys *atimeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(fsys.base, name)
+	if err != nil {
+		return nil, err
+	}
+	fsys.touch(name)
+	return fsys.entries(name, entries), nil
+}
+
+func (fsys *atimeFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys.base, name)
+	if err != nil {
+		return nil, err
+	}
+	fsys.touch(name)
+	return data, nil
+}
+
+func (fsys *atimeFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *atimeFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(fsys.base, name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.info(name, info), nil
+}
+
+func (fsys *atimeFS) entries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	// The entries are modified, they must not be shared with the base file
+	// system.
+	entries = append([]fs.DirEntry(nil), entries...)
+	for i, entry := range entries {
+		entries[i] = &atimeEntry{entry, path.Join(dir, entry.Name()), fsys}
+	}
+	return entries
+}
+
+var (
+	_ fs.ReadDirFS      = (*atimeFS)(nil)
+	_ fs.ReadFileFS     = (*atimeFS)(nil)
+	_ fs.StatFS         = (*atimeFS)(nil)
+	_ fslink.ReadLinkFS = (*atimeFS)(nil)
+)
+
+type atimeFile struct {
+	fs.File
+	name string
+	fsys *atimeFS
+}
+
+func (f *atimeFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.fsys.touch(f.name)
+	return n, err
+}
+
+func (f *atimeFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	entries, err := d.ReadDir(n)
+	f.fsys.touch(f.name)
+	return f.fsys.entries(f.name, entries), err
+}
+
+func (f *atimeFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return f.fsys.info(f.name, info), nil
+}
+
+type atimeEntry struct {
+	fs.DirEntry
+	name string
+	fsys *atimeFS
+}
+
+func (e *atimeEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.fsys.info(e.name, info), nil
+}
+
+type atimeInfo struct {
+	fs.FileInfo
+	atime time.Time
+}
+
+func (info *atimeInfo) AccessTime() time.Time { return info.atime }
+
+func (info *atimeInfo) ChangeTime() time.Time { return changeTime(info.FileInfo) }
@@ -0,0 +1,78 @@
+package fstest_test
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestAtimeFS(t *testing.T) {
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := t0
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	fsys := fstest.AtimeFS(fstest.MapFS{
+		"cache":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"cache/a": &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"cache/b": &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+	}, clock)
+
+	atime := func(name string) time.Time {
+		t.Helper()
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t, _ := info.(interface{ AccessTime() time.Time })
+		if t == nil {
+			return time.Time{}
+		}
+		return t.AccessTime()
+	}
+
+	if !atime("cache/a").IsZero() {
+		t.Error("expected no access time before the file is accessed")
+	}
+	f, err := fsys.Open("cache/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atime("cache/a"), t0.Add(1*time.Second); !got.Equal(want) {
+		t.Errorf("access time mismatch after open: want=%v got=%v", want, got)
+	}
+	if _, err := io.ReadAll(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := fs.ReadFile(fsys, "cache/b"); err != nil {
+		t.Fatal(err)
+	}
+	// The least recently used file is the one with the oldest access time.
+	if a, b := atime("cache/a"), atime("cache/b"); !a.Before(b) {
+		t.Errorf("expected cache/a to be accessed before cache/b: %v >= %v", a, b)
+	}
+
+	entries, err := fs.ReadDir(fsys, "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := entries[1].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.(interface{ AccessTime() time.Time }).AccessTime(), atime("cache/b"); !got.Equal(want) {
+		t.Errorf("access time of the entry mismatch: want=%v got=%v", want, got)
+	}
+	if atime("cache").IsZero() {
+		t.Error("expected listing the directory to record its access time")
+	}
+
+	if err := fstest.TestFS(fsys, "cache/a", "cache/b"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,135 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// Builder constructs MapFS values with a fluent API, which is less verbose than
+// map literals and creates the parent directories of the entries it adds:
+//
+//	fsys, err := new(fstest.Builder).
+//		Dir("etc").Mode(0700).
+//		File("etc/hosts", "127.0.0.1 localhost\n").
+//		Symlink("hosts", "etc/hosts").
+//		Build()
+//
+// Files are created with mode 0644, directories with mode 0755, and symbolic
+// links with mode 0777; the Mode and ModTime methods change the entry added by
+// the previous call. Parent directories which were not added explicitly are
+// created with mode 0755 and a zero modification time.
+//
+// The methods do not fail, errors such as invalid paths or conflicting entries
+// are accumulated and returned by Build. The zero value is an empty builder
+// ready to use.
+type Builder struct {
+	fsys MapFS
+	last string
+	errs []error
+	// Set when the previous call failed, which is not reported again by the
+	// calls modifying the entry.
+	failed bool
+}
+
+// File adds a regular file at name with the given content.
+func (b *Builder) File(name, content string) *Builder {
+	return b.FileBytes(name, []byte(content))
+}
+
+// FileBytes adds a regular file at name with the given content, which is
+// copied.
+func (b *Builder) FileBytes(name string, content []byte) *Builder {
+	return b.add("file", name, &MapFile{Mode: 0644, Data: append([]byte{}, content...)})
+}
+
+// Dir adds a directory at name. Adding a directory which was created as the
+// parent of previous entries is not an error, it allows changing its mode and
+// modification time with the next calls.
+func (b *Builder) Dir(name string) *Builder {
+	if file := b.fsys[name]; file != nil && file.Mode.IsDir() {
+		b.last, b.failed = name, false
+		return b
+	}
+	return b.add("dir", name, &MapFile{Mode: fs.ModeDir | 0755})
+}
+
+// Symlink adds a symbolic link at name pointing to target, which does not need
+// to exist.
+func (b *Builder) Symlink(name, target string) *Builder {
+	return b.add("symlink", name, &MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte(target)})
+}
+
+// Mode sets the permission bits of the entry added by the previous call, along
+// with the bits fs.ModeSetuid, fs.ModeSetgid, and fs.ModeSticky; its type is
+// not changed.
+func (b *Builder) Mode(perm fs.FileMode) *Builder {
+	if file := b.lastFile("mode"); file != nil {
+		file.Mode = file.Mode.Type() | perm&^fs.ModeType
+	}
+	return b
+}
+
+// ModTime sets the modification time of the entry added by the previous call.
+func (b *Builder) ModTime(t time.Time) *Builder {
+	if file := b.lastFile("modtime"); file != nil {
+		file.ModTime = t
+	}
+	return b
+}
+
+// Build returns the file system constructed by the builder, or an error
+// listing all the problems found by the previous calls. The builder can keep
+// being used after Build, the file system returned is a copy.
+func (b *Builder) Build() (MapFS, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+	if b.fsys == nil {
+		return MapFS{}, nil
+	}
+	return b.fsys.Clone(), nil
+}
+
+func (b *Builder) add(op, name string, file *MapFile) *Builder {
+	b.last, b.failed = "", false
+	if !fs.ValidPath(name) || name == "." {
+		return b.fail(op, name, errInvalidPath)
+	}
+	if b.fsys[name] != nil {
+		return b.fail(op, name, errDuplicate)
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if parent := b.fsys[dir]; parent != nil && !parent.Mode.IsDir() {
+			return b.fail(op, name, errParentNotDir)
+		}
+	}
+	if b.fsys == nil {
+		b.fsys = make(MapFS)
+	}
+	for dir := path.Dir(name); dir != "." && b.fsys[dir] == nil; dir = path.Dir(dir) {
+		b.fsys[dir] = &MapFile{Mode: fs.ModeDir | 0755}
+	}
+	b.fsys[name] = file
+	b.last = name
+	return b
+}
+
+func (b *Builder) lastFile(op string) *MapFile {
+	if b.last == "" {
+		if !b.failed {
+			b.fail(op, "", errNoEntry)
+		}
+		return nil
+	}
+	return b.fsys[b.last]
+}
+
+func (b *Builder) fail(op, name string, err error) *Builder {
+	b.errs = append(b.errs, &fs.PathError{Op: op, Path: name, Err: err})
+	b.failed = true
+	return b
+}
+
+var errNoEntry = errors.New("no entry to modify")
@@ -0,0 +1,94 @@
+package fstest_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestBuilder(t *testing.T) {
+	modTime := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := new(fstest.Builder).
+		File("a/b/file", "Hello World!").Mode(0600).ModTime(modTime).
+		Dir("a").Mode(0700).
+		Dir("empty").
+		FileBytes("data", []byte{1, 2, 3}).Mode(0755|fs.ModeSetuid).
+		Symlink("a/link", "b/file")
+
+	fsys, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fstest.MapFS{
+		"a":        &fstest.MapFile{Mode: 0700 | fs.ModeDir},
+		"a/b":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b/file": &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!"), ModTime: modTime},
+		"a/link":   &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("b/file")},
+		"data":     &fstest.MapFile{Mode: 0755 | fs.ModeSetuid, Data: []byte{1, 2, 3}},
+		"empty":    &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+	}
+	if err := fstest.EqualFSWith(want, fsys, fstest.WithModeMask(fs.ModePerm|fs.ModeSetuid)); err != nil {
+		t.Error(err)
+	}
+	if len(fsys) != len(want) {
+		t.Errorf("number of entries mismatch: want=%d got=%d", len(want), len(fsys))
+	}
+
+	// The file system returned by Build is a copy.
+	fsys["a/b/file"].Data = nil
+	if again, _ := b.Build(); string(again["a/b/file"].Data) != "Hello World!" {
+		t.Error("the file system returned by Build is shared with the builder")
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := new(fstest.Builder).
+		File("/abs", "").Mode(0600).
+		File("file", "").
+		File("file", "").
+		Dir("file/sub").
+		Symlink("../up", "target").
+		Build()
+
+	var paths []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			paths = append(paths, pathErr.Op+" "+pathErr.Path)
+		}
+	}
+	if got, want := fmt.Sprint(paths), "[file /abs file file dir file/sub symlink ../up]"; got != want {
+		t.Errorf("errors mismatch: want=%s got=%s", want, got)
+	}
+
+	if _, err := new(fstest.Builder).Mode(0600).Build(); err == nil {
+		t.Error("expected an error changing the mode without entries")
+	}
+	if fsys, err := new(fstest.Builder).Build(); err != nil || len(fsys) != 0 {
+		t.Errorf("unexpected result of the empty builder: %v (%v)", fsys, err)
+	}
+}
+
+func ExampleBuilder() {
+	// The tree of TestEqualFS, without writing the parent directories and
+	// modes of all the entries.
+	fsys, err := new(fstest.Builder).
+		File("dir/file", "Hello World!").
+		Symlink("dir/symlink", "../file").Mode(0666).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+
+	want := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/symlink": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../file")},
+	}
+	fmt.Println(fstest.EqualFS(want, fsys))
+	// Output: <nil>
+}
@@ -0,0 +1,160 @@
+package fstest
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// CacheFS returns a file system which caches the information, content,
+// directory entries, and symbolic link targets of the files of fsys on first
+// access, serving them from memory on subsequent accesses.
+//
+// The returned file system does not observe changes made to fsys after files
+// were cached. Use InvalidateCache to drop cached data.
+//
+// Errors are not cached. When the content of a file cannot be read, opening
+// the file is forwarded to fsys each time.
+func CacheFS(fsys fs.FS) fs.FS {
+	return &cacheFS{base: fsys}
+}
+
+// InvalidateCache drops the data cached for the given names from fsys, or all
+// cached data if no names are given. The function has no effect if fsys was
+// not returned by CacheFS.
+//
+// Only the data of the named files is invalidated; when files are created or
+// removed, the name of their parent directory must also be passed to refresh
+// its list of entries.
+func InvalidateCache(fsys fs.FS, names ...string) {
+	if c, ok := fsys.(*cacheFS); ok {
+		c.invalidate(names)
+	}
+}
+
+type cacheFS struct {
+	base    fs.FS
+	mutex   sync.Mutex
+	infos   map[string]fs.FileInfo
+	data    map[string][]byte
+	entries map[string][]fs.DirEntry
+	links   map[string]string
+}
+
+func (fsys *cacheFS) invalidate(names []string) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	if len(names) == 0 {
+		fsys.infos, fsys.data, fsys.entries, fsys.links = nil, nil, nil, nil
+		return
+	}
+	for _, name := range names {
+		delete(fsys.infos, name)
+		delete(fsys.data, name)
+		delete(fsys.entries, name)
+		delete(fsys.links, name)
+	}
+}
+
+// cached returns the value cached for name, loading it with load on the first
+// access to the name.
+func cached[T any](fsys *cacheFS, cache *map[string]T, name string, load func() (T, error)) (T, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	if value, ok := (*cache)[name]; ok {
+		return value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if *cache == nil {
+		*cache = make(map[string]T)
+	}
+	(*cache)[name] = value
+	return value, nil
+}
+
+func (fsys *cacheFS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		entries, err := fsys.ReadDir(name)
+		if err == nil {
+			return &mountDir{name: name, info: info, entries: entries}, nil
+		}
+	case info.Mode().IsRegular():
+		data, err := fsys.readFile(name)
+		if err == nil {
+			return &cacheFile{bytes.NewReader(data), info}, nil
+		}
+	}
+	return fsys.base.Open(name)
+}
+
+func (fsys *cacheFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := cached(fsys, &fsys.entries, name, func() ([]fs.DirEntry, error) {
+		return fs.ReadDir(fsys.base, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]fs.DirEntry{}, entries...), nil
+}
+
+func (fsys *cacheFS) ReadFile(name string) ([]byte, error) {
+	data, err := fsys.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (fsys *cacheFS) readFile(name string) ([]byte, error) {
+	// The content is read from the opened file rather than with fs.ReadFile
+	// so the cache mirrors the errors (e.g. permissions) seen by Open.
+	return cached(fsys, &fsys.data, name, func() ([]byte, error) {
+		f, err := fsys.base.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	})
+}
+
+func (fsys *cacheFS) ReadLink(name string) (string, error) {
+	return cached(fsys, &fsys.links, name, func() (string, error) {
+		return readLink(fsys.base, name)
+	})
+}
+
+func (fsys *cacheFS) Stat(name string) (fs.FileInfo, error) {
+	return cached(fsys, &fsys.infos, name, func() (fs.FileInfo, error) {
+		return fs.Stat(fsys.base, name)
+	})
+}
+
+var (
+	_ fs.ReadDirFS      = (*cacheFS)(nil)
+	_ fs.ReadFileFS     = (*cacheFS)(nil)
+	_ fs.StatFS         = (*cacheFS)(nil)
+	_ fslink.ReadLinkFS = (*cacheFS)(nil)
+)
+
+type cacheFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *cacheFile) Close() error { return nil }
+
+func (f *cacheFile) Stat() (fs.FileInfo, error) { return f.info, nil }
@@ -0,0 +1,60 @@
+package fstest_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCacheFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/a":    &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/b":    &fstest.MapFile{Mode: 0600, Data: []byte("B")},
+		"denied":   &fstest.MapFile{Mode: 0200, Data: []byte("secret")},
+		"dir/link": &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("a")},
+	}
+	cache := fstest.CacheFS(fsys)
+
+	if err := fstest.CheckCache(cache, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("file", []byte("Hello Cache!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("dir/c", []byte("C"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, cache, "file", "Hello World!")
+	if _, err := fs.Stat(cache, "dir/c"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := fs.ReadDir(cache, "dir"); len(entries) != 3 {
+		t.Errorf("expected the cached directory entries, got %d entries", len(entries))
+	}
+
+	fstest.InvalidateCache(cache, "file", "dir")
+	assertFile(t, cache, "file", "Hello Cache!")
+	if err := fstest.CheckCache(cache, fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsys.WriteFile("dir/a", []byte("AAA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fstest.InvalidateCache(cache)
+	assertFile(t, cache, "dir/a", "AAA")
+}
+
+func assertFile(t *testing.T, fsys fs.FS, name, want string) {
+	t.Helper()
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("%s: content mismatch: want=%q got=%q", name, want, b)
+	}
+}
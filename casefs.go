@@ -0,0 +1,170 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// CaseInsensitiveFS returns a file system wrapping fsys which resolves names
+// case-insensitively while preserving their case, like the default file
+// systems of macOS and Windows.
+//
+// Each component of the names passed to the file system is matched against
+// the entries of its parent directory in fsys: an entry with the exact name is
+// used if there is one, otherwise the first entry in lexical order whose name
+// is equal under Unicode simple case folding, as defined by strings.EqualFold.
+// Names are not normalized, so the composed and decomposed forms of accented
+// characters are different names. Directory listings and file information
+// report the names stored in fsys.
+//
+// The file systems returned by the Sub method of the returned file system are
+// case-insensitive as well. The returned file system implements WritableFS,
+// delegating to fsys if it implements WritableFS, or returning errors wrapping
+// fs.ErrPermission otherwise. Names differing only by case collide on write:
+// writing "foo" when "Foo" exists replaces the content of "Foo", and creating
+// the directory "foo" fails with an error wrapping fs.ErrExist.
+func CaseInsensitiveFS(fsys fs.FS) fs.FS {
+	return &caseFS{fsys}
+}
+
+type caseFS struct {
+	base fs.FS
+}
+
+// resolve returns the name of the file of the base file system matching name.
+// When a component of name has no match, the remaining components are kept
+// as-is so the base file system reports the error.
+func (fsys *caseFS) resolve(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return name, nil
+	}
+	dir, rest := ".", name
+	for rest != "" {
+		elem, next, _ := strings.Cut(rest, "/")
+		match, ok := fsys.lookup(dir, elem)
+		if !ok {
+			return path.Join(dir, rest), nil
+		}
+		dir, rest = path.Join(dir, match), next
+	}
+	return dir, nil
+}
+
+func (fsys *caseFS) lookup(dir, elem string) (string, bool) {
+	entries, err := fs.ReadDir(fsys.base, dir)
+	if err != nil {
+		return "", false
+	}
+	match, found := "", false
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == elem {
+			return name, true
+		}
+		if strings.EqualFold(name, elem) && (!found || name < match) {
+			match, found = name, true
+		}
+	}
+	return match, found
+}
+
+func (fsys *caseFS) Open(name string) (fs.File, error) {
+	resolved, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.base.Open(resolved)
+}
+
+func (fsys *caseFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, err := fsys.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(fsys.base, resolved)
+}
+
+func (fsys *caseFS) ReadFile(name string) ([]byte, error) {
+	resolved, err := fsys.resolve("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(fsys.base, resolved)
+}
+
+func (fsys *caseFS) ReadLink(name string) (string, error) {
+	resolved, err := fsys.resolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return readLink(fsys.base, resolved)
+}
+
+func (fsys *caseFS) Stat(name string) (fs.FileInfo, error) {
+	resolved, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys.base, resolved)
+}
+
+func (fsys *caseFS) Sub(dir string) (fs.FS, error) {
+	resolved, err := fsys.resolve("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := fslink.Sub(fsys.base, resolved)
+	if err != nil {
+		return nil, err
+	}
+	return &caseFS{sub}, nil
+}
+
+func (fsys *caseFS) writable(op, name string) (WritableFS, string, error) {
+	base, ok := fsys.base.(WritableFS)
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	resolved, err := fsys.resolve(op, name)
+	return base, resolved, err
+}
+
+func (fsys *caseFS) Chtimes(name string, atime, mtime time.Time) error {
+	base, resolved, err := fsys.writable("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return base.Chtimes(resolved, atime, mtime)
+}
+
+func (fsys *caseFS) Mkdir(name string, perm fs.FileMode) error {
+	base, resolved, err := fsys.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return base.Mkdir(resolved, perm)
+}
+
+func (fsys *caseFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	base, resolved, err := fsys.writable("write", name)
+	if err != nil {
+		return err
+	}
+	return base.WriteFile(resolved, data, perm)
+}
+
+var (
+	_ fs.ReadDirFS      = (*caseFS)(nil)
+	_ fs.ReadFileFS     = (*caseFS)(nil)
+	_ fs.StatFS         = (*caseFS)(nil)
+	_ fs.SubFS          = (*caseFS)(nil)
+	_ fslink.ReadLinkFS = (*caseFS)(nil)
+	_ WritableFS        = (*caseFS)(nil)
+)
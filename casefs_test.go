@@ -0,0 +1,82 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCaseInsensitiveFS(t *testing.T) {
+	base := fstest.MapFS{
+		"Docs":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"Docs/README.md": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"Docs/Link":      &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("README.md")},
+		"Straße":         &fstest.MapFile{Mode: 0644, Data: []byte("street")},
+	}
+	fsys := fstest.CaseInsensitiveFS(base)
+
+	if err := fstest.TestFS(fsys, "Docs/README.md", "Straße"); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFS(base, fsys); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"docs/readme.md", "DOCS/ReadMe.MD", "docs/README.md"} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if info.Name() != "README.md" {
+			t.Errorf("%s: stored name not preserved: %q", name, info.Name())
+		}
+	}
+	if data, err := fs.ReadFile(fsys, "STRAẞE"); err != nil || string(data) != "street" {
+		t.Errorf("unexpected content of the file: %q (%v)", data, err)
+	}
+	// Simple case folding does not map ß to ss.
+	if _, err := fs.Stat(fsys, "strasse"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if link, err := fslink.ReadLink(fsys, "docs/link"); err != nil || link != "README.md" {
+		t.Errorf("symbolic link mismatch: want=%q got=%q (%v)", "README.md", link, err)
+	}
+	if entries, err := fs.ReadDir(fsys, "DOCS"); err != nil || len(entries) != 2 || entries[1].Name() != "README.md" {
+		t.Errorf("unexpected directory entries: %v (%v)", entries, err)
+	}
+
+	w := fsys.(fstest.WritableFS)
+	if err := w.WriteFile("docs/Readme.md", []byte("Hello Case!"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data := base["Docs/README.md"].Data; string(data) != "Hello Case!" {
+		t.Errorf("the existing file was not written: %q", data)
+	}
+	if _, ok := base["docs/Readme.md"]; ok {
+		t.Error("a file colliding with an existing one was created")
+	}
+	if err := w.Mkdir("docs", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist, got %v", err)
+	}
+	if err := w.Mkdir("docs/New", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := base["Docs/New"]; !ok {
+		t.Error("the directory was not created in the existing parent")
+	}
+}
+
+func TestCaseInsensitiveFSSub(t *testing.T) {
+	sub, err := fs.Sub(fstest.CaseInsensitiveFS(linkFS{fstest.MapFS{
+		"Docs/README.md": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"Docs/Link":      &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("README.md")},
+	}}), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := fslink.ReadLink(sub, "LINK"); err != nil || link != "README.md" {
+		t.Errorf("sub readlink: want=%q got=%q (%v)", "README.md", link, err)
+	}
+}
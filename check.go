@@ -0,0 +1,299 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CheckSubConsistency verifies that the file system returned by calling Sub
+// on fsys is equal to the directory dir of fsys, returning an error describing
+// the first divergent path when they differ.
+//
+// The directory is accessed by prefixing the names with dir, which exercises
+// the path manipulations of the Sub implementation (including the handling of
+// the "." root) against a trivial reference.
+func CheckSubConsistency(fsys fs.SubFS, dir string) error {
+	sub, err := fsys.Sub(dir)
+	if err != nil {
+		return err
+	}
+	return EqualFS(&prefixFS{fsys, dir}, sub)
+}
+
+// TestSubFS is like TestFS but it also tests the file systems returned by
+// fs.Sub for each directory of fsys.
+//
+// The directories are discovered by walking fsys. For each of them, the
+// function runs TestFS on the sub file system, expecting all the files found
+// under the directory, and verifies that the sub file system is equal to the
+// directory accessed through fsys. When fsys implements fs.SubFS, it also
+// verifies that Sub returns errors for the files which are not directories,
+// and errors wrapping fs.ErrNotExist for names which do not exist.
+func TestSubFS(fsys fs.FS, expected ...string) error {
+	errs := []error{TestFS(fsys, expected...)}
+
+	var dirs, files []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, name)
+		} else {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, dir := range dirs {
+		if dir != "." {
+			errs = append(errs, testSub(fsys, dir, dirs, files))
+		}
+	}
+
+	if subFS, ok := fsys.(fs.SubFS); ok {
+		for _, name := range files {
+			if _, err := subFS.Sub(name); err == nil {
+				errs = append(errs, checkErrorf(name, "sub of a file which is not a directory did not fail"))
+			}
+		}
+		for _, name := range []string{"missing.fstest", "missing.fstest/dir"} {
+			if _, err := subFS.Sub(name); !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, checkErrorf(name, "sub of a missing directory: want fs.ErrNotExist got %v", err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func testSub(fsys fs.FS, dir string, dirs, files []string) error {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return checkErrorf(dir, "sub: %v", err)
+	}
+	var expected []string
+	prefix := dir + "/"
+	for _, names := range [][]string{dirs, files} {
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) {
+				expected = append(expected, strings.TrimPrefix(name, prefix))
+			}
+		}
+	}
+	if err := TestFS(sub, expected...); err != nil {
+		return fmt.Errorf("sub %s: %w", dir, err)
+	}
+	if err := EqualFS(&prefixFS{fsys, dir}, sub); err != nil {
+		return fmt.Errorf("sub %s: %w", dir, err)
+	}
+	return nil
+}
+
+type prefixFS struct {
+	base   fs.FS
+	prefix string
+}
+
+func (fsys *prefixFS) fullName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(fsys.prefix, name), nil
+}
+
+func (fsys *prefixFS) Open(name string) (fs.File, error) {
+	fullName, err := fsys.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.base.Open(fullName)
+}
+
+func (fsys *prefixFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fullName, err := fsys.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(fsys.base, fullName)
+}
+
+func (fsys *prefixFS) ReadLink(name string) (string, error) {
+	fullName, err := fsys.fullName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return readLink(fsys.base, fullName)
+}
+
+func (fsys *prefixFS) Stat(name string) (fs.FileInfo, error) {
+	fullName, err := fsys.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys.base, fullName)
+}
+
+// CheckReadDirPaging verifies that reading the directory dir of fsys in pages
+// of pageSize entries with fs.ReadDirFile yields the same entries as reading
+// the whole directory at once, without duplicates or omissions, and that the
+// end of the directory is reported with io.EOF.
+func CheckReadDirPaging(fsys fs.FS, dir string, pageSize int) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("invalid page size: %d", pageSize)
+	}
+
+	want, err := readDirAll(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	f, err := fsys.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not implemented")}
+	}
+
+	var got []fs.DirEntry
+	for {
+		page, err := d.ReadDir(pageSize)
+		if len(page) > pageSize {
+			return checkErrorf(dir, "page size mismatch: want<=%d got=%d", pageSize, len(page))
+		}
+		got = append(got, page...)
+		if err == io.EOF {
+			if len(page) != 0 {
+				return checkErrorf(dir, "io.EOF returned with %d entries", len(page))
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return checkErrorf(dir, "empty page returned without io.EOF")
+		}
+	}
+
+	if page, err := d.ReadDir(pageSize); len(page) != 0 || err != io.EOF {
+		return checkErrorf(dir, "reading past the end: want=(0, EOF) got=(%d, %v)", len(page), err)
+	}
+
+	sortEntries(got)
+	for i := 1; i < len(got); i++ {
+		if got[i-1].Name() == got[i].Name() {
+			return checkErrorf(dir, "duplicate directory entry: %q", got[i].Name())
+		}
+	}
+	if len(got) != len(want) {
+		return checkErrorf(dir, "number of directory entries mismatch: want=%d got=%d", len(want), len(got))
+	}
+	for i := range want {
+		if want[i].Name() != got[i].Name() || want[i].Type() != got[i].Type() {
+			return checkErrorf(dir, "directory entry mismatch: want=%q (%v) got=%q (%v)",
+				want[i].Name(), want[i].Type(), got[i].Name(), got[i].Type())
+		}
+	}
+	return nil
+}
+
+func readDirAll(fsys fs.FS, dir string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not implemented")}
+	}
+	entries, err := d.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func sortEntries(entries []fs.DirEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+}
+
+func checkErrorf(name, msg string, args ...any) error {
+	return &fs.PathError{Op: "check", Path: name, Err: fmt.Errorf(msg, args...)}
+}
+
+// CheckCache verifies that cache serves the same files as the file system fsys
+// it caches, returning an error describing the first difference found.
+//
+// The file systems are compared twice, so that the second comparison exercises
+// the data served from the cache populated by the first one.
+func CheckCache(cache, fsys fs.FS) error {
+	for i := 0; i < 2; i++ {
+		if err := EqualFS(fsys, cache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckModeConsistency verifies that the predicates describing the types of
+// the files of fsys agree with each other, returning an error describing the
+// first offending entry.
+//
+// For each file, the IsDir methods of the directory entry, the file information
+// and its mode must match the ModeDir type bit, IsRegular must match a zero
+// type, and the type of the directory entry must match the type of the mode
+// of its file information.
+func CheckModeConsistency(fsys fs.FS) error {
+	root, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return err
+	}
+	if err := checkModeInfo(".", root); err != nil {
+		return err
+	}
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		typ := entry.Type()
+		if entry.IsDir() != (typ == fs.ModeDir) {
+			return checkErrorf(name, "directory entry type mismatch: IsDir=%t Type=%v", entry.IsDir(), typ)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode(); typ != mode.Type() {
+			return checkErrorf(name, "directory entry and file information types mismatch: want=%v got=%v", typ, mode.Type())
+		}
+		return checkModeInfo(name, info)
+	})
+}
+
+func checkModeInfo(name string, info fs.FileInfo) error {
+	mode := info.Mode()
+	isDir := mode.Type() == fs.ModeDir
+	if info.IsDir() != isDir || mode.IsDir() != isDir {
+		return checkErrorf(name, "file information type mismatch: IsDir=%t Mode.IsDir=%t Mode=%v", info.IsDir(), mode.IsDir(), mode)
+	}
+	if mode.IsRegular() != (mode.Type() == 0) {
+		return checkErrorf(name, "file information type mismatch: IsRegular=%t Mode=%v", mode.IsRegular(), mode)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCheckSubConsistency(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":             &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":        &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/sub/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello Sub!")},
+		"dir/sub/symlink": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("file")},
+	}
+
+	for _, dir := range []string{".", "dir", "dir/sub"} {
+		if err := fstest.CheckSubConsistency(fsys, dir); err != nil {
+			t.Errorf("%s: %v", dir, err)
+		}
+	}
+
+	if err := fstest.CheckSubConsistency(brokenSubFS{fsys}, "dir/sub"); err == nil {
+		t.Error("expected an error checking a broken Sub implementation")
+	}
+}
+
+// brokenSubFS implements Sub by ignoring the directory.
+type brokenSubFS struct{ fstest.MapFS }
+
+func (fsys brokenSubFS) Sub(string) (fs.FS, error) { return fsys.MapFS, nil }
+
+func TestCheckReadDirPaging(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/a":       &fstest.MapFile{Mode: 0644},
+		"dir/b":       &fstest.MapFile{Mode: 0644},
+		"dir/c":       &fstest.MapFile{Mode: 0644},
+		"virtual/a":   &fstest.MapFile{Mode: 0644},
+		"virtual/b":   &fstest.MapFile{Mode: 0644},
+		"virtual/c/d": &fstest.MapFile{Mode: 0644},
+	}
+
+	for _, dir := range []string{".", "dir", "virtual"} {
+		for pageSize := 1; pageSize <= 4; pageSize++ {
+			if err := fstest.CheckReadDirPaging(fsys, dir, pageSize); err != nil {
+				t.Errorf("%s: page size %d: %v", dir, pageSize, err)
+			}
+		}
+	}
+
+	if err := fstest.CheckReadDirPaging(noPagingFS{fsys}, "dir", 2); err == nil {
+		t.Error("expected an error checking a directory ignoring the page size")
+	}
+}
+
+// noPagingFS returns directories which always return all their entries.
+type noPagingFS struct{ fstest.MapFS }
+
+func (fsys noPagingFS) Open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return noPagingDir{d}, nil
+	}
+	return f, nil
+}
+
+type noPagingDir struct{ fs.ReadDirFile }
+
+func (d noPagingDir) ReadDir(int) ([]fs.DirEntry, error) { return d.ReadDirFile.ReadDir(-1) }
+
+// inconsistentInfo reports a mode which disagrees with IsDir.
+type inconsistentInfo struct{ fs.FileInfo }
+
+func (inconsistentInfo) IsDir() bool { return true }
+
+func TestCheckModeConsistency(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/file": &fstest.MapFile{Mode: 0644},
+		"link":     &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("file")},
+	}
+	if err := fstest.CheckModeConsistency(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.SetInfo("dir/file", inconsistentInfo{info}); err != nil {
+		t.Fatal(err)
+	}
+	var pathErr *fs.PathError
+	if err := fstest.CheckModeConsistency(fsys); !errors.As(err, &pathErr) || pathErr.Path != "dir/file" {
+		t.Errorf("expected an error for dir/file, got %v", err)
+	}
+}
+
+func TestTestSubFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":             &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":        &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/sub":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/sub/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello Sub!")},
+		"dir/sub/symlink": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+		"empty":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+	}
+	if err := fstest.TestSubFS(fsys, "dir/file", "dir/sub/file"); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.TestSubFS(brokenSubFS{fsys}); err == nil {
+		t.Error("expected errors for the broken implementation of Sub")
+	}
+}
@@ -0,0 +1,118 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// concurrentIterations is the number of times that each goroutine of
+// ConcurrentTestFS reads the files and directories.
+const concurrentIterations = 10
+
+// ConcurrentTestFS tests that fsys is safe for concurrent use, by reading the
+// files from parallelism goroutines at the same time.
+//
+// The content of the files and of their parent directories is first read
+// serially to establish a baseline. The goroutines then repeatedly open, fully
+// read, and close each file, and read the directories, returning an error if
+// any of the reads fails or differs from the baseline.
+//
+// The function is best run with the race detector enabled, which surfaces the
+// data races of the implementation under test.
+func ConcurrentTestFS(fsys fs.FS, parallelism int, files ...string) error {
+	if parallelism <= 0 {
+		return fmt.Errorf("invalid parallelism: %d", parallelism)
+	}
+
+	data := make(map[string][]byte, len(files))
+	dirs := make(map[string][]string)
+	for _, name := range files {
+		b, err := readAll(fsys, name)
+		if err != nil {
+			return err
+		}
+		data[name] = b
+		dirs[path.Dir(name)] = nil
+	}
+	for dir := range dirs {
+		names, err := readDirNames(fsys, dir)
+		if err != nil {
+			return err
+		}
+		dirs[dir] = names
+	}
+
+	var (
+		group sync.WaitGroup
+		mutex sync.Mutex
+		errs  []error
+	)
+	for i := 0; i < parallelism; i++ {
+		group.Add(1)
+		go func(offset int) {
+			defer group.Done()
+			if err := concurrentReads(fsys, files, offset, data, dirs); err != nil {
+				mutex.Lock()
+				errs = append(errs, err)
+				mutex.Unlock()
+			}
+		}(i)
+	}
+	group.Wait()
+	return errors.Join(errs...)
+}
+
+// concurrentReads reads the files in a rotated order starting at offset, so
+// that the goroutines do not all access the same file at the same time.
+func concurrentReads(fsys fs.FS, files []string, offset int, data map[string][]byte, dirs map[string][]string) error {
+	for n := 0; n < concurrentIterations; n++ {
+		for i := range files {
+			name := files[(offset+i)%len(files)]
+			b, err := readAll(fsys, name)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(b, data[name]) {
+				return checkErrorf(name, "concurrent read differs from the baseline: want=%d bytes got=%d bytes", len(data[name]), len(b))
+			}
+		}
+		for dir, want := range dirs {
+			got, err := readDirNames(fsys, dir)
+			if err != nil {
+				return err
+			}
+			if !equalStrings(want, got) {
+				return checkErrorf(dir, "concurrent readdir mismatch: want=%q got=%q", want, got)
+			}
+		}
+	}
+	return nil
+}
+
+func readAll(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func readDirNames(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,46 @@
+package fstest_test
+
+import (
+	"fmt"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestConcurrentTestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/b": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/c": &fstest.MapFile{Mode: 0644},
+	}
+	if err := fstest.ConcurrentTestFS(fsys, 8, "a", "dir/b", "dir/c"); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.ConcurrentTestFS(&unstableFS{MapFS: fsys}, 8, "a", "dir/b"); err == nil {
+		t.Error("expected an error for a file system returning different content")
+	}
+	if err := fstest.ConcurrentTestFS(fsys, 8, "missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if err := fstest.ConcurrentTestFS(fsys, 0, "a"); err == nil {
+		t.Error("expected an error for an invalid parallelism")
+	}
+}
+
+// unstableFS returns files with a different content each time they are opened.
+type unstableFS struct {
+	fstest.MapFS
+	opens atomic.Int64
+}
+
+func (fsys *unstableFS) Open(name string) (fs.File, error) {
+	info, err := fs.Stat(fsys.MapFS, name)
+	if err != nil || info.IsDir() {
+		return fsys.MapFS.Open(name)
+	}
+	data := fmt.Sprintf("open #%d", fsys.opens.Add(1))
+	return fstest.MapFS{name: &fstest.MapFile{Data: []byte(data)}}.Open(name)
+}
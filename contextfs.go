@@ -0,0 +1,117 @@
+package fstest
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ContextFS is a file system wrapper whose operations accept a context, which
+// allows asserting that code threading a context.Context through file system
+// accesses respects cancellation.
+//
+// The methods check the context before delegating to the underlying file
+// system, and return errors wrapping the context error if it is done. The
+// files opened by OpenContext check the context on each call to Read and
+// ReadDir as well.
+//
+// ContextFS also implements fs.FS and the standard extension interfaces, its
+// Open, ReadDir, ReadFile, and Stat methods using context.Background(), so it
+// can be passed to code which is not aware of contexts.
+type ContextFS struct {
+	base fs.FS
+}
+
+// NewContextFS returns a ContextFS wrapping fsys.
+func NewContextFS(fsys fs.FS) *ContextFS {
+	return &ContextFS{base: fsys}
+}
+
+// OpenContext opens the named file, failing if ctx is done.
+func (fsys *ContextFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &contextFile{f, ctx, name}, nil
+}
+
+// ReadDirContext reads the named directory, failing if ctx is done.
+func (fsys *ContextFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return fs.ReadDir(fsys.base, name)
+}
+
+// ReadFileContext reads the named file, failing if ctx is done.
+func (fsys *ContextFS) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return fs.ReadFile(fsys.base, name)
+}
+
+// StatContext returns information about the named file, failing if ctx is
+// done.
+func (fsys *ContextFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return fs.Stat(fsys.base, name)
+}
+
+func (fsys *ContextFS) Open(name string) (fs.File, error) {
+	return fsys.OpenContext(context.Background(), name)
+}
+
+func (fsys *ContextFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fsys.ReadDirContext(context.Background(), name)
+}
+
+func (fsys *ContextFS) ReadFile(name string) ([]byte, error) {
+	return fsys.ReadFileContext(context.Background(), name)
+}
+
+func (fsys *ContextFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *ContextFS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.StatContext(context.Background(), name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*ContextFS)(nil)
+	_ fs.ReadFileFS     = (*ContextFS)(nil)
+	_ fs.StatFS         = (*ContextFS)(nil)
+	_ fslink.ReadLinkFS = (*ContextFS)(nil)
+)
+
+type contextFile struct {
+	fs.File
+	ctx  context.Context
+	name string
+}
+
+func (f *contextFile) Read(b []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return f.File.Read(b)
+}
+
+func (f *contextFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+	}
+	return d.ReadDir(n)
+}
@@ -0,0 +1,53 @@
+package fstest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestContextFS(t *testing.T) {
+	fsys := fstest.NewContextFS(fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	})
+	if err := fstest.TestFS(fsys, "dir/file"); err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := fsys.OpenContext(ctx, "dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, err := fsys.ReadFileContext(ctx, "dir/file"); err != nil || string(data) != "Hello World!" {
+		t.Errorf("unexpected content: %q (%v)", data, err)
+	}
+	cancel()
+
+	if _, err := fsys.OpenContext(ctx, "dir/file"); !errors.Is(err, context.Canceled) {
+		t.Errorf("open: expected context.Canceled, got %v", err)
+	}
+	if _, err := fsys.ReadDirContext(ctx, "dir"); !errors.Is(err, context.Canceled) {
+		t.Errorf("readdir: expected context.Canceled, got %v", err)
+	}
+	if _, err := fsys.ReadFileContext(ctx, "dir/file"); !errors.Is(err, context.Canceled) {
+		t.Errorf("read: expected context.Canceled, got %v", err)
+	}
+	if _, err := fsys.StatContext(ctx, "dir/file"); !errors.Is(err, context.Canceled) {
+		t.Errorf("stat: expected context.Canceled, got %v", err)
+	}
+	if _, err := io.ReadAll(f); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected reading the file opened with the context to fail, got %v", err)
+	}
+
+	// The methods of fs.FS are not affected by the cancellation.
+	if _, err := fsys.Stat("dir/file"); err != nil {
+		t.Error(err)
+	}
+}
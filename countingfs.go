@@ -0,0 +1,134 @@
+package fstest
+
+import (
+	"io/fs"
+	"sync/atomic"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// CountingFS is a file system wrapper counting the operations made on the file
+// system, which allows asserting that code does not perform unnecessary
+// operations (e.g. that walking a tree does not stat files more than once).
+//
+// The wrapper is transparent: operations are delegated to the underlying file
+// system and their results returned unchanged.
+//
+// CountingFS values are safe for concurrent use.
+type CountingFS struct {
+	base   fs.FS
+	counts struct {
+		open, read, readDir, readFile, stat, readLink atomic.Int64
+	}
+}
+
+// Counts is a snapshot of the counters of a CountingFS.
+type Counts struct {
+	// Number of calls to the Open method of the file system.
+	Open int64
+	// Number of calls to the Read method of the files opened.
+	Read int64
+	// Number of calls to the ReadDir method of the file system and of the
+	// directories opened.
+	ReadDir int64
+	// Number of calls to the ReadFile method of the file system.
+	ReadFile int64
+	// Number of calls to the Stat method of the file system and of the files
+	// opened.
+	Stat int64
+	// Number of calls to the ReadLink method of the file system.
+	ReadLink int64
+}
+
+// NewCountingFS returns a CountingFS wrapping fsys, with all counters zero.
+func NewCountingFS(fsys fs.FS) *CountingFS {
+	return &CountingFS{base: fsys}
+}
+
+// Counts returns the current values of the counters.
+func (fsys *CountingFS) Counts() Counts {
+	return Counts{
+		Open:     fsys.counts.open.Load(),
+		Read:     fsys.counts.read.Load(),
+		ReadDir:  fsys.counts.readDir.Load(),
+		ReadFile: fsys.counts.readFile.Load(),
+		Stat:     fsys.counts.stat.Load(),
+		ReadLink: fsys.counts.readLink.Load(),
+	}
+}
+
+// Reset sets all the counters to zero.
+func (fsys *CountingFS) Reset() {
+	fsys.counts.open.Store(0)
+	fsys.counts.read.Store(0)
+	fsys.counts.readDir.Store(0)
+	fsys.counts.readFile.Store(0)
+	fsys.counts.stat.Store(0)
+	fsys.counts.readLink.Store(0)
+}
+
+func (fsys *CountingFS) Open(name string) (fs.File, error) {
+	fsys.counts.open.Add(1)
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &countingDir{countingFile{d, fsys}, d}, nil
+	}
+	return &countingFile{f, fsys}, nil
+}
+
+func (fsys *CountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys.counts.readDir.Add(1)
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *CountingFS) ReadFile(name string) ([]byte, error) {
+	fsys.counts.readFile.Add(1)
+	return fs.ReadFile(fsys.base, name)
+}
+
+func (fsys *CountingFS) ReadLink(name string) (string, error) {
+	fsys.counts.readLink.Add(1)
+	return readLink(fsys.base, name)
+}
+
+func (fsys *CountingFS) Stat(name string) (fs.FileInfo, error) {
+	fsys.counts.stat.Add(1)
+	return fs.Stat(fsys.base, name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*CountingFS)(nil)
+	_ fs.ReadFileFS     = (*CountingFS)(nil)
+	_ fs.StatFS         = (*CountingFS)(nil)
+	_ fslink.ReadLinkFS = (*CountingFS)(nil)
+)
+
+type countingFile struct {
+	fs.File
+	fsys *CountingFS
+}
+
+func (f *countingFile) Read(b []byte) (int, error) {
+	f.fsys.counts.read.Add(1)
+	return f.File.Read(b)
+}
+
+func (f *countingFile) Stat() (fs.FileInfo, error) {
+	f.fsys.counts.stat.Add(1)
+	return f.File.Stat()
+}
+
+// countingDir is the type of directories opened by CountingFS, which are only
+// given a ReadDir method when the underlying directories have one.
+type countingDir struct {
+	countingFile
+	dir fs.ReadDirFile
+}
+
+func (d *countingDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.fsys.counts.readDir.Add(1)
+	return d.dir.ReadDir(n)
+}
@@ -0,0 +1,51 @@
+package fstest_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCountingFS(t *testing.T) {
+	base := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/b": &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"dir/c": &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+	}
+	fsys := fstest.NewCountingFS(base)
+
+	if err := fstest.TestFS(fsys, "a", "dir/b", "dir/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(base, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if counts := fsys.Counts(); counts.Open == 0 || counts.Read == 0 || counts.ReadDir == 0 {
+		t.Errorf("operations were not counted: %+v", counts)
+	}
+
+	fsys.Reset()
+	if counts := fsys.Counts(); counts != (fstest.Counts{}) {
+		t.Errorf("counters were not reset: %+v", counts)
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			_, err = fs.ReadFile(fsys, name)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := fstest.Counts{ReadDir: 2, ReadFile: 3, Stat: 1}
+	if counts := fsys.Counts(); counts != want {
+		t.Errorf("counters mismatch:\nwant: %+v\ngot:  %+v", want, counts)
+	}
+}
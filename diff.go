@@ -0,0 +1,73 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// Diff is a machine-readable description of a difference between two file
+// systems, as returned by DiffFS.
+type Diff struct {
+	// Path of the file which differs.
+	Path string
+	// Kind of difference.
+	Kind Kind
+	// Values which differ in the source and target file systems (e.g. the
+	// file modes when Kind is ModeChanged). The values are empty for the
+	// kinds of differences which do not compare values, such as Added or
+	// Removed.
+	Want string
+	Got  string
+	// Human-readable description of the difference, which is the message of
+	// the errors returned by EqualFS without the path.
+	Message string
+}
+
+func (d Diff) String() string { return d.Path + ": " + d.Message }
+
+// DiffFS compares two file systems and returns the list of differences found,
+// sorted by path. The list is empty if the file systems are equal.
+//
+// The differences are the ones that EqualFSAll would return, the function
+// accepts the same options. Errors which are not differences between the file
+// systems interrupt the comparison and are returned as-is.
+func DiffFS(a, b fs.FS, opts ...EqualOption) ([]Diff, error) {
+	diffs, err := diffFS(a, b, newEqualOptions(opts))
+	if diffs == nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// diffFS compares a and b like EqualFSAll, returning the differences found
+// along with the error returned by EqualFSAll. The differences are nil if the
+// comparison was interrupted by an error which is not a difference.
+func diffFS(a, b fs.FS, opts *equalOptions) ([]Diff, error) {
+	opts.errs = new([]error)
+	err := equalFS(a, b, nil, opts)
+	var errs EqualErrors
+	if err != nil && !errors.As(err, &errs) {
+		return nil, err
+	}
+	return newDiffs(errs), err
+}
+
+func newDiffs(errs EqualErrors) []Diff {
+	diffs := make([]Diff, len(errs))
+	for i, err := range errs {
+		diffs[i] = newDiff(err)
+	}
+	return diffs
+}
+
+func newDiff(err error) Diff {
+	diff := Diff{Path: errorPath(err), Message: err.Error()}
+	var equalErr *EqualError
+	if errors.As(err, &equalErr) {
+		diff.Kind = equalErr.Kind
+		diff.Want = equalErr.want
+		diff.Got = equalErr.got
+		diff.Message = equalErr.Error()
+	}
+	return diff
+}
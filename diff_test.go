@@ -0,0 +1,119 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestDiffFS(t *testing.T) {
+	a := fstest.MapFS{
+		"dir/mode":    &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/removed": &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"file":        &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":        &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+	}
+
+	b := fstest.MapFS{
+		"added":    &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+		"dir/mode": &fstest.MapFile{Mode: 0600, Data: []byte("A")},
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World?")},
+		"link":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dir")},
+	}
+
+	diffs, err := fstest.DiffFS(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fstest.Diff{
+		{
+			Path:    "added",
+			Kind:    fstest.Added,
+			Message: "file added: ----------",
+		},
+		{
+			Path:    "dir/mode",
+			Kind:    fstest.ModeChanged,
+			Want:    "-rw-r--r--",
+			Got:     "-rw-------",
+			Message: "file modes mismatch: want=-rw-r--r-- got=-rw-------",
+		},
+		{
+			Path:    "dir/removed",
+			Kind:    fstest.Removed,
+			Message: "file removed: ----------",
+		},
+		{
+			Path:    "file",
+			Kind:    fstest.ContentChanged,
+			Want:    "0x21",
+			Got:     "0x3f",
+			Message: "file content mismatch at offset 11: want=0x21 got=0x3f",
+		},
+		{
+			Path:    "link",
+			Kind:    fstest.SymlinkChanged,
+			Want:    `"file"`,
+			Got:     `"dir"`,
+			Message: `symbolic links mimatch: want="file" got="dir"`,
+		},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("differences mismatch:\nwant: %+v\ngot:  %+v", want, diffs)
+	}
+
+	diffs, err = fstest.DiffFS(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("unexpected differences: %+v", diffs)
+	}
+
+	boom := errors.New("boom")
+	if _, err := fstest.DiffFS(a, errorFS{boom}); !errors.Is(err, boom) {
+		t.Errorf("expected the error of the file system to be returned, got %v", err)
+	}
+}
+
+func TestDiffFSAnnotatedValues(t *testing.T) {
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!\n")},
+	}
+
+	// The values of differences are preserved when their messages are
+	// extended with more details.
+	tests := []struct {
+		opt       fstest.EqualOption
+		data      string
+		want, got string
+		detail    string
+	}{
+		{fstest.TextDiff(), "Hello Tests!\n", "0x57", "0x54", "+Hello Tests!"},
+		{fstest.DetectContentType(), "\x00ello World!\n", "0x48", "0x00", "content-type changed"},
+	}
+	for _, test := range tests {
+		b := fstest.MapFS{
+			"file": &fstest.MapFile{Mode: 0644, Data: []byte(test.data)},
+		}
+		diffs, err := fstest.DiffFS(a, b, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diffs) != 1 {
+			t.Fatalf("wrong number of differences: %+v", diffs)
+		}
+		diff := diffs[0]
+		if diff.Want != test.want || diff.Got != test.got {
+			t.Errorf("unexpected values: want=%q got=%q", diff.Want, diff.Got)
+		}
+		if !strings.Contains(diff.Message, test.detail) {
+			t.Errorf("message does not contain %q: %s", test.detail, diff.Message)
+		}
+	}
+}
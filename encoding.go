@@ -0,0 +1,160 @@
+package fstest
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Encoding describes a compressed encoding of file contents, recognized by
+// the magic bytes that the encoded data starts with.
+type Encoding struct {
+	// Name of the encoding, used in error messages (e.g. "gzip").
+	Name string
+	// Magic bytes identifying data with this encoding.
+	Magic []byte
+	// NewReader returns a reader decoding the data read from r.
+	NewReader func(r io.Reader) (io.Reader, error)
+}
+
+var defaultEncodings = []Encoding{
+	{
+		Name:  "gzip",
+		Magic: []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:  "bzip2",
+		Magic: []byte("BZh"),
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	},
+}
+
+// WithEquivalentEncodings configures the comparison to decompress the content
+// of files before comparing it, when they are compressed on either side. This
+// allows comparing file systems storing the same files with different
+// compressions, or compressed on one side only.
+//
+// The encodings are recognized by the magic bytes at the beginning of files.
+// The gzip and bzip2 encodings are always recognized; other encodings, such as
+// zstd or xz which are not supported by the standard library, can be added by
+// passing their description:
+//
+//	fstest.WithEquivalentEncodings(fstest.Encoding{
+//		Name:  "zstd",
+//		Magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+//		NewReader: func(r io.Reader) (io.Reader, error) {
+//			return zstd.NewReader(r)
+//		},
+//	})
+//
+// The content is decompressed as it is compared, without loading whole files
+// in memory. Since their sizes differ, the sizes of files are not compared when
+// either side is compressed. Failing to decompress a file is reported as a
+// difference.
+func WithEquivalentEncodings(encodings ...Encoding) EqualOption {
+	return func(opts *equalOptions) {
+		if opts.encodings == nil {
+			opts.encodings = append(opts.encodings, defaultEncodings...)
+		}
+		opts.encodings = append(opts.encodings, encodings...)
+	}
+}
+
+func (opts *equalOptions) maxMagicLength() (n int) {
+	for _, enc := range opts.encodings {
+		if len(enc.Magic) > n {
+			n = len(enc.Magic)
+		}
+	}
+	return n
+}
+
+func (opts *equalOptions) sniffEncoding(prefix []byte) *Encoding {
+	for i := range opts.encodings {
+		if enc := &opts.encodings[i]; bytes.HasPrefix(prefix, enc.Magic) {
+			return enc
+		}
+	}
+	return nil
+}
+
+// decode returns readers of the decompressed contents of the source and target
+// files, and whether any of the two was compressed.
+func (opts *equalOptions) decode(source, target io.Reader) (io.Reader, io.Reader, bool, error) {
+	sourceReader, sourceEncoding := opts.sniff(source)
+	targetReader, targetEncoding := opts.sniff(target)
+	if sourceEncoding == nil && targetEncoding == nil {
+		return sourceReader, targetReader, false, nil
+	}
+	sourceReader, err := decodeReader(sourceReader, sourceEncoding)
+	if err != nil {
+		return nil, nil, false, differencef(ErrorChanged, "%s decompression of the source file failed: %v", sourceEncoding.Name, err)
+	}
+	targetReader, err = decodeReader(targetReader, targetEncoding)
+	if err != nil {
+		return nil, nil, false, differencef(ErrorChanged, "%s decompression of the target file failed: %v", targetEncoding.Name, err)
+	}
+	return sourceReader, targetReader, true, nil
+}
+
+// sniff reads the beginning of r to detect its encoding, and returns a reader
+// which still produces the whole content of r.
+func (opts *equalOptions) sniff(r io.Reader) (io.Reader, *Encoding) {
+	prefix := make([]byte, opts.maxMagicLength())
+	n, err := readFull(r, prefix)
+	prefix = prefix[:n]
+	return &prefixReader{prefix, err, r}, opts.sniffEncoding(prefix)
+}
+
+func decodeReader(r io.Reader, enc *Encoding) (io.Reader, error) {
+	if enc == nil {
+		return r, nil
+	}
+	d, err := enc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &decodingReader{d, enc.Name}, nil
+}
+
+// prefixReader produces the bytes already read from a reader, followed by the
+// error that the read returned, or the remaining data of the reader.
+type prefixReader struct {
+	prefix []byte
+	err    error
+	r      io.Reader
+}
+
+func (r *prefixReader) Read(b []byte) (int, error) {
+	if len(r.prefix) != 0 {
+		n := copy(b, r.prefix)
+		r.prefix = r.prefix[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.r.Read(b)
+}
+
+// decodingReader annotates the errors of decoders so the failure to decompress
+// a file is not mistaken for a failure to read it.
+type decodingReader struct {
+	r    io.Reader
+	name string
+}
+
+func (r *decodingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%s decompression failed: %w", r.name, err)
+	}
+	return n, err
+}
@@ -0,0 +1,64 @@
+package fstest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithEquivalentEncodings(t *testing.T) {
+	const text = "Hello World! Hello World! Hello World!"
+
+	compressed := func(s string) []byte {
+		buf := new(bytes.Buffer)
+		w := gzip.NewWriter(buf)
+		io.WriteString(w, s)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	base64Encoding := fstest.Encoding{
+		Name:  "base64",
+		Magic: []byte("B64:"),
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
+				return nil, err
+			}
+			return base64.NewDecoder(base64.StdEncoding, r), nil
+		},
+	}
+
+	plain := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte(text)}}
+	gzipped := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: compressed(text)}}
+	encoded := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("B64:" + base64.StdEncoding.EncodeToString([]byte(text)))}}
+	changed := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: compressed(strings.ToUpper(text))}}
+	corrupted := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: compressed(text)[:20]}}
+
+	if err := fstest.EqualFS(plain, gzipped); err == nil {
+		t.Error("expected the files to differ without decompression")
+	}
+	if err := fstest.EqualFSWith(plain, gzipped, fstest.WithEquivalentEncodings()); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(gzipped, encoded, fstest.WithEquivalentEncodings(base64Encoding)); err != nil {
+		t.Error(err)
+	}
+
+	var equalErr *fstest.EqualError
+	err := fstest.EqualFSWith(plain, changed, fstest.WithEquivalentEncodings())
+	if !errors.As(err, &equalErr) || equalErr.Kind != fstest.ContentChanged {
+		t.Errorf("expected a content difference, got %v", err)
+	}
+	err = fstest.EqualFSWith(plain, corrupted, fstest.WithEquivalentEncodings())
+	if !errors.As(err, &equalErr) || equalErr.Kind != fstest.ErrorChanged {
+		t.Errorf("expected an error difference, got %v", err)
+	} else if !strings.Contains(err.Error(), "gzip decompression failed") {
+		t.Errorf("expected the error to mention the decompression failure, got %v", err)
+	}
+}
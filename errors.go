@@ -0,0 +1,190 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Kind represents the kinds of differences that EqualFS may find when
+// comparing file systems.
+type Kind int
+
+const (
+	// TypeChanged indicates that the types of files differ.
+	TypeChanged Kind = iota
+	// ModeChanged indicates that the permission bits of files differ.
+	ModeChanged
+	// SizeChanged indicates that the sizes of files differ.
+	SizeChanged
+	// TimeChanged indicates that the modification, access, or change times
+	// of files differ.
+	TimeChanged
+	// ContentChanged indicates that the content of files differ.
+	ContentChanged
+	// SymlinkChanged indicates that the targets of symbolic links differ.
+	SymlinkChanged
+	// EntriesChanged indicates that the entries of directories differ.
+	EntriesChanged
+	// ErrorChanged indicates that different errors were returned when
+	// accessing files.
+	ErrorChanged
+	// PermissionAsymmetry indicates that a file could be read on one side but
+	// access was denied on the other.
+	PermissionAsymmetry
+	// Added indicates that a file exists only in the target file system.
+	Added
+	// Removed indicates that a file exists only in the source file system.
+	Removed
+	// WhitespaceChanged indicates that the content of text files only differ
+	// in whitespace.
+	WhitespaceChanged
+	// HardlinkChanged indicates that files are hard links of different sets
+	// of files.
+	HardlinkChanged
+	// XattrsChanged indicates that the extended attributes of files differ.
+	XattrsChanged
+)
+
+// ErrUnsupported is returned by functions of this package when the file
+// system or the platform does not support the operation.
+var ErrUnsupported = errors.New("unsupported operation")
+
+// Sentinel errors matching the differences of each kind with errors.Is.
+var (
+	ErrTypeMismatch        = errors.New("type mismatch")
+	ErrModeMismatch        = errors.New("mode mismatch")
+	ErrSizeMismatch        = errors.New("size mismatch")
+	ErrTimeMismatch        = errors.New("time mismatch")
+	ErrContentMismatch     = errors.New("content mismatch")
+	ErrSymlinkMismatch     = errors.New("symlink mismatch")
+	ErrEntriesMismatch     = errors.New("entries mismatch")
+	ErrErrorMismatch       = errors.New("error mismatch")
+	ErrPermissionAsymmetry = errors.New("permission asymmetry")
+	ErrAdded               = errors.New("file added")
+	ErrRemoved             = errors.New("file removed")
+	ErrWhitespaceMismatch  = errors.New("whitespace mismatch")
+	ErrHardlinkMismatch    = errors.New("hardlink mismatch")
+	ErrXattrsMismatch      = errors.New("xattrs mismatch")
+)
+
+var kindErrors = [...]error{
+	TypeChanged:         ErrTypeMismatch,
+	ModeChanged:         ErrModeMismatch,
+	SizeChanged:         ErrSizeMismatch,
+	TimeChanged:         ErrTimeMismatch,
+	ContentChanged:      ErrContentMismatch,
+	SymlinkChanged:      ErrSymlinkMismatch,
+	EntriesChanged:      ErrEntriesMismatch,
+	ErrorChanged:        ErrErrorMismatch,
+	PermissionAsymmetry: ErrPermissionAsymmetry,
+	Added:               ErrAdded,
+	Removed:             ErrRemoved,
+	WhitespaceChanged:   ErrWhitespaceMismatch,
+	HardlinkChanged:     ErrHardlinkMismatch,
+	XattrsChanged:       ErrXattrsMismatch,
+}
+
+func (k Kind) String() string {
+	switch k {
+	case TypeChanged:
+		return "type changed"
+	case ModeChanged:
+		return "mode changed"
+	case SizeChanged:
+		return "size changed"
+	case TimeChanged:
+		return "time changed"
+	case ContentChanged:
+		return "content changed"
+	case SymlinkChanged:
+		return "symlink changed"
+	case EntriesChanged:
+		return "entries changed"
+	case ErrorChanged:
+		return "error changed"
+	case PermissionAsymmetry:
+		return "permission asymmetry"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case WhitespaceChanged:
+		return "whitespace changed"
+	case HardlinkChanged:
+		return "hardlink changed"
+	case XattrsChanged:
+		return "xattrs changed"
+	default:
+		return "unknown"
+	}
+}
+
+// EqualError is the error type describing differences found by EqualFS.
+//
+// Differences are reported as *fs.PathError values wrapping an *EqualError,
+// which can be retrieved with errors.As to determine the kind of difference.
+// The kind can also be tested with errors.Is and the sentinel error matching
+// it, for example:
+//
+//	if errors.Is(err, fstest.ErrContentMismatch) {
+//		...
+//	}
+type EqualError struct {
+	Kind Kind
+	Err  error
+	// Values which differ, formatted when the error is created by mismatchf
+	// and reported by DiffFS.
+	want, got string
+}
+
+func (e *EqualError) Error() string { return e.Err.Error() }
+
+func (e *EqualError) Unwrap() error { return e.Err }
+
+// annotate returns a copy of e with detail appended to its message, or e
+// itself if detail is empty.
+func (e *EqualError) annotate(detail string) *EqualError {
+	if detail == "" {
+		return e
+	}
+	return &EqualError{Kind: e.Kind, Err: fmt.Errorf("%w%s", e.Err, detail), want: e.want, got: e.got}
+}
+
+// Is returns true if target is the sentinel error of the kind of e.
+func (e *EqualError) Is(target error) bool {
+	return e.Kind >= 0 && int(e.Kind) < len(kindErrors) && kindErrors[e.Kind] == target
+}
+
+// EqualErrors is the error type returned by EqualFSAll, aggregating all the
+// differences found when comparing file systems, ordered by path.
+//
+// The type implements the Unwrap() []error method, which allows errors.Is and
+// errors.As to match any of the differences.
+type EqualErrors []error
+
+func (e EqualErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e EqualErrors) Unwrap() []error { return e }
+
+func sortErrors(errs []error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errorPath(errs[i]) < errorPath(errs[j])
+	})
+}
+
+func errorPath(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Path
+	}
+	return ""
+}
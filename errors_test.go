@@ -0,0 +1,160 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestEqualErrorKind(t *testing.T) {
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	tests := []struct {
+		scenario string
+		file     *fstest.MapFile
+		kind     fstest.Kind
+	}{
+		{
+			scenario: "content",
+			file:     &fstest.MapFile{Mode: 0644, Data: []byte("Hello Test!!")},
+			kind:     fstest.ContentChanged,
+		},
+		{
+			scenario: "mode",
+			file:     &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!")},
+			kind:     fstest.ModeChanged,
+		},
+		{
+			scenario: "size",
+			file:     &fstest.MapFile{Mode: 0644, Data: []byte("Hello")},
+			kind:     fstest.SizeChanged,
+		},
+		{
+			scenario: "permission",
+			file:     &fstest.MapFile{Mode: 0000, Data: []byte("Hello World!")},
+			kind:     fstest.PermissionAsymmetry,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			b := fstest.MapFS{"file": test.file}
+
+			var equalErr *fstest.EqualError
+			if err := fstest.EqualFS(a, b); !errors.As(err, &equalErr) {
+				t.Fatalf("expected an equal error, got %v", err)
+			}
+			if equalErr.Kind != test.kind {
+				t.Errorf("kind mismatch: want=%v got=%v", test.kind, equalErr.Kind)
+			}
+		})
+	}
+}
+
+func TestEqualFSAll(t *testing.T) {
+	a := fstest.MapFS{
+		"a":       &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"b":       &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"dir/c":   &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+		"dir/d":   &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+		"removed": &fstest.MapFile{Mode: 0644},
+	}
+	b := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"b":     &fstest.MapFile{Mode: 0600, Data: []byte("B")},
+		"dir/c": &fstest.MapFile{Mode: 0644, Data: []byte("X")},
+		"dir/d": &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+		"dir/e": &fstest.MapFile{Mode: 0644},
+	}
+
+	if err := fstest.EqualFSAll(a, a); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fstest.EqualFSAll(a, b)
+	for _, target := range []error{
+		fstest.ErrModeMismatch,
+		fstest.ErrContentMismatch,
+		fstest.ErrAdded,
+		fstest.ErrRemoved,
+	} {
+		if !errors.Is(err, target) {
+			t.Errorf("expected the error to match %v", target)
+		}
+	}
+	if errors.Is(err, fstest.ErrSizeMismatch) {
+		t.Errorf("unexpected size mismatch")
+	}
+
+	var equalErrs fstest.EqualErrors
+	if !errors.As(err, &equalErrs) {
+		t.Fatalf("expected an EqualErrors value, got %v", err)
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+
+	want := []struct {
+		path string
+		kind fstest.Kind
+	}{
+		{"b", fstest.ModeChanged},
+		{"dir/c", fstest.ContentChanged},
+		{"dir/e", fstest.Added},
+		{"removed", fstest.Removed},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("number of differences mismatch: want=%d got=%d\n%v", len(want), len(errs), err)
+	}
+	for i, err := range errs {
+		var pathErr *fs.PathError
+		var equalErr *fstest.EqualError
+		if !errors.As(err, &pathErr) || !errors.As(err, &equalErr) {
+			t.Fatalf("unexpected error type: %v", err)
+		}
+		if pathErr.Path != want[i].path || equalErr.Kind != want[i].kind {
+			t.Errorf("difference %d mismatch: want=%s (%v) got=%s (%v)", i, want[i].path, want[i].kind, pathErr.Path, equalErr.Kind)
+		}
+	}
+}
+
+func TestEqualFSAllOrder(t *testing.T) {
+	a := fstest.MapFS{
+		"a/x":   &fstest.MapFile{Mode: 0644, Data: []byte("1")},
+		"a-b":   &fstest.MapFile{Mode: 0644, Data: []byte("2")},
+		"a/y/z": &fstest.MapFile{Mode: 0644, Data: []byte("3")},
+		"b":     &fstest.MapFile{Mode: 0644, Data: []byte("4")},
+	}
+	b := fstest.MapFS{
+		"a/x":   &fstest.MapFile{Mode: 0644, Data: []byte("one")},
+		"a-b":   &fstest.MapFile{Mode: 0644, Data: []byte("two")},
+		"a/y/z": &fstest.MapFile{Mode: 0644, Data: []byte("three")},
+		"b":     &fstest.MapFile{Mode: 0644, Data: []byte("four")},
+	}
+
+	var equalErr *fstest.EqualError
+	if err := fstest.EqualFS(a, b); !errors.As(err, &equalErr) {
+		t.Fatalf("expected an equal error, got %v", err)
+	} else if _, ok := err.(fstest.EqualErrors); ok {
+		t.Fatal("expected EqualFS to stop at the first difference")
+	}
+
+	want := []string{"a-b", "a/x", "a/y/z", "b"}
+	for i := 0; i < 10; i++ {
+		var errs fstest.EqualErrors
+		if err := fstest.EqualFSAll(a, b); !errors.As(err, &errs) {
+			t.Fatalf("expected an EqualErrors value, got %v", err)
+		}
+		if len(errs) != len(want) {
+			t.Fatalf("number of differences mismatch: want=%d got=%d", len(want), len(errs))
+		}
+		for j, err := range errs {
+			var pathErr *fs.PathError
+			if !errors.As(err, &pathErr) || pathErr.Path != want[j] {
+				t.Errorf("difference %d mismatch: want=%s got=%v", j, want[j], err)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package fstest
+
+import (
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// FaultOp is the name of an operation of a file system which can be made to
+// fail by FaultFS.
+type FaultOp string
+
+const (
+	OpOpen     FaultOp = "open"
+	OpRead     FaultOp = "read"
+	OpReadDir  FaultOp = "readdir"
+	OpReadFile FaultOp = "readfile"
+	OpReadLink FaultOp = "readlink"
+	OpStat     FaultOp = "stat"
+)
+
+// FaultFS is a file system wrapper returning errors on demand, which allows
+// exercising the error handling of code using file systems.
+//
+// The faults are configured with rules matching operations and paths, and are
+// registered with a fluent API, for example:
+//
+//	fsys := fstest.NewFaultFS(base).
+//		Fail(fstest.OpOpen, "secret/*", fs.ErrPermission).
+//		FailNth(fstest.OpRead, "big.bin", 3, io.ErrUnexpectedEOF)
+//
+// The rules are consulted before delegating operations to the underlying file
+// system, in the order they were registered; the first rule matching an
+// operation determines its error. The errors are returned wrapped in an
+// *fs.PathError.
+//
+// The OpRead rules apply to the calls to Read on the files opened by the file
+// system, and the OpReadDir rules to both the ReadDir method of the file system
+// and of the directories it opened. ReadFile opens and reads the files, so the
+// OpOpen and OpRead rules apply to it as well as the OpReadFile rules.
+//
+// FaultFS values are safe for concurrent use.
+type FaultFS struct {
+	base  fs.FS
+	mutex sync.Mutex
+	rules []*faultRule
+}
+
+type faultRule struct {
+	op      FaultOp
+	pattern string
+	nth     int
+	calls   int
+	err     error
+}
+
+// NewFaultFS returns a FaultFS wrapping fsys, without any rules.
+func NewFaultFS(fsys fs.FS) *FaultFS {
+	return &FaultFS{base: fsys}
+}
+
+// Fail registers a rule making the op operations on the files matching the
+// pattern fail with err. The patterns use the syntax of path.Match, with the
+// addition of "**" which matches any number of directories (e.g. "**/*.go").
+func (fsys *FaultFS) Fail(op FaultOp, pattern string, err error) *FaultFS {
+	return fsys.FailNth(op, pattern, 0, err)
+}
+
+// FailNth is like Fail but only the n-th call matching the operation and the
+// pattern fails, counting from one; the other calls are delegated to the
+// underlying file system. When n is zero or negative, all the matching calls
+// fail.
+func (fsys *FaultFS) FailNth(op FaultOp, pattern string, n int, err error) *FaultFS {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	fsys.rules = append(fsys.rules, &faultRule{op: op, pattern: pattern, nth: n, err: err})
+	return fsys
+}
+
+// fault returns the error that the op operation on name must fail with, or
+// nil if it must be delegated to the underlying file system.
+func (fsys *FaultFS) fault(op FaultOp, name string) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	for _, rule := range fsys.rules {
+		if rule.op != op || !matchGlob(rule.pattern, name) {
+			continue
+		}
+		rule.calls++
+		if rule.nth <= 0 || rule.calls == rule.nth {
+			return &fs.PathError{Op: string(op), Path: name, Err: rule.err}
+		}
+	}
+	return nil
+}
+
+func (fsys *FaultFS) Open(name string) (fs.File, error) {
+	if err := fsys.fault(OpOpen, name); err != nil {
+		return nil, err
+	}
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{f, name, fsys}, nil
+}
+
+func (fsys *FaultFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := fsys.fault(OpReadDir, name); err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *FaultFS) ReadFile(name string) ([]byte, error) {
+	if err := fsys.fault(OpReadFile, name); err != nil {
+		return nil, err
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (fsys *FaultFS) ReadLink(name string) (string, error) {
+	if err := fsys.fault(OpReadLink, name); err != nil {
+		return "", err
+	}
+	return readLink(fsys.base, name)
+}
+
+func (fsys *FaultFS) Stat(name string) (fs.FileInfo, error) {
+	if err := fsys.fault(OpStat, name); err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys.base, name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*FaultFS)(nil)
+	_ fs.ReadFileFS     = (*FaultFS)(nil)
+	_ fs.StatFS         = (*FaultFS)(nil)
+	_ fslink.ReadLinkFS = (*FaultFS)(nil)
+)
+
+type faultFile struct {
+	fs.File
+	name string
+	fsys *FaultFS
+}
+
+func (f *faultFile) Read(b []byte) (int, error) {
+	if err := f.fsys.fault(OpRead, f.name); err != nil {
+		return 0, err
+	}
+	return f.File.Read(b)
+}
+
+func (f *faultFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.fsys.fault(OpReadDir, f.name); err != nil {
+		return nil, err
+	}
+	return d.ReadDir(n)
+}
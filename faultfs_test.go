@@ -0,0 +1,78 @@
+package fstest_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestFaultFS(t *testing.T) {
+	base := fstest.MapFS{
+		"secret/key":  &fstest.MapFile{Mode: 0600, Data: []byte("0xdeadbeef")},
+		"public/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"big.bin":     &fstest.MapFile{Mode: 0644, Data: make([]byte, 100)},
+		"link":        &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("big.bin")},
+	}
+
+	fsys := fstest.NewFaultFS(base).
+		Fail(fstest.OpOpen, "secret/*", fs.ErrPermission).
+		FailNth(fstest.OpRead, "big.bin", 3, io.ErrUnexpectedEOF).
+		Fail(fstest.OpReadDir, "public", fs.ErrClosed).
+		Fail(fstest.OpStat, "**/file", fs.ErrNotExist).
+		Fail(fstest.OpReadLink, "link", fs.ErrInvalid)
+
+	if _, err := fsys.Open("secret/key"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("open: expected fs.ErrPermission, got %v", err)
+	}
+	if _, err := fs.ReadFile(fsys, "secret/key"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("readfile: expected fs.ErrPermission, got %v", err)
+	}
+	if _, err := fs.ReadDir(fsys, "public"); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("readdir: expected fs.ErrClosed, got %v", err)
+	}
+	if _, err := fs.Stat(fsys, "public/file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat: expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.ReadLink("link"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("readlink: expected fs.ErrInvalid, got %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "public/file"); err != nil || string(data) != "Hello World!" {
+		t.Errorf("readfile: unexpected result: %q, %v", data, err)
+	}
+
+	f, err := fsys.Open("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 10)
+	for i := 1; i <= 5; i++ {
+		_, err := f.Read(buf)
+		switch {
+		case i == 3 && !errors.Is(err, io.ErrUnexpectedEOF):
+			t.Errorf("read %d: expected io.ErrUnexpectedEOF, got %v", i, err)
+		case i != 3 && err != nil:
+			t.Errorf("read %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestFaultFSEqualFS(t *testing.T) {
+	base := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	boom := errors.New("boom")
+
+	// The faults are reported from the first read of the content.
+	err := fstest.EqualFS(base, fstest.NewFaultFS(base).FailNth(fstest.OpRead, "file", 1, boom))
+	if !errors.Is(err, fstest.ErrErrorMismatch) {
+		t.Errorf("expected an error mismatch failing the first read, got %v", err)
+	}
+	err = fstest.EqualFS(base, fstest.NewFaultFS(base).FailNth(fstest.OpRead, "file", 1, fs.ErrPermission))
+	if !errors.Is(err, fstest.ErrPermissionAsymmetry) {
+		t.Errorf("expected a permission asymmetry failing the first read, got %v", err)
+	}
+}
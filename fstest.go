@@ -8,10 +8,17 @@ package fstest
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"io/fs"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"testing/fstest"
 	"time"
 
@@ -25,13 +32,54 @@ func TestFS(fsys fs.FS, expected ...string) error {
 
 type MapFile = fstest.MapFile
 
+// MapFS is a file system backed by a map, like fstest.MapFS, extended with
+// support for symbolic links, the MapFileSys entries, and methods modifying
+// the file system.
+//
+// The methods of MapFS are safe for concurrent use: the methods reading the
+// file system take a read lock while the methods modifying it take a write
+// lock, which is shared by all the MapFS values. Entries are never modified in
+// place by the methods, they are replaced, so files remain safe to read after
+// being opened. Accessing the map directly is not synchronized and must not
+// happen concurrently with calls to the methods.
+//
+// The methods enforce the owner permission bits of the entries, like a process
+// running as the owner of all the files would observe: accessing the entries
+// of a directory requires its execute permission, reading files and listing
+// directories require the read permission, and modifying files or the entries
+// of a directory require the write permission. The directories synthesized by
+// the map, which Stat reports with the permission bits 0555, grant all the
+// permissions, and so do the entries created for them by Chtimes. Errors wrap
+// fs.ErrPermission when the permissions are missing. The As method simulates
+// other users.
+//
+// The regular files opened by MapFS implement io.Seeker and io.ReaderAt over
+// the content of the entries, allowing to test random-access readers.
 type MapFS fstest.MapFS
 
+// mapfsMutex synchronizes the methods of MapFS. A single lock is used since
+// the map type of MapFS cannot carry one.
+var mapfsMutex sync.RWMutex
+
 func (fsys MapFS) Glob(pattern string) ([]string, error) {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
 	return fstest.MapFS(fsys).Glob(pattern)
 }
 
 func (fsys MapFS) Open(name string) (fs.File, error) {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return fsys.open(name, ownerAccess)
+}
+
+// open opens the named file, checking the permissions to search its parent
+// directories and to read it with access. The read lock must be held by the
+// caller.
+func (fsys MapFS) open(name string, access accessFunc) (fs.File, error) {
+	if err := fsys.search("open", name, access); err != nil {
+		return nil, err
+	}
 	f, err := fstest.MapFS(fsys).Open(name)
 	if err != nil {
 		return nil, err
@@ -41,45 +89,140 @@ func (fsys MapFS) Open(name string) (fs.File, error) {
 		f.Close()
 		return nil, err
 	}
-	if s.IsDir() && fsys[name] == nil { // virtual directory?
-		return virtualDirectory{f.(fs.ReadDirFile)}, nil
+	if d, ok := f.(fs.ReadDirFile); ok && s.IsDir() {
+		dir := &dirFile{ReadDirFile: d, fsys: fsys, name: name}
+		if fsys[name] == nil { // virtual directory?
+			return virtualDirectory{dir}, nil
+		}
+		dir.info = fsys.info(name)
+		f = dir
+	} else if sys := fsys.sys(name); sys != nil {
+		if sys.Info != nil {
+			f = &infoFile{f, sys.Info}
+		}
+		if len(sys.ReadScript) != 0 {
+			f = &scriptFile{f, sys}
+		}
 	}
-	if (s.Mode().Perm() & 0400) == 0 {
-		return denyReadPermission{f}, nil
+	if !access(name, s.Mode(), 4) {
+		if d, ok := f.(fs.ReadDirFile); ok && s.IsDir() {
+			return &denyReadDir{d, name}, nil
+		}
+		return &denyReadFile{f, name}, nil
 	}
 	return f, nil
 }
 
 func (fsys MapFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	return fstest.MapFS(fsys).ReadDir(name)
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return fsys.readDir(name, ownerAccess)
+}
+
+// readDir reads the directory at name, applying the permission checks of
+// access. The read lock must be held by the caller.
+func (fsys MapFS) readDir(name string, access accessFunc) ([]fs.DirEntry, error) {
+	if err := fsys.check("readdir", name, 4, access); err != nil {
+		return nil, err
+	}
+	entries, err := fstest.MapFS(fsys).ReadDir(name)
+	fsys.entries(name, entries)
+	return entries, err
+}
+
+// entries replaces the entries of the directory at name which have file
+// information installed by SetInfo. The read lock must be held by the caller.
+func (fsys MapFS) entries(name string, entries []fs.DirEntry) {
+	for i, entry := range entries {
+		if info := fsys.info(path.Join(name, entry.Name())); info != nil {
+			entries[i] = fs.FileInfoToDirEntry(info)
+		}
+	}
 }
 
 func (fsys MapFS) ReadFile(name string) ([]byte, error) {
+	mapfsMutex.RLock()
+	sys := fsys.sys(name)
+	mapfsMutex.RUnlock()
+	if sys != nil && len(sys.ReadScript) != 0 {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	if err := fsys.check("read", name, 4, ownerAccess); err != nil {
+		return nil, err
+	}
 	return fstest.MapFS(fsys).ReadFile(name)
 }
 
 func (fsys MapFS) Stat(name string) (fs.FileInfo, error) {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return fsys.stat(name, ownerAccess)
+}
+
+// stat returns the file information of name, applying the permission checks
+// of access. The read lock must be held by the caller.
+func (fsys MapFS) stat(name string, access accessFunc) (fs.FileInfo, error) {
+	if err := fsys.check("stat", name, 0, access); err != nil {
+		return nil, err
+	}
+	if info := fsys.info(name); info != nil {
+		return info, nil
+	}
 	return fstest.MapFS(fsys).Stat(name)
 }
 
+// Sub returns a view of the directory at name, which implements all the
+// methods of MapFS reading the file system.
+//
+// Unlike the generic implementation of fs.Sub, which accepts any valid name
+// and defers errors to the operations of the returned file system, the
+// function validates that the directory exists when it is called: it returns
+// an error wrapping fs.ErrNotExist if name does not exist, and an error if it
+// is not a directory, like opening a file under a regular file fails on Unix
+// systems.
 func (fsys MapFS) Sub(name string) (fs.FS, error) {
-	_, err := fs.Stat(fsys, name)
+	info, err := fs.Stat(fsys, name)
 	if err != nil {
-		return nil, err
+		return nil, &fs.PathError{Op: "sub", Path: name, Err: errors.Unwrap(err)}
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: name, Err: errNotDir}
 	}
 	return &subFS{fsys, name}, nil
 }
 
 func (fsys MapFS) ReadLink(name string) (string, error) {
 	if !fs.ValidPath(name) {
-		return "", &fs.PathError{"readlink", name, fs.ErrNotExist}
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return fsys.readLink(name, ownerAccess)
+}
+
+// readLink returns the target of the symbolic link at name, applying the
+// permission checks of access. The read lock must be held by the caller.
+func (fsys MapFS) readLink(name string, access accessFunc) (string, error) {
+	if err := fsys.check("readlink", name, 0, access); err != nil {
+		return "", err
 	}
 	file := fsys[name]
 	if file == nil {
-		return "", &fs.PathError{"readlink", name, fs.ErrNotExist}
+		// Directories synthesized by the map exist but are not links.
+		if _, err := fstest.MapFS(fsys).Stat(name); err == nil {
+			return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+		}
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
 	}
 	if (file.Mode & fs.ModeSymlink) == 0 {
-		return "", &fs.PathError{"readlink", name, fs.ErrInvalid}
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
 	}
 	return string(file.Data), nil
 }
@@ -90,9 +233,10 @@ type subFS struct {
 }
 
 func (f *subFS) fullName(name string) string {
-	if name == "." {
+	switch {
+	case name == ".":
 		name = f.name
-	} else {
+	case f.name != ".":
 		name = f.name + "/" + name
 	}
 	return name
@@ -102,63 +246,257 @@ func (f *subFS) Open(name string) (fs.File, error) {
 	return f.fsys.Open(f.fullName(name))
 }
 
+func (f *subFS) Glob(pattern string) ([]string, error) {
+	// The pattern is validated on its own since the prefix could hide errors,
+	// and the prefix is escaped since it is not a pattern.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if f.name == "." {
+		return f.fsys.Glob(pattern)
+	}
+	matches, err := f.fsys.Glob(escapeGlob(f.name) + "/" + pattern)
+	for i, match := range matches {
+		matches[i] = strings.TrimPrefix(match, f.name+"/")
+	}
+	return matches, err
+}
+
+func (f *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return f.fsys.ReadDir(f.fullName(name))
+}
+
+func (f *subFS) ReadFile(name string) ([]byte, error) {
+	return f.fsys.ReadFile(f.fullName(name))
+}
+
 func (f *subFS) ReadLink(name string) (string, error) {
 	return f.fsys.ReadLink(f.fullName(name))
 }
 
+func (f *subFS) Stat(name string) (fs.FileInfo, error) {
+	return f.fsys.Stat(f.fullName(name))
+}
+
+func (f *subFS) Sub(name string) (fs.FS, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "sub", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.fsys.Sub(f.fullName(name))
+}
+
+// escapeGlob escapes the characters of name which have a special meaning in
+// the patterns of path.Match.
+func escapeGlob(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
 var (
 	_ fslink.ReadLinkFS = (MapFS)(nil)
+	_ fs.GlobFS         = (*subFS)(nil)
+	_ fs.ReadDirFS      = (*subFS)(nil)
+	_ fs.ReadFileFS     = (*subFS)(nil)
+	_ fs.StatFS         = (*subFS)(nil)
+	_ fs.SubFS          = (*subFS)(nil)
+	_ fslink.ReadLinkFS = (*subFS)(nil)
 )
 
-type denyReadPermission struct{ fs.File }
+// denyReadFile and denyReadDir are the files opened without the permission to
+// read them. Only the content of regular files and the entries of directories
+// are denied; the other methods, such as Stat or the Read method of
+// directories, behave as they would on readable files.
+type denyReadFile struct {
+	fs.File
+	name string
+}
+
+func (f *denyReadFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *denyReadFile) ReadAt([]byte, int64) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *denyReadFile) Seek(offset int64, whence int) (int64, error) {
+	return seekFile(f.File, offset, whence)
+}
 
-func (denyReadPermission) Read([]byte) (int, error) { return 0, fs.ErrPermission }
+type denyReadDir struct {
+	fs.ReadDirFile
+	name string
+}
+
+func (d *denyReadDir) ReadDir(int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrPermission}
+}
 
-func (denyReadPermission) ReadDir(int) ([]fs.DirEntry, error) { return nil, fs.ErrPermission }
+// seekFile and readFileAt forward the calls to Seek and ReadAt to f, which is
+// a file wrapped to alter other methods. The regular files opened by MapFS
+// implement both interfaces, the wrappers must not hide them.
+func seekFile(f fs.File, offset int64, whence int) (int64, error) {
+	if s, ok := f.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: fileName(f), Err: fs.ErrInvalid}
+}
+
+func readFileAt(f fs.File, b []byte, offset int64) (int, error) {
+	if r, ok := f.(io.ReaderAt); ok {
+		return r.ReadAt(b, offset)
+	}
+	return 0, &fs.PathError{Op: "read", Path: fileName(f), Err: fs.ErrInvalid}
+}
+
+func fileName(f fs.File) string {
+	if info, err := f.Stat(); err == nil {
+		return info.Name()
+	}
+	return ""
+}
+
+// dirFile is a directory opened by MapFS. The standard library implements
+// reading the entries incrementally, the wrapper makes them consistent with
+// MapFS.ReadDir and the Stat method consistent with MapFS.Stat.
+type dirFile struct {
+	fs.ReadDirFile
+	fsys MapFS
+	name string
+	info fs.FileInfo
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	mapfsMutex.RLock()
+	d.fsys.entries(d.name, entries)
+	mapfsMutex.RUnlock()
+	return entries, err
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	if d.info != nil {
+		return d.info, nil
+	}
+	return d.ReadDirFile.Stat()
+}
 
 type virtualDirectory struct{ fs.ReadDirFile }
 
 func (d virtualDirectory) Stat() (fs.FileInfo, error) {
 	stat, err := d.ReadDirFile.Stat()
-	return virtualDirInfo{stat}, err
+	return virtualDirInfo{FileInfo: stat}, err
+}
+
+// virtualDirInfo is the information of the directories synthesized by the map
+// when they are opened, which have no permission bits and a zero modification
+// time unless configured with VirtualDirs.
+type virtualDirInfo struct {
+	fs.FileInfo
+	perm    fs.FileMode
+	modTime time.Time
 }
 
-type virtualDirInfo struct{ fs.FileInfo }
+func (info virtualDirInfo) Mode() fs.FileMode { return fs.ModeDir | info.perm }
 
-func (virtualDirInfo) Mode() fs.FileMode { return fs.ModeDir }
+func (info virtualDirInfo) ModTime() time.Time { return info.modTime }
 
 const equalFSMinSize = 1024
 const equalFSBufSize = 32768
 
 // EqualFS compares two file systems, returning nil if they are equal, or an
 // error describing their difference when they are not.
+//
+// The comparison stops at the first difference; use EqualFSAll to collect all
+// the differences between the file systems.
 func EqualFS(a, b fs.FS) error { return EqualFSBuffer(a, b, nil) }
 
 // EqualFSBuffer is like EqualFS but the function receives the buffer used to
 // read files as arguments.
 func EqualFSBuffer(a, b fs.FS, buf []byte) error {
+	return EqualFSContext(context.Background(), a, b, buf)
+}
+
+// EqualFSContext is like EqualFSBuffer but the comparison is interrupted when
+// ctx is canceled, in which case the function returns the context error.
+func EqualFSContext(ctx context.Context, a, b fs.FS, buf []byte) error {
+	options := newEqualOptions(nil)
+	options.ctx = ctx
+	return equalFS(a, b, buf, options)
+}
+
+// EqualFSAll is like EqualFSWith but the comparison does not stop at the first
+// difference. All the differences found are returned as an EqualErrors value
+// ordered by path, each of them being an *fs.PathError wrapping an *EqualError
+// as returned by EqualFS.
+//
+// Entries which exist in only one of the directories are reported with the
+// kinds Added and Removed. Errors which are not differences between the file
+// systems (e.g. failing to read a directory on both sides) still interrupt the
+// comparison and are returned as-is.
+func EqualFSAll(a, b fs.FS, opts ...EqualOption) error {
+	options := newEqualOptions(opts)
+	options.errs = new([]error)
+	return equalFS(a, b, nil, options)
+}
+
+func equalFS(a, b fs.FS, buf []byte, opts *equalOptions) error {
 	if len(buf) < equalFSMinSize {
 		buf = make([]byte, equalFSBufSize)
 	}
-	return equalDir(a, b, ".", buf)
+	a, b = opts.wrap(a), opts.wrap(b)
+	start := time.Now()
+	if opts.workers > 1 && opts.errs == nil && !opts.mirror && opts.report == nil {
+		opts.parallel = newParallel(opts.workers, len(buf))
+	}
+	if opts.detectHardlinks {
+		opts.hardlinks = new(hardlinks)
+	}
+	err := equalDir(a, b, ".", buf, opts.forPath("."))
+	if opts.parallel != nil {
+		err = opts.parallel.wait(err)
+	}
+	if err == nil && opts.hardlinks != nil {
+		err = opts.hardlinks.check(opts.record)
+	}
+	if err := opts.record(err); err != nil {
+		opts.observeError(err)
+		return err
+	}
+	opts.observe(".", fs.ModeDir, start)
+	if opts.errs != nil && len(*opts.errs) != 0 {
+		errs := *opts.errs
+		sortErrors(errs)
+		return EqualErrors(errs)
+	}
+	return nil
 }
 
 func equalSymlink(source, target fs.FS, name string) error {
-	sourceLink, err := fslink.ReadLink(source, name)
+	sourceLink, err := readLink(source, name)
 	if err != nil {
 		return err
 	}
-	targetLink, err := fslink.ReadLink(target, name)
+	targetLink, err := readLink(target, name)
 	if err != nil {
 		return err
 	}
 	if sourceLink != targetLink {
-		return equalErrorf(name, "symbolic links mimatch: want=%q got=%q", sourceLink, targetLink)
+		return equalError(name, mismatchf(SymlinkChanged, "%q", sourceLink, targetLink, "symbolic links mimatch"))
 	}
 	return nil
 }
 
-func equalDir(source, target fs.FS, name string, buf []byte) error {
+func equalDir(source, target fs.FS, name string, buf []byte, opts *equalOptions) error {
+	if err := opts.ctx.Err(); err != nil {
+		return err
+	}
 	sourceEntries, err := fs.ReadDir(source, name)
 	if err != nil {
 		return err
@@ -167,47 +505,171 @@ func equalDir(source, target fs.FS, name string, buf []byte) error {
 	if err != nil {
 		return err
 	}
-	if len(sourceEntries) != len(targetEntries) {
-		return equalErrorf(name, "number of directory entries mismatch: want=%d got=%d", len(sourceEntries), len(targetEntries))
+	// fs.ReadDir returns the entries of fs.ReadDirFS implementations as-is,
+	// which may not sort them, so the entries are sorted before being paired.
+	sourceEntries = sortedEntries(opts.filter(name, sourceEntries))
+	targetEntries = sortedEntries(opts.filter(name, targetEntries))
+	if opts.errs != nil || opts.mirror {
+		return equalEntries(source, target, name, sourceEntries, targetEntries, buf, opts)
+	}
+	if msg := entriesMismatch(sourceEntries, targetEntries); msg != "" {
+		return equalErrorf(name, EntriesChanged, "directory entries mismatch: %s", msg)
 	}
 	for i := range sourceEntries {
-		sourceEntry := sourceEntries[i]
-		targetEntry := targetEntries[i]
-
-		sourceName := sourceEntry.Name()
-		targetName := targetEntry.Name()
-		if sourceName != targetName {
-			return equalErrorf(name, "name of directory entry %d mismatch: want=%q got=%q", i, sourceName, targetName)
-		}
-
-		sourceType := sourceEntry.Type()
-		targetType := targetEntry.Type()
-		if sourceType != targetType {
-			return equalErrorf(name, "name of directory entry %q mismatch: want=%v got=%v", sourceName, sourceType, targetType)
+		if err := equalEntry(source, target, name, sourceEntries[i], targetEntries[i], buf, opts); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		var filePath = path.Join(name, sourceName)
+// equalEntries pairs the entries of directories by name so the comparison can
+// carry on past entries existing in only one of them. The entries must be
+// sorted by name.
+func equalEntries(source, target fs.FS, name string, sourceEntries, targetEntries []fs.DirEntry, buf []byte, opts *equalOptions) error {
+	for i, j := 0, 0; i < len(sourceEntries) || j < len(targetEntries); {
 		var err error
-		switch sourceType {
-		case fs.ModeSymlink:
-			err = equalSymlink(source, target, filePath)
-		case fs.ModeDir:
-			err = equalDir(source, target, filePath, buf)
-		case 0: // regular
-			err = equalFile(source, target, filePath, buf)
+		switch {
+		case j == len(targetEntries) || (i < len(sourceEntries) && sourceEntries[i].Name() < targetEntries[j].Name()):
+			entry := sourceEntries[i]
+			err = equalErrorf(path.Join(name, entry.Name()), Removed, "file removed: %v", entry.Type())
+			i++
+		case i == len(sourceEntries) || targetEntries[j].Name() < sourceEntries[i].Name():
+			entry := targetEntries[j]
+			err = equalErrorf(path.Join(name, entry.Name()), Added, "file added: %v", entry.Type())
+			if opts.mirror {
+				opts.warn(err)
+				err = nil
+			}
+			j++
 		default:
-			err = equalNode(source, target, filePath)
+			err = equalEntry(source, target, name, sourceEntries[i], targetEntries[j], buf, opts)
+			i++
+			j++
 		}
-		if err != nil {
+		if err := opts.record(err); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func equalFile(source, target fs.FS, name string, buf []byte) error {
-	if err := equalStat(source, target, name); err != nil {
-		return equalErrorf(name, "%w", err)
+// entriesMismatch pairs the entries of directories by name, returning a message
+// listing the names of the entries missing in the target directory and of the
+// extra entries it contains, or an empty string if the names are the same. The
+// entries must be sorted by name.
+func entriesMismatch(sourceEntries, targetEntries []fs.DirEntry) string {
+	var missing, extra []string
+	for i, j := 0, 0; i < len(sourceEntries) || j < len(targetEntries); {
+		switch {
+		case j == len(targetEntries) || (i < len(sourceEntries) && sourceEntries[i].Name() < targetEntries[j].Name()):
+			missing = append(missing, sourceEntries[i].Name())
+			i++
+		case i == len(sourceEntries) || targetEntries[j].Name() < sourceEntries[i].Name():
+			extra = append(extra, targetEntries[j].Name())
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	var msgs []string
+	if len(missing) != 0 {
+		msgs = append(msgs, fmt.Sprintf("missing in target: %v", missing))
+	}
+	if len(extra) != 0 {
+		msgs = append(msgs, fmt.Sprintf("extra in target: %v", extra))
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// sortedEntries returns entries sorted by name. The slice is copied if it needs
+// to be sorted since it may be owned by the file system.
+func sortedEntries(entries []fs.DirEntry) []fs.DirEntry {
+	isSorted := sort.SliceIsSorted(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	if !isSorted {
+		entries = append([]fs.DirEntry(nil), entries...)
+		sortEntries(entries)
+	}
+	return entries
+}
+
+func equalEntry(source, target fs.FS, dir string, sourceEntry, targetEntry fs.DirEntry, buf []byte, opts *equalOptions) error {
+	sourceName := sourceEntry.Name()
+	sourceType := sourceEntry.Type()
+	targetType := targetEntry.Type()
+	var filePath = path.Join(dir, sourceName)
+	if sourceType != targetType {
+		if opts.symlinkAsTarget && isSymlinkAndRegular(sourceType, targetType) {
+			if ok, err := equalSymlinkAsTarget(source, target, filePath, sourceType, buf, opts.forPath(filePath)); ok {
+				return err
+			}
+		}
+		return equalError(dir, mismatchf(TypeChanged, "%v", sourceType, targetType, "name of directory entry %q mismatch", sourceName))
+	}
+
+	var start = time.Now()
+	opts = opts.forPath(filePath)
+	if opts.parallel != nil && opts.parallel.stopped(filePath) {
+		return errStopped
+	}
+	if opts.compareXattrs {
+		if err := opts.record(equalXattrs(source, target, filePath)); err != nil {
+			return err
+		}
+	}
+	var err error
+	switch sourceType {
+	case fs.ModeSymlink:
+		err = equalSymlink(source, target, filePath)
+	case fs.ModeDir:
+		err = equalSubdir(source, target, filePath, buf, opts)
+	case 0: // regular
+		err = equalFile(source, target, filePath, buf, opts)
+		if err == nil && opts.hardlinks != nil {
+			err = opts.hardlinks.add(filePath, sourceEntry, targetEntry)
+		}
+	default:
+		err = equalNode(source, target, filePath, opts)
+	}
+	opts.observeFile()
+	opts.observe(filePath, sourceType, start)
+	return err
+}
+
+func isSymlinkAndRegular(a, b fs.FileMode) bool {
+	return (a == fs.ModeSymlink && b == 0) || (a == 0 && b == fs.ModeSymlink)
+}
+
+// equalSubdir compares the directory at name, which is either traversed in the
+// current goroutine, traversed by a worker of a parallel comparison, or not
+// traversed when it is beyond the maximum depth.
+func equalSubdir(source, target fs.FS, name string, buf []byte, opts *equalOptions) error {
+	if opts.maxDepth >= 0 && depth(name) > opts.maxDepth {
+		return equalNode(source, target, name, opts)
+	}
+	if opts.parallel != nil && opts.parallel.spawn(name, func(buf []byte) error {
+		return equalDir(source, target, name, buf, opts)
+	}) {
+		return nil
+	}
+	return equalDir(source, target, name, buf, opts)
+}
+
+// depth returns the number of directories between the root and the file at
+// name, including the file itself.
+func depth(name string) int {
+	if name == "." {
+		return 0
+	}
+	return strings.Count(name, "/") + 1
+}
+
+func equalFile(source, target fs.FS, name string, buf []byte, opts *equalOptions) error {
+	if opts.structureOnly {
+		return equalNode(source, target, name, opts)
 	}
 	sourceFile, err1 := source.Open(name)
 	if err1 == nil {
@@ -217,110 +679,415 @@ func equalFile(source, target fs.FS, name string, buf []byte) error {
 	if err2 == nil {
 		defer targetFile.Close()
 	}
+	// Permission errors may be reported when opening or when reading the
+	// files; the ones reported by reads are detected when comparing the
+	// content.
+	if permissionAsymmetry(err1, err2) {
+		return equalPermission(source, target, name)
+	}
 	if err1 != nil || err2 != nil {
 		if !errors.Is(err1, unwrap(err2)) {
-			return equalErrorf(name, "file open error mismatch: want=%v got=%v", err1, err2)
+			return equalError(name, mismatchf(ErrorChanged, "%v", err1, err2, "file open error mismatch"))
+		}
+	}
+	var sourceData, targetData io.Reader = sourceFile, targetFile
+	var decoded, normalized bool
+	if err1 == nil {
+		var err error
+		sourceData, targetData, decoded, normalized, err = opts.contents(sourceFile, targetFile, true)
+		if err != nil {
+			return equalError(name, err)
+		}
+	}
+	info, err := equalStat(source, target, name, !decoded && !normalized, opts)
+	if err != nil {
+		// A file which cannot be read on one side has a different mode,
+		// reading the content tells whether the change denies the access.
+		if err1 == nil && errors.Is(err, ErrModeMismatch) && deniedRead(sourceData, targetData, buf) {
+			return equalPermission(source, target, name)
+		}
+		return opts.textDiffError(source, target, name, equalError(name, err))
+	}
+	if err1 != nil || opts.skipContent(info) {
+		return nil
+	}
+	if opts.sizeRounding == 0 && (opts.hash != nil || isWriterTo(sourceData, targetData)) {
+		err := equalDigest(source, target, name, sourceData, targetData, buf, opts)
+		if errors.Is(err, ErrPermissionAsymmetry) {
+			return equalPermission(source, target, name)
+		}
+		return opts.textDiffError(source, target, name, err)
+	}
+	if err := equalData(sourceData, targetData, buf, opts); err != nil {
+		if errors.Is(err, ErrPermissionAsymmetry) {
+			return equalPermission(source, target, name)
 		}
+		return opts.textDiffError(source, target, name, equalError(name, err))
 	}
-	if err := equalData(sourceFile, targetFile, buf); err != nil {
-		return equalErrorf(name, "%w", err)
+	if normalized && opts.reportWhitespace {
+		return equalWhitespace(source, target, name, buf, opts)
 	}
 	return nil
 }
 
-func equalNode(source, target fs.FS, name string) error {
-	if err := equalStat(source, target, name); err != nil {
-		return equalErrorf(name, "%w", err)
+// contents returns readers of the contents of the source and target files to
+// compare, decompressed and with whitespace normalized when the options ask
+// for it, and whether either transformation was applied.
+func (opts *equalOptions) contents(sourceFile, targetFile io.Reader, whitespace bool) (sourceData, targetData io.Reader, decoded, normalized bool, err error) {
+	sourceData, targetData = sourceFile, targetFile
+	if len(opts.encodings) != 0 {
+		sourceData, targetData, decoded, err = opts.decode(sourceData, targetData)
+		if err != nil {
+			return nil, nil, false, false, err
+		}
+	}
+	if whitespace && opts.whitespace != nil {
+		sourceData, targetData, normalized = opts.normalize(sourceData, targetData)
+	}
+	return sourceData, targetData, decoded, normalized, nil
+}
+
+// equalDigest compares the content of files by writing them to hashes, either
+// because the comparison was configured with CompareContentHash, or because
+// files on both sides implement io.WriterTo. In the latter case, the content of
+// files with the same digest is then compared byte by byte to rule out hash
+// collisions unless configured with DigestOnly, which requires reopening the
+// files.
+func equalDigest(source, target fs.FS, name string, sourceData, targetData io.Reader, buf []byte, opts *equalOptions) error {
+	newHash := opts.hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	sourceSum, n1, err1 := digest(newHash(), sourceData, buf)
+	targetSum, n2, err2 := digest(newHash(), targetData, buf)
+	opts.observeBytes(int(n1), int(n2))
+	if permissionAsymmetry(err1, err2) {
+		return equalError(name, mismatchf(PermissionAsymmetry, "%v", err1, err2, "file read permission mismatch"))
+	}
+	if err1 != err2 && !errors.Is(err1, unwrap(err2)) {
+		return equalError(name, mismatchf(ErrorChanged, "%v", err1, err2, "file read error mismatch"))
+	}
+	if !bytes.Equal(sourceSum, targetSum) {
+		return equalError(name, mismatchf(ContentChanged, "%x", sourceSum, targetSum, "file content digest mismatch"))
+	}
+	if opts.hash != nil || opts.digestOnly {
+		return nil
+	}
+	sourceFile, err := source.Open(name)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	targetFile, err := target.Open(name)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+	if err := equalData(sourceFile, targetFile, buf, opts); err != nil {
+		return equalError(name, err)
 	}
 	return nil
 }
 
-func equalData(source, target fs.File, buf []byte) error {
+func isWriterTo(source, target io.Reader) bool {
+	_, sourceOK := source.(io.WriterTo)
+	_, targetOK := target.(io.WriterTo)
+	return sourceOK && targetOK
+}
+
+// digest writes the content of r to h, using the io.WriterTo implementation of
+// r if it has one.
+func digest(h hash.Hash, r io.Reader, buf []byte) ([]byte, int64, error) {
+	n, err := io.CopyBuffer(h, r, buf)
+	return h.Sum(nil), n, err
+}
+
+func equalNode(source, target fs.FS, name string, opts *equalOptions) error {
+	if _, err := equalStat(source, target, name, true, opts); err != nil {
+		return equalError(name, err)
+	}
+	return nil
+}
+
+func equalPermission(source, target fs.FS, name string) error {
+	var sourcePerm, targetPerm fs.FileMode
+	if info, err := fs.Stat(source, name); err == nil {
+		sourcePerm = info.Mode().Perm()
+	}
+	if info, err := fs.Stat(target, name); err == nil {
+		targetPerm = info.Mode().Perm()
+	}
+	return equalError(name, mismatchf(PermissionAsymmetry, "%s", sourcePerm, targetPerm, "permission asymmetry"))
+}
+
+// permissionAsymmetry returns true if only one of err1 and err2 denies the
+// access to a file.
+func permissionAsymmetry(err1, err2 error) bool {
+	return errors.Is(err1, fs.ErrPermission) != errors.Is(err2, fs.ErrPermission)
+}
+
+// deniedRead returns true if reading the first chunk of source and target
+// fails with a permission error on one side only.
+func deniedRead(source, target io.Reader, buf []byte) bool {
+	_, err1 := source.Read(buf[:len(buf)/2])
+	_, err2 := target.Read(buf[len(buf)/2:])
+	return permissionAsymmetry(err1, err2)
+}
+
+// EqualReader compares the data read from a and b, which may be fs.File values
+// or any other streams, returning nil if they are equal, or an *EqualError of
+// kind ContentChanged, ErrorChanged, or PermissionAsymmetry describing the
+// first difference and the offset where it was found. This allows comparing
+// streams (e.g. decompressed data) against expected files without constructing
+// file systems.
+//
+// The buffer is split in halves to read from a and b; a buffer is allocated if
+// buf is shorter than 1 KiB.
+func EqualReader(a, b io.Reader, buf []byte) error {
+	if len(buf) < equalFSMinSize {
+		buf = make([]byte, equalFSBufSize)
+	}
+	return equalData(a, b, buf, newEqualOptions(nil))
+}
+
+func equalData(source, target io.Reader, buf []byte, opts *equalOptions) error {
 	buf1 := buf[:len(buf)/2]
 	buf2 := buf[len(buf)/2:]
-	for {
-		n1, err1 := source.Read(buf1)
-		n2, err2 := target.Read(buf2)
-		if n1 != n2 {
-			return fmt.Errorf("file read size mismatch: want=%d got=%d", n1, n2)
+	for offset := int64(0); ; {
+		if err := opts.ctx.Err(); err != nil {
+			return err
 		}
-		b1 := buf1[:n1]
-		b2 := buf2[:n2]
-		if !bytes.Equal(b1, b2) {
-			return fmt.Errorf("file content mismatch: want=%q got=%q", b1, b2)
+		// Files may return short reads, or return data alongside an error, so
+		// we fill the buffers before comparing them. The data is compared
+		// first, then the errors, and finally the amount of data read.
+		n1, err1 := readFull(source, buf1)
+		n2, err2 := readFull(target, buf2)
+		opts.observeBytes(n1, n2)
+		n := n1
+		if n > n2 {
+			n = n2
 		}
-		if err1 != err2 {
-			return fmt.Errorf("file read error mismatch: want=%v got=%v", err1, err2)
+		b1 := buf1[:n]
+		b2 := buf2[:n]
+		// The content type is only detected from the beginning of files, so
+		// it can only differ if the first chunks are different.
+		first := offset == 0
+		if i := mismatch(b1, b2); i >= 0 {
+			var contentType string
+			if first {
+				contentType = opts.contentTypeChange(buf1[:n1], buf2[:n2])
+			}
+			return mismatchf(ContentChanged, "0x%02x", b1[i], b2[i], "file content mismatch at offset %d", offset+int64(i)).annotate(contentType)
+		}
+		if n1 != n2 && opts.sizeRounding > 0 {
+			// One of the files may be padded with zeros up to the block size,
+			// in which case the shorter one must have reached its end.
+			switch {
+			case n1 < n2 && err1 == io.EOF:
+				return equalPadding(target, buf2[n:n2], err2, buf2)
+			case n2 < n1 && err2 == io.EOF:
+				return equalPadding(source, buf1[n:n1], err1, buf1)
+			}
+		}
+		if permissionAsymmetry(err1, err2) {
+			return mismatchf(PermissionAsymmetry, "%v", err1, err2, "file read permission mismatch at offset %d", offset+int64(n))
+		}
+		if err1 != err2 && !errors.Is(err1, unwrap(err2)) {
+			return mismatchf(ErrorChanged, "%v", err1, err2, "file read error mismatch at offset %d", offset+int64(n))
+		}
+		if n1 != n2 {
+			var contentType string
+			if first {
+				contentType = opts.contentTypeChange(buf1[:n1], buf2[:n2])
+			}
+			return mismatchf(ContentChanged, "%d", n1, n2, "file read size mismatch at offset %d", offset).annotate(contentType)
 		}
 		if err1 != nil {
 			break
 		}
+		offset += int64(n)
 	}
 	return nil
 }
 
-func equalStat(source, target fs.FS, name string) error {
+// mismatch returns the index of the first byte differing between a and b,
+// which have the same length, or -1 if they are equal.
+func mismatch(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalPadding(r io.Reader, tail []byte, err error, buf []byte) error {
+	for {
+		for _, b := range tail {
+			if b != 0 {
+				return mismatchf(ContentChanged, "%d", 0, b, "file padding mismatch")
+			}
+		}
+		if err != nil {
+			break
+		}
+		var n int
+		n, err = readFull(r, buf)
+		tail = buf[:n]
+	}
+	if err != io.EOF {
+		return mismatchf(ErrorChanged, "%v", io.EOF, err, "file read error mismatch")
+	}
+	return nil
+}
+
+// Readers are discouraged from returning zero bytes and no error, we use the
+// same limit as bufio to detect the ones that would never make progress.
+const maxConsecutiveEmptyReads = 100
+
+func readFull(r io.Reader, b []byte) (n int, err error) {
+	for empty := 0; n < len(b) && err == nil; {
+		var rn int
+		rn, err = r.Read(b[n:])
+		n += rn
+		if rn > 0 {
+			empty = 0
+		} else if empty++; empty == maxConsecutiveEmptyReads && err == nil {
+			err = io.ErrNoProgress
+		}
+	}
+	return n, err
+}
+
+func equalStat(source, target fs.FS, name string, compareSize bool, opts *equalOptions) (fs.FileInfo, error) {
 	sourceInfo, err := fs.Stat(source, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	targetInfo, err := fs.Stat(target, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	sourceMode := sourceInfo.Mode()
 	targetMode := targetInfo.Mode()
 	sourceType := sourceMode.Type()
 	targetType := targetMode.Type()
 	if sourceType != targetType {
-		return fmt.Errorf("file types mismatch: want=%s got=%s", sourceType, targetType)
+		return nil, mismatchf(TypeChanged, "%s", sourceType, targetType, "file types mismatch")
 	}
 	sourcePerm := sourceMode.Perm()
 	targetPerm := targetMode.Perm()
 	// Sometimes the permission bits may not be available. Clearly we were able
 	// to open the files so we should have at least read permissions reported so
 	// just ignore the permissions if either the source or target are zero. This
-	// happens with virtualized directories for fstest.MapFS for example.
-	if sourcePerm != 0 && targetPerm != 0 && sourcePerm != targetPerm {
-		return fmt.Errorf("file modes mismatch: want=%s got=%s", sourceMode, targetMode)
+	// happens with virtualized directories for fstest.MapFS for example, which
+	// can be configured with MapFS.VirtualDirs to be compared instead.
+	if sourcePerm != 0 && targetPerm != 0 && sourceMode&opts.modeMask != targetMode&opts.modeMask {
+		return nil, mismatchf(ModeChanged, "%s", sourceMode, targetMode, "file modes mismatch")
 	}
-	sourceModTime := fsinfo.ModTime(sourceInfo)
-	targetModTime := fsinfo.ModTime(targetInfo)
-	if err := equalTime("modification", sourceModTime, targetModTime); err != nil {
-		return err
+	if !opts.ignoreModTime {
+		sourceModTime := modTime(sourceInfo)
+		targetModTime := modTime(targetInfo)
+		if err := equalTime("modification", sourceModTime, targetModTime, opts.timeTolerance, opts.requireModTime); err != nil {
+			return nil, err
+		}
 	}
-	sourceAccessTime := fsinfo.AccessTime(sourceInfo)
-	targetAccessTime := fsinfo.AccessTime(targetInfo)
-	if err := equalTime("access", sourceAccessTime, targetAccessTime); err != nil {
-		return err
+	if !opts.ignoreAccessTime {
+		sourceAccessTime := accessTime(sourceInfo)
+		targetAccessTime := accessTime(targetInfo)
+		if err := equalTime("access", sourceAccessTime, targetAccessTime, opts.timeTolerance, opts.requireAccess); err != nil {
+			return nil, err
+		}
 	}
-	sourceChangeTime := fsinfo.ChangeTime(sourceInfo)
-	targetChangeTime := fsinfo.ChangeTime(targetInfo)
-	if err := equalTime("change", sourceChangeTime, targetChangeTime); err != nil {
-		return err
+	if !opts.ignoreChangeTime {
+		sourceChangeTime := changeTime(sourceInfo)
+		targetChangeTime := changeTime(targetInfo)
+		if err := equalTime("change", sourceChangeTime, targetChangeTime, opts.timeTolerance, opts.requireChange); err != nil {
+			return nil, err
+		}
 	}
 	// Directory sizes are platform-dependent, there is no need to compare.
-	if !sourceInfo.IsDir() {
+	if compareSize && !sourceInfo.IsDir() {
 		sourceSize := sourceInfo.Size()
 		targetSize := targetInfo.Size()
-		if sourceSize != targetSize {
-			return fmt.Errorf("files sizes mismatch: want=%d got=%d", sourceSize, targetSize)
+		if opts.roundSize(sourceSize) != opts.roundSize(targetSize) {
+			return nil, mismatchf(SizeChanged, "%d", sourceSize, targetSize, "files sizes mismatch")
 		}
 	}
-	return nil
+	return sourceInfo, nil
 }
 
-func equalTime(typ string, source, target time.Time) error {
+func equalTime(typ string, source, target time.Time, tolerance time.Duration, require bool) error {
+	if require && (source.IsZero() || target.IsZero()) {
+		return mismatchf(TimeChanged, "%v", source, target, "file %s time missing", typ)
+	}
 	// Only compare the modification times if both file systems support it,
 	// assuming a zero time means it's not supported.
-	if !source.IsZero() && !target.IsZero() && !source.Equal(target) {
-		return fmt.Errorf("file %s times mismatch: want=%v got=%v", typ, source, target)
+	if source.IsZero() || target.IsZero() || source.Equal(target) {
+		return nil
 	}
-	return nil
+	if tolerance > 0 {
+		delta := source.Sub(target)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta <= tolerance {
+			return nil
+		}
+	}
+	return mismatchf(TimeChanged, "%v", source, target, "file %s times mismatch", typ)
+}
+
+// modTime returns the modification time of the file, preferring the value of
+// the system-specific information when there is one. fsinfo only reads the
+// times from *syscall.Stat_t values, other implementations of fs.FileInfo
+// (such as the ones of MapFS) report the time through their ModTime method.
+func modTime(info fs.FileInfo) time.Time {
+	if t := fsinfo.ModTime(info); !t.IsZero() {
+		return t
+	}
+	return info.ModTime()
+}
+
+// File information may carry access and change times directly, which is the
+// case for the wrappers of this package altering the times reported by the
+// underlying file system.
+func accessTime(info fs.FileInfo) time.Time {
+	if t, ok := info.(interface{ AccessTime() time.Time }); ok {
+		return t.AccessTime()
+	}
+	return fsinfo.AccessTime(info)
+}
+
+func changeTime(info fs.FileInfo) time.Time {
+	if t, ok := info.(interface{ ChangeTime() time.Time }); ok {
+		return t.ChangeTime()
+	}
+	return fsinfo.ChangeTime(info)
+}
+
+func equalErrorf(name string, kind Kind, msg string, args ...any) error {
+	return equalError(name, differencef(kind, msg, args...))
+}
+
+func equalError(name string, err error) error {
+	return &fs.PathError{Op: "equal", Path: name, Err: err}
+}
+
+func differencef(kind Kind, msg string, args ...any) error {
+	return &EqualError{Kind: kind, Err: fmt.Errorf(msg, args...)}
 }
 
-func equalErrorf(name, msg string, args ...any) error {
-	return &fs.PathError{Op: "equal", Path: name, Err: fmt.Errorf(msg, args...)}
+// mismatchf returns a difference of the given kind between the values want
+// and got, which are formatted with verb and appended to the message formatted
+// from msg and args. The error carries the formatted values for DiffFS.
+func mismatchf(kind Kind, verb string, want, got any, msg string, args ...any) *EqualError {
+	w, g := fmt.Sprintf(verb, want), fmt.Sprintf(verb, got)
+	err := fmt.Errorf("%s: want=%s got=%s", fmt.Sprintf(msg, args...), w, g)
+	return &EqualError{Kind: kind, Err: err, want: w, got: g}
 }
 
 func unwrap(err error) error {
@@ -1,7 +1,15 @@
 package fstest_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
 	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
 	"testing"
 
 	"github.com/stealthrocket/fstest"
@@ -27,3 +35,317 @@ func TestEqualFS(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestEqualFSReadErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	tests := []struct {
+		scenario string
+		source   fs.FS
+		target   fs.FS
+		equal    bool
+	}{
+		{
+			scenario: "short reads",
+			source:   a,
+			target:   readErrorFS{a, 3, io.EOF},
+			equal:    true,
+		},
+		{
+			scenario: "data with the same error",
+			source:   readErrorFS{a, 3, errBoom},
+			target:   readErrorFS{a, 5, errBoom},
+			equal:    true,
+		},
+		{
+			scenario: "data with a different error",
+			source:   a,
+			target:   readErrorFS{a, 5, errBoom},
+			equal:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			err := fstest.EqualFS(test.source, test.target)
+			if test.equal && err != nil {
+				t.Error(err)
+			}
+			if !test.equal {
+				var equalErr *fstest.EqualError
+				if !errors.As(err, &equalErr) || equalErr.Kind != fstest.ErrorChanged {
+					t.Errorf("expected a read error mismatch, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// readErrorFS returns files which read at most chunk bytes at a time, and
+// return err alongside the last bytes of data.
+type readErrorFS struct {
+	fstest.MapFS
+	chunk int
+	err   error
+}
+
+func (fsys readErrorFS) Open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := f.Stat()
+	if err != nil || s.IsDir() {
+		return f, err
+	}
+	data, err := fsys.MapFS.ReadFile(name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &readErrorFile{f, data, fsys.chunk, fsys.err}, nil
+}
+
+type readErrorFile struct {
+	fs.File
+	data  []byte
+	chunk int
+	err   error
+}
+
+func (f *readErrorFile) Read(b []byte) (int, error) {
+	if len(b) > f.chunk {
+		b = b[:f.chunk]
+	}
+	n := copy(b, f.data)
+	f.data = f.data[n:]
+	if len(f.data) == 0 {
+		return n, f.err
+	}
+	return n, nil
+}
+
+func TestEqualFSEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nilData := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644}}
+	emptyData := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte{}}}
+
+	sources := map[string]fs.FS{
+		"nil data":   nilData,
+		"empty data": emptyData,
+	}
+	targets := map[string]fs.FS{
+		"nil data":    nilData,
+		"empty data":  emptyData,
+		"read errors": readErrorFS{emptyData, 1, io.EOF},
+		"directory":   os.DirFS(dir),
+	}
+	options := map[string][]fstest.EqualOption{
+		"default":          nil,
+		"size rounding":    {fstest.WithSizeRounding(512)},
+		"content type":     {fstest.DetectContentType()},
+		"encodings":        {fstest.WithEquivalentEncodings()},
+		"skip larger than": {fstest.SkipLargerThan(0)},
+	}
+
+	for sourceName, source := range sources {
+		for targetName, target := range targets {
+			for optionName, opts := range options {
+				scenario := sourceName + "/" + targetName + "/" + optionName
+				if err := fstest.EqualFSWith(source, target, opts...); err != nil {
+					t.Errorf("%s: %v", scenario, err)
+				}
+				if err := fstest.EqualFSWith(target, source, opts...); err != nil {
+					t.Errorf("%s (reversed): %v", scenario, err)
+				}
+			}
+		}
+	}
+
+	notEmpty := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte{0}}}
+	var equalErr *fstest.EqualError
+	if err := fstest.EqualFS(nilData, notEmpty); !errors.As(err, &equalErr) || equalErr.Kind != fstest.SizeChanged {
+		t.Errorf("expected a size difference, got %v", err)
+	}
+}
+
+func TestEqualFSContentOffset(t *testing.T) {
+	data := make([]byte, 100000)
+	changed := make([]byte, len(data))
+	changed[54321] = 0x42
+
+	a := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: data}}
+	b := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: changed}}
+
+	err := fstest.EqualFS(a, b)
+	if err == nil {
+		t.Fatal("expected a content mismatch")
+	}
+	const want = "equal file: file content mismatch at offset 54321: want=0x00 got=0x42"
+	if err.Error() != want {
+		t.Errorf("error message mismatch:\nwant: %s\ngot:  %s", want, err)
+	}
+}
+
+func TestEqualReader(t *testing.T) {
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: data}}
+	f, err := fsys.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := fstest.EqualReader(f, r, nil); err != nil {
+		t.Error(err)
+	}
+
+	changed := bytes.Clone(data)
+	changed[54321] = 0x42
+	err = fstest.EqualReader(bytes.NewReader(data), bytes.NewReader(changed), make([]byte, 4096))
+	if !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Errorf("expected a content mismatch, got %v", err)
+	}
+	const want = "file content mismatch at offset 54321: want=0x31 got=0x42"
+	if err != nil && err.Error() != want {
+		t.Errorf("error message mismatch:\nwant: %s\ngot:  %s", want, err)
+	}
+	if err := fstest.EqualReader(bytes.NewReader(data), bytes.NewReader(data[:1000]), nil); !errors.Is(err, fstest.ErrErrorMismatch) {
+		t.Errorf("expected the early end of the stream to be reported, got %v", err)
+	}
+}
+
+func TestEqualFSEntriesMismatch(t *testing.T) {
+	a := fstest.MapFS{
+		"dir/a": &fstest.MapFile{Mode: 0644},
+		"dir/b": &fstest.MapFile{Mode: 0644},
+		"dir/c": &fstest.MapFile{Mode: 0644},
+		"dir/d": &fstest.MapFile{Mode: 0644},
+	}
+	b := fstest.MapFS{
+		"dir/a": &fstest.MapFile{Mode: 0644},
+		"dir/d": &fstest.MapFile{Mode: 0644},
+		"dir/x": &fstest.MapFile{Mode: 0644},
+	}
+
+	tests := []struct {
+		a, b fstest.MapFS
+		want string
+	}{
+		{a, b, "equal dir: directory entries mismatch: missing in target: [b c], extra in target: [x]"},
+		{b, a, "equal dir: directory entries mismatch: missing in target: [x], extra in target: [b c]"},
+		{a, fstest.MapFS{"dir/a": a["dir/a"]}, "equal dir: directory entries mismatch: missing in target: [b c d]"},
+	}
+	for _, test := range tests {
+		err := fstest.EqualFS(test.a, test.b)
+		if !errors.Is(err, fstest.ErrEntriesMismatch) {
+			t.Fatalf("expected an entries mismatch, got %v", err)
+		}
+		if err.Error() != test.want {
+			t.Errorf("error message mismatch:\nwant: %s\ngot:  %s", test.want, err)
+		}
+	}
+}
+
+func TestEqualFSContextCanceled(t *testing.T) {
+	fsys := fstest.MapFS{}
+	dir := "."
+	for i := 0; i < 100; i++ {
+		dir = path.Join(dir, "dir")
+		fsys[dir] = &fstest.MapFile{Mode: 0755 | fs.ModeDir}
+		fsys[path.Join(dir, "file")] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opens := 0
+	target := cancelFS{fsys, func() {
+		if opens++; opens == 20 {
+			cancel()
+		}
+	}}
+
+	err := fstest.EqualFSContext(ctx, fsys, target, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if opens > 21 {
+		t.Errorf("comparison continued after cancellation: %d files opened", opens)
+	}
+	if err := fstest.EqualFSContext(context.Background(), fsys, target, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// cancelFS calls a function each time a file is opened.
+type cancelFS struct {
+	fs.FS
+	open func()
+}
+
+func (fsys cancelFS) Open(name string) (fs.File, error) {
+	fsys.open()
+	return fsys.FS.Open(name)
+}
+
+func TestEqualFSUnsortedEntries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":       &fstest.MapFile{Mode: 0644, Data: []byte("a")},
+		"b":       &fstest.MapFile{Mode: 0644, Data: []byte("b")},
+		"c":       &fstest.MapFile{Mode: 0644, Data: []byte("c")},
+		"dir":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/x":   &fstest.MapFile{Mode: 0644, Data: []byte("x")},
+		"dir/y":   &fstest.MapFile{Mode: 0644, Data: []byte("y")},
+		"dir/z/w": &fstest.MapFile{Mode: 0644, Data: []byte("w")},
+	}
+
+	if err := fstest.EqualFS(fsys, shuffleFS{fsys}); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFS(shuffleFS{fsys}, fsys); err != nil {
+		t.Error(err)
+	}
+
+	changed := fstest.MapFS{}
+	for name, file := range fsys {
+		changed[name] = file
+	}
+	delete(changed, "b")
+	if err := fstest.EqualFS(fsys, shuffleFS{changed}); !errors.Is(err, fstest.ErrEntriesMismatch) {
+		t.Errorf("expected a mismatch of directory entries, got %v", err)
+	}
+}
+
+// shuffleFS returns directory entries in reverse order.
+type shuffleFS struct{ fstest.MapFS }
+
+func (fsys shuffleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fsys.MapFS.ReadDir(name)
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, err
+}
@@ -0,0 +1,77 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GlobRecursive is like Glob but the pattern may contain "**" segments, which
+// match zero or more path segments, so "**/*.go" matches the files with the
+// ".go" extension at any depth. The other segments of the pattern are matched
+// against a single path segment using the syntax of path.Match.
+//
+// The returned names are sorted, and do not include the root directory. The
+// only possible returned error is path.ErrBadPattern, when the pattern is
+// malformed.
+func (fsys MapFS) GlobRecursive(pattern string) ([]string, error) {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+	}
+	var matches []string
+	err := fs.WalkDir(fsys, ".", func(name string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && matchGlob(pattern, name) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchGlob reports whether name matches the shell pattern, using the syntax
+// of path.Match for each path segment, with the addition of "**" segments
+// which match zero or more path segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
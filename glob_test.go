@@ -0,0 +1,47 @@
+package fstest_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMapFSGlobRecursive(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b":          &fstest.MapFile{Mode: 0644},
+		"a/x/b":        &fstest.MapFile{Mode: 0644},
+		"a/x/y/b":      &fstest.MapFile{Mode: 0644},
+		"a/x/y/c.go":   &fstest.MapFile{Mode: 0644},
+		"a.go":         &fstest.MapFile{Mode: 0644},
+		"main.go":      &fstest.MapFile{Mode: 0644},
+		"vendor/b":     &fstest.MapFile{Mode: 0644},
+		"vendor/lib.c": &fstest.MapFile{Mode: 0644},
+	}
+
+	tests := []struct {
+		pattern string
+		matches []string
+	}{
+		{"**/*.go", []string{"a.go", "a/x/y/c.go", "main.go"}},
+		{"a/**/b", []string{"a/b", "a/x/b", "a/x/y/b"}},
+		{"**/b", []string{"a/b", "a/x/b", "a/x/y/b", "vendor/b"}},
+		{"*.go", []string{"a.go", "main.go"}},
+		{"a/*/b", []string{"a/x/b"}},
+		{"**", []string{"a", "a.go", "a/b", "a/x", "a/x/b", "a/x/y", "a/x/y/b", "a/x/y/c.go", "main.go", "vendor", "vendor/b", "vendor/lib.c"}},
+		{"missing/**", nil},
+	}
+	for _, test := range tests {
+		matches, err := fsys.GlobRecursive(test.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", test.pattern, err)
+		} else if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%s: matches mismatch:\nwant: %q\ngot:  %q", test.pattern, test.matches, matches)
+		}
+	}
+
+	if _, err := fsys.GlobRecursive("**/[a"); err != path.ErrBadPattern {
+		t.Errorf("expected path.ErrBadPattern, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package fstest
+
+import (
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// DetectHardlinks configures the comparison to verify that the regular files
+// which are hard links of the same file in one file system are also hard links
+// of the same file in the other. Differences are reported with the kind
+// HardlinkChanged, after all the files were compared.
+//
+// Hard links are identified by the device and inode numbers of files, which
+// are obtained like in CheckInodeUniqueness. Files for which the file systems
+// do not report inode numbers are only compared by content, which is always
+// the case on platforms without inodes, except for MapFS entries modeling
+// hard links with MapFileSys.
+func DetectHardlinks() EqualOption {
+	return func(opts *equalOptions) { opts.detectHardlinks = true }
+}
+
+// hardlinks records the inodes of the regular files compared, to verify that
+// the groups of hard links are the same on both sides.
+type hardlinks struct {
+	mutex  sync.Mutex
+	source map[string]inodeKey
+	target map[string]inodeKey
+}
+
+func (h *hardlinks) add(name string, source, target fs.DirEntry) error {
+	sourceInfo, err := source.Info()
+	if err != nil {
+		return err
+	}
+	targetInfo, err := target.Info()
+	if err != nil {
+		return err
+	}
+	sourceDev, sourceIno, ok1 := inode(sourceInfo)
+	targetDev, targetIno, ok2 := inode(targetInfo)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.source == nil {
+		h.source = make(map[string]inodeKey)
+		h.target = make(map[string]inodeKey)
+	}
+	h.source[name] = inodeKey{sourceDev, sourceIno}
+	h.target[name] = inodeKey{targetDev, targetIno}
+	return nil
+}
+
+// check compares the groups of hard links of each file recorded, passing the
+// differences to record in path order.
+func (h *hardlinks) check(record func(error) error) error {
+	sourceLinks := linkGroups(h.source)
+	targetLinks := linkGroups(h.target)
+
+	names := make([]string, 0, len(h.source))
+	for name := range h.source {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sourceGroup := sourceLinks[h.source[name]]
+		targetGroup := targetLinks[h.target[name]]
+		if !equalStrings(sourceGroup, targetGroup) {
+			err := equalError(name, mismatchf(HardlinkChanged, "%q", sourceGroup, targetGroup, "hard links mismatch"))
+			if err := record(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// linkGroups returns the sorted paths of the files sharing each inode.
+func linkGroups(inodes map[string]inodeKey) map[inodeKey][]string {
+	groups := make(map[inodeKey][]string)
+	for name, key := range inodes {
+		groups[key] = append(groups[key], name)
+	}
+	for _, group := range groups {
+		sort.Strings(group)
+	}
+	return groups
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
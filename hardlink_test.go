@@ -0,0 +1,59 @@
+package fstest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestDetectHardlinks(t *testing.T) {
+	newFS := func(inodes map[string]uint64) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for name, ino := range inodes {
+			fsys[name] = &fstest.MapFile{Mode: 0644, Data: []byte("A"), Sys: &fstest.MapFileSys{Ino: ino}}
+		}
+		return fsys
+	}
+
+	a := newFS(map[string]uint64{"a": 1, "b": 1, "c": 2, "dir/d": 1})
+	b := newFS(map[string]uint64{"a": 10, "b": 10, "c": 20, "dir/d": 10})
+	c := newFS(map[string]uint64{"a": 10, "b": 20, "c": 20, "dir/d": 10})
+
+	for _, opts := range [][]fstest.EqualOption{
+		{fstest.DetectHardlinks()},
+		{fstest.DetectHardlinks(), fstest.Parallel(4)},
+	} {
+		if err := fstest.EqualFSWith(a, b, opts...); err != nil {
+			t.Error(err)
+		}
+		if err := fstest.EqualFS(a, c); err != nil {
+			t.Errorf("expected files to be equal without the option: %v", err)
+		}
+		err := fstest.EqualFSWith(a, c, opts...)
+		if !errors.Is(err, fstest.ErrHardlinkMismatch) {
+			t.Fatalf("expected a hard link mismatch, got %v", err)
+		}
+		const want = `equal a: hard links mismatch: want=["a" "b" "dir/d"] got=["a" "dir/d"]`
+		if err.Error() != want {
+			t.Errorf("error message mismatch:\nwant: %s\ngot:  %s", want, err)
+		}
+	}
+
+	err := fstest.EqualFSAll(a, c, fstest.DetectHardlinks())
+	var errs fstest.EqualErrors
+	if !errors.As(err, &errs) || len(errs) != 4 {
+		t.Errorf("expected differences for all the files, got %v", err)
+	}
+
+	// Files without inode numbers are only compared by content.
+	d := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"b":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"c":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/d": &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+	}
+	if err := fstest.EqualFSWith(a, d, fstest.DetectHardlinks()); err != nil {
+		t.Error(err)
+	}
+}
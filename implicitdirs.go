@@ -0,0 +1,174 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ImplicitDirs configures the comparison to synthesize the directories which
+// are only implied by the names of files, such as the file systems backed by
+// object stores listing keys containing slashes (e.g. "a/b/c") instead of
+// directories.
+//
+// Directory entries with names containing slashes are placed in the tree of
+// directories leading to them, which are synthesized when they do not exist,
+// similarly to what archive/zip does for archives without directory entries.
+// The file systems are indexed on both sides before being compared, so that
+// directories existing on one side align with directories implied on the
+// other.
+//
+// The synthesized directories have a zero modification time and no permission
+// bits unless configured with ImplicitDirMode.
+func ImplicitDirs() EqualOption {
+	return func(opts *equalOptions) { opts.implicitDirs = true }
+}
+
+// ImplicitDirMode configures the permission bits of the directories that
+// ImplicitDirs synthesizes.
+func ImplicitDirMode(perm fs.FileMode) EqualOption {
+	return func(opts *equalOptions) { opts.implicitPerm = perm.Perm() }
+}
+
+func (opts *equalOptions) wrap(fsys fs.FS) fs.FS {
+	if opts.followSymlinks {
+		fsys = &followFS{base: fsys}
+	}
+	if opts.implicitDirs {
+		fsys = &implicitDirFS{base: fsys, perm: opts.implicitPerm}
+	}
+	return fsys
+}
+
+type implicitDirFS struct {
+	base fs.FS
+	perm fs.FileMode
+	once sync.Once
+	err  error
+	// Entries of the directories, indexed by path, and the set of paths of
+	// the synthesized directories.
+	dirs     map[string]map[string]fs.DirEntry
+	implicit map[string]bool
+}
+
+func (fsys *implicitDirFS) index() error {
+	fsys.once.Do(func() {
+		fsys.dirs = map[string]map[string]fs.DirEntry{".": {}}
+		fsys.implicit = make(map[string]bool)
+		fsys.err = fsys.scan(".")
+	})
+	return fsys.err
+}
+
+func (fsys *implicitDirFS) scan(dir string) error {
+	entries, err := fs.ReadDir(fsys.base, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		fsys.add(name, entry, false)
+		if entry.IsDir() {
+			if err := fsys.scan(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add records the entry in its parent directory, synthesizing the directories
+// leading to it if they were not seen yet. Directories listed by the base file
+// system take precedence over the synthesized ones.
+func (fsys *implicitDirFS) add(name string, entry fs.DirEntry, implicit bool) {
+	dir, base := path.Split(name)
+	dir = path.Clean(dir)
+	if entry.Name() != base {
+		entry = &renamedEntry{entry, base}
+	}
+	if fsys.dirs[dir] == nil {
+		fsys.add(dir, fs.FileInfoToDirEntry(&dirInfo{name: path.Base(dir), perm: fsys.perm}), true)
+	}
+	if entry.IsDir() {
+		if fsys.dirs[name] != nil {
+			if implicit || !fsys.implicit[name] {
+				return
+			}
+		} else {
+			fsys.dirs[name] = make(map[string]fs.DirEntry)
+		}
+		fsys.implicit[name] = implicit
+	}
+	fsys.dirs[dir][base] = entry
+}
+
+func (fsys *implicitDirFS) Open(name string) (fs.File, error) {
+	if err := fsys.index(); err != nil {
+		return nil, err
+	}
+	if fsys.dirs[name] == nil {
+		return fsys.base.Open(name)
+	}
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &mountDir{name: name, info: info, entries: entries}, nil
+}
+
+func (fsys *implicitDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := fsys.index(); err != nil {
+		return nil, err
+	}
+	dir := fsys.dirs[name]
+	if dir == nil {
+		return fs.ReadDir(fsys.base, name)
+	}
+	entries := make([]fs.DirEntry, 0, len(dir))
+	for _, entry := range dir {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (fsys *implicitDirFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *implicitDirFS) Stat(name string) (fs.FileInfo, error) {
+	if err := fsys.index(); err != nil {
+		return nil, err
+	}
+	if fsys.implicit[name] {
+		return &dirInfo{name: path.Base(name), perm: fsys.perm}, nil
+	}
+	return fs.Stat(fsys.base, name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*implicitDirFS)(nil)
+	_ fs.StatFS         = (*implicitDirFS)(nil)
+	_ fslink.ReadLinkFS = (*implicitDirFS)(nil)
+)
+
+type renamedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e *renamedEntry) Name() string { return e.name }
+
+func (e *renamedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return &renamedInfo{info, e.name}, nil
+}
@@ -0,0 +1,79 @@
+package fstest_test
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+// flatFS is a file system listing all its files in the root directory with
+// names containing slashes, like object stores do.
+type flatFS struct{ fstest.MapFS }
+
+func (fsys flatFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	err := fs.WalkDir(fsys.MapFS, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			entries = append(entries, &flatEntry{entry, name})
+		}
+		return err
+	})
+	return entries, err
+}
+
+type flatEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e *flatEntry) Name() string { return e.name }
+
+func TestImplicitDirs(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	golden := fstest.MapFS{
+		"a":         &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: modTime},
+		"dir":       &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"dir/b":     &fstest.MapFile{Mode: 0644, Data: []byte("B"), ModTime: modTime},
+		"dir/sub":   &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"dir/sub/c": &fstest.MapFile{Mode: 0644, Data: []byte("C"), ModTime: modTime},
+	}
+	flat := flatFS{fstest.MapFS{
+		"a":         &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: modTime},
+		"dir/b":     &fstest.MapFile{Mode: 0644, Data: []byte("B"), ModTime: modTime},
+		"dir/sub/c": &fstest.MapFile{Mode: 0644, Data: []byte("C"), ModTime: modTime},
+	}}
+
+	if err := fstest.EqualFS(golden, flat); err == nil {
+		t.Error("expected the file systems to differ without implicit directories")
+	}
+	if err := fstest.EqualFSWith(golden, flat, fstest.ImplicitDirs()); err != nil {
+		t.Error(err)
+	}
+
+	entries, err := fstest.List(flat, fstest.ImplicitDirs(), fstest.ImplicitDirMode(0755))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.Mode.IsDir() {
+			if entry.Mode != fs.ModeDir|0755 {
+				t.Errorf("%s: mode mismatch: want=%v got=%v", entry.Path, fs.ModeDir|0755, entry.Mode)
+			}
+			dirs = append(dirs, entry.Path)
+		}
+	}
+	if len(dirs) != 2 || dirs[0] != "dir" || dirs[1] != "dir/sub" {
+		t.Errorf("synthesized directories mismatch: %q", dirs)
+	}
+
+	flat.MapFS["dir/sub/c"].Data = []byte("X")
+	if err := fstest.EqualFSWith(golden, flat, fstest.ImplicitDirs()); err == nil {
+		t.Error("expected the content of dir/sub/c to differ")
+	}
+}
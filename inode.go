@@ -0,0 +1,154 @@
+package fstest
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// InodeCollision describes distinct files of a file system sharing the same
+// inode number.
+type InodeCollision struct {
+	Dev   uint64
+	Ino   uint64
+	Paths []string
+}
+
+// CheckInodeUniqueness walks fsys and returns the groups of paths which share
+// an inode number but are not hard links of the same file, which indicates
+// that the file system aliases distinct files.
+//
+// Paths sharing an inode are considered hard links of the same file when they
+// have the same type, permissions, and content (or target for symbolic links).
+// The collisions are sorted by path.
+//
+// The inode numbers are obtained from the Sys method of the file information,
+// which must return a *syscall.Stat_t or a *MapFileSys with a non-zero inode
+// number; the function returns an error wrapping ErrUnsupported if the
+// information of a file other than a directory does not carry an inode number
+// or the platform does not support it. Directories without an inode number are
+// skipped.
+func CheckInodeUniqueness(fsys fs.FS) ([]InodeCollision, error) {
+	inodes := make(map[inodeKey][]string)
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		dev, ino, ok := inode(info)
+		if !ok {
+			// Directories cannot be hard linked, so the ones without an inode
+			// number (like those synthesized by MapFS) are not audited.
+			if entry.IsDir() {
+				return nil
+			}
+			return &fs.PathError{Op: "check", Path: name, Err: ErrUnsupported}
+		}
+		key := inodeKey{dev, ino}
+		inodes[key] = append(inodes[key], name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var collisions []InodeCollision
+	for key, paths := range inodes {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, name := range paths[1:] {
+			same, err := sameFile(fsys, paths[0], name)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				collisions = append(collisions, InodeCollision{
+					Dev:   key.dev,
+					Ino:   key.ino,
+					Paths: paths,
+				})
+				break
+			}
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Paths[0] < collisions[j].Paths[0]
+	})
+	return collisions, nil
+}
+
+type inodeKey struct{ dev, ino uint64 }
+
+// inode returns the device and inode numbers of the file described by info,
+// and whether they are known.
+func inode(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	if sys, _ := info.Sys().(*MapFileSys); sys != nil && sys.Ino != 0 {
+		return 0, sys.Ino, true
+	}
+	return sysInode(info)
+}
+
+func sameFile(fsys fs.FS, name1, name2 string) (bool, error) {
+	info1, err := lstat(fsys, name1)
+	if err != nil {
+		return false, err
+	}
+	info2, err := lstat(fsys, name2)
+	if err != nil {
+		return false, err
+	}
+	if info1.Mode() != info2.Mode() || info1.Size() != info2.Size() {
+		return false, nil
+	}
+	switch info1.Mode().Type() {
+	case 0: // regular
+		data1, err := fs.ReadFile(fsys, name1)
+		if err != nil {
+			return false, err
+		}
+		data2, err := fs.ReadFile(fsys, name2)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(data1, data2), nil
+	case fs.ModeSymlink:
+		link1, err := readLink(fsys, name1)
+		if err != nil {
+			return false, err
+		}
+		link2, err := readLink(fsys, name2)
+		if err != nil {
+			return false, err
+		}
+		return link1 == link2, nil
+	case fs.ModeDir:
+		// Directories cannot be hard linked.
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// lstat returns the information of name without following symbolic links,
+// which fs.Stat may do, by looking up the name in the entries of its parent.
+func lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if name == "." {
+		return fs.Stat(fsys, name)
+	}
+	dir, base := path.Split(name)
+	entries, err := fs.ReadDir(fsys, path.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Name() >= base })
+	if i == len(entries) || entries[i].Name() != base {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries[i].Info()
+}
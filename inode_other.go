@@ -0,0 +1,7 @@
+//go:build !unix
+
+package fstest
+
+import "io/fs"
+
+func sysInode(info fs.FileInfo) (dev, ino uint64, ok bool) { return 0, 0, false }
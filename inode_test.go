@@ -0,0 +1,68 @@
+//go:build unix
+
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCheckInodeUniqueness(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b"), []byte("B"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "c")); err != nil {
+		t.Skip(err)
+	}
+	collisions, err := fstest.CheckInodeUniqueness(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 0 {
+		t.Errorf("unexpected inode collisions: %+v", collisions)
+	}
+
+	stat := func(ino uint64) *syscall.Stat_t { return &syscall.Stat_t{Ino: ino} }
+	fsys := fstest.MapFS{
+		".": &fstest.MapFile{Mode: fs.ModeDir | 0755, Sys: stat(1)},
+		"a": &fstest.MapFile{Mode: 0644, Data: []byte("A"), Sys: stat(2)},
+		"b": &fstest.MapFile{Mode: 0644, Data: []byte("A"), Sys: stat(2)},
+		"c": &fstest.MapFile{Mode: 0644, Data: []byte("C"), Sys: stat(3)},
+		"d": &fstest.MapFile{Mode: 0644, Data: []byte("D"), Sys: stat(3)},
+	}
+	collisions, err = fstest.CheckInodeUniqueness(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fstest.InodeCollision{{Ino: 3, Paths: []string{"c", "d"}}}
+	if !reflect.DeepEqual(collisions, want) {
+		t.Errorf("inode collisions mismatch:\nwant=%+v\ngot= %+v", want, collisions)
+	}
+
+	// The synthesized root directory has no inode number and is skipped.
+	delete(fsys, ".")
+	fsys["dir/e"] = &fstest.MapFile{Mode: 0644, Data: []byte("E"), Sys: &fstest.MapFileSys{Ino: 4}}
+	collisions, err = fstest.CheckInodeUniqueness(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(collisions, want) {
+		t.Errorf("inode collisions mismatch:\nwant=%+v\ngot= %+v", want, collisions)
+	}
+
+	fsys["f"] = &fstest.MapFile{Mode: 0644, Data: []byte("F")}
+	if _, err := fstest.CheckInodeUniqueness(fsys); !errors.Is(err, fstest.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package fstest
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func sysInode(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat != nil && stat.Ino != 0 {
+		return uint64(stat.Dev), uint64(stat.Ino), true
+	}
+	return 0, 0, false
+}
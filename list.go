@@ -0,0 +1,237 @@
+package fstest
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// Entry describes a file listed by List.
+type Entry struct {
+	// Path of the file, relative to the root of the file system.
+	Path string
+	// Mode of the file, including its type bits.
+	Mode fs.FileMode
+	// Size of the file as reported by the file system.
+	Size int64
+	// Modification time of the file.
+	ModTime time.Time
+	// Target of the file, if it is a symbolic link.
+	Target string
+}
+
+// List returns the list of files in fsys, sorted by path. The root directory
+// is not included in the list.
+//
+// The Include and Exclude options can be passed to filter the files, and the
+// ImplicitDirs option to list the directories implied by the names of files;
+// other options are ignored. The content of files is not read, only their
+// metadata.
+func List(fsys fs.FS, opts ...EqualOption) ([]Entry, error) {
+	options := newEqualOptions(opts)
+	fsys = options.wrap(fsys)
+	entries := []Entry{}
+
+	err := WalkFS(fsys, ".", func(name string, d fs.DirEntry, target string, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if !options.match(name, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{
+			Path:    name,
+			Mode:    info.Mode(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Target:  target,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// WalkFS is like fs.WalkDir but it also passes the targets of symbolic links
+// to fn, read with the ReadLink method of fsys; the target is empty for other
+// types of files. The targets are passed as-is, including the absolute ones.
+//
+// Symbolic links are not followed, so the walk cannot enter cycles. When
+// reading the target of a symbolic link fails, fn is called with the entry and
+// the error, and the walk carries on if it returns nil, or follows the rules of
+// fs.WalkDir if it returns fs.SkipDir or fs.SkipAll.
+func WalkFS(fsys fs.FS, root string, fn func(path string, d fs.DirEntry, linkTarget string, err error) error) error {
+	return fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type() != fs.ModeSymlink {
+			return fn(name, d, "", err)
+		}
+		target, err := readLink(fsys, name)
+		return fn(name, d, target, err)
+	})
+}
+
+// FindOption configures FindFS.
+type FindOption func(*findOptions)
+
+type findOptions struct {
+	skipUnreadable bool
+}
+
+// SkipUnreadableDirs configures FindFS to skip the directories which cannot be
+// read instead of returning an error.
+func SkipUnreadableDirs() FindOption {
+	return func(opts *findOptions) { opts.skipUnreadable = true }
+}
+
+// FindFS returns the sorted list of paths of the files of fsys for which pred
+// returns true, including the root directory ".".
+//
+// The function returns the first error encountered walking fsys, unless the
+// SkipUnreadableDirs option is passed, in which case the directories which
+// cannot be read are skipped. The directories themselves are still passed to
+// pred since they exist.
+func FindFS(fsys fs.FS, pred func(path string, d fs.DirEntry) bool, opts ...FindOption) ([]string, error) {
+	options := findOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var matches []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && options.skipUnreadable {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if pred(name, d) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// DirSize returns the sum of the sizes of the regular files in the directory
+// at root of fsys and its subdirectories. The sizes of directories are not
+// counted since they depend on the platform.
+//
+// Symbolic links are not followed and count for zero, unless the
+// FollowSymlinks option is passed, in which case they count for the size of
+// the files they refer to, and the directories they refer to are walked; a
+// file referred to by multiple links is counted once per link. The Include
+// and Exclude options can be passed to filter the files; other options are
+// ignored.
+//
+// The function returns an error if any file or directory cannot be read.
+func DirSize(fsys fs.FS, root string, opts ...EqualOption) (int64, error) {
+	options := newEqualOptions(opts)
+	fsys = options.wrap(fsys)
+	size := int64(0)
+
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !options.match(name, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// FSStats is the result of Stats, describing the content of a directory tree.
+type FSStats struct {
+	// Numbers of regular files, directories, and symbolic links found under
+	// the root. The root directory is not counted.
+	Files    int
+	Dirs     int
+	Symlinks int
+	// Sum of the sizes of the regular files.
+	Bytes int64
+	// Paths of the regular files with a size of zero, in lexical order.
+	EmptyFiles []string
+	// Path of the largest regular file, the first one in lexical order if
+	// several files have the same size, or an empty string if there are no
+	// regular files.
+	LargestFile string
+}
+
+// Stats walks the directory at root of fsys in a single pass and returns the
+// numbers of files it contains by type, the sum of the sizes of the regular
+// files, and the empty and largest regular files, which saves tests from
+// writing their own fs.WalkDir loops to assert the shape of a tree.
+//
+// The paths reported are the paths of the files in fsys, including root.
+// Symbolic links are counted but not followed, and other types of files (e.g.
+// named pipes) are not counted. The walk is aborted at the first error, which
+// is returned as-is, usually naming the file or directory which could not be
+// read.
+func Stats(fsys fs.FS, root string) (FSStats, error) {
+	var stats FSStats
+	var largest int64
+
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch d.Type() {
+		case fs.ModeDir:
+			if name != root {
+				stats.Dirs++
+			}
+		case fs.ModeSymlink:
+			stats.Symlinks++
+		case 0:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size := info.Size()
+			stats.Files++
+			stats.Bytes += size
+			if size == 0 {
+				stats.EmptyFiles = append(stats.EmptyFiles, name)
+			}
+			if stats.LargestFile == "" || size > largest {
+				stats.LargestFile, largest = name, size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return FSStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,250 @@
+package fstest_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestList(t *testing.T) {
+	now := time.Now()
+
+	fsys := fstest.MapFS{
+		"a.txt":        &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: now},
+		"a":            &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: now},
+		"a/b.go":       &fstest.MapFile{Mode: 0644, Data: []byte("package b"), ModTime: now},
+		"a/link":       &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("b.go"), ModTime: now},
+		"cache":        &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: now},
+		"cache/object": &fstest.MapFile{Mode: 0600, Data: []byte("..."), ModTime: now},
+	}
+
+	entries, err := fstest.List(fsys, fstest.Exclude("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fstest.Entry{
+		{Path: "a", Mode: 0755 | fs.ModeDir, ModTime: now},
+		{Path: "a.txt", Mode: 0644, Size: 1, ModTime: now},
+		{Path: "a/b.go", Mode: 0644, Size: 9, ModTime: now},
+		{Path: "a/link", Mode: 0777 | fs.ModeSymlink, Size: 4, ModTime: now, Target: "b.go"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries mismatch:\nwant: %+v\ngot:  %+v", want, entries)
+	}
+
+	entries, err = fstest.List(fsys, fstest.Include("**/*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Path)
+	}
+	if want := []string{"a", "a/b.go", "cache"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("entries mismatch: want=%q got=%q", want, names)
+	}
+}
+
+func TestWalkFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":  &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/link":  &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+		"dir/cycle": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("..")},
+		"dir/hosts": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("/etc/hosts")},
+	}
+
+	targets := map[string]string{}
+	err := fstest.WalkFS(fsys, ".", func(name string, d fs.DirEntry, target string, err error) error {
+		if err != nil {
+			return err
+		}
+		targets[name] = target
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		".":         "",
+		"dir":       "",
+		"dir/cycle": "..",
+		"dir/file":  "",
+		"dir/hosts": "/etc/hosts",
+		"dir/link":  "file",
+	}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("targets mismatch:\nwant: %q\ngot:  %q", want, targets)
+	}
+
+	entries, err := fstest.List(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Target != want[entry.Path] {
+			t.Errorf("%s: target mismatch: want=%q got=%q", entry.Path, want[entry.Path], entry.Target)
+		}
+	}
+
+	faulty := fstest.NewFaultFS(fsys).Fail(fstest.OpReadLink, "dir/link", fs.ErrPermission)
+	var failed []string
+	err = fstest.WalkFS(faulty, ".", func(name string, d fs.DirEntry, target string, err error) error {
+		if err != nil {
+			failed = append(failed, name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []string{"dir/link"}) {
+		t.Errorf("failed paths mismatch: want=[dir/link] got=%q", failed)
+	}
+}
+
+func TestFindFS(t *testing.T) {
+	fsys := fstest.NewFaultFS(fstest.MapFS{
+		"bin":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"bin/tool":    &fstest.MapFile{Mode: 0755, Data: []byte("#!/bin/sh")},
+		"bin/README":  &fstest.MapFile{Mode: 0644, Data: []byte("tools")},
+		"private":     &fstest.MapFile{Mode: 0700 | fs.ModeDir},
+		"private/key": &fstest.MapFile{Mode: 0700},
+	}).Fail(fstest.OpReadDir, "private", fs.ErrPermission)
+	isExecutable := func(name string, d fs.DirEntry) bool {
+		info, err := d.Info()
+		return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+	}
+
+	if _, err := fstest.FindFS(fsys, isExecutable); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected fs.ErrPermission, got %v", err)
+	}
+	matches, err := fstest.FindFS(fsys, isExecutable, fstest.SkipUnreadableDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bin/tool"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches mismatch: want=%q got=%q", want, matches)
+	}
+
+	matches, err = fstest.FindFS(fsys, func(string, fs.DirEntry) bool { return true }, fstest.SkipUnreadableDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".", "bin", "bin/README", "bin/tool", "private"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches mismatch: want=%q got=%q", want, matches)
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/a":       &fstest.MapFile{Mode: 0644, Data: []byte("Hello")},
+		"dir/sub/b":   &fstest.MapFile{Mode: 0644, Data: []byte("World!")},
+		"dir/link":    &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("../other")},
+		"dir/sublink": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("sub")},
+		"other":       &fstest.MapFile{Mode: 0644, Data: []byte("0123456789")},
+	}
+
+	tests := []struct {
+		root string
+		opts []fstest.EqualOption
+		size int64
+	}{
+		{".", nil, 21},
+		{"dir", nil, 11},
+		{"dir/sub", nil, 6},
+		{"dir", []fstest.EqualOption{fstest.FollowSymlinks()}, 27},
+		{"dir", []fstest.EqualOption{fstest.Exclude("dir/sub")}, 5},
+	}
+	for _, test := range tests {
+		size, err := fstest.DirSize(fsys, test.root, test.opts...)
+		if err != nil {
+			t.Errorf("%s: %v", test.root, err)
+		} else if size != test.size {
+			t.Errorf("%s: size mismatch: want=%d got=%d", test.root, test.size, size)
+		}
+	}
+
+	if _, err := fstest.DirSize(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/a":         &fstest.MapFile{Mode: 0644, Data: []byte("Hello")},
+		"dir/empty":     &fstest.MapFile{Mode: 0644},
+		"dir/sub/b":     &fstest.MapFile{Mode: 0644, Data: []byte("World!")},
+		"dir/sub/c":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello!")},
+		"dir/sub/empty": &fstest.MapFile{Mode: 0644},
+		"dir/link":      &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("a")},
+		"other":         &fstest.MapFile{Mode: 0644, Data: []byte("0123456789")},
+	}
+
+	stats, err := fstest.Stats(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fstest.FSStats{
+		Files:       5,
+		Dirs:        1,
+		Symlinks:    1,
+		Bytes:       17,
+		EmptyFiles:  []string{"dir/empty", "dir/sub/empty"},
+		LargestFile: "dir/sub/b",
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("stats mismatch:\nwant: %+v\ngot:  %+v", want, stats)
+	}
+
+	if stats, err := fstest.Stats(fsys, "."); err != nil {
+		t.Error(err)
+	} else if stats.Dirs != 2 || stats.Files != 6 || stats.LargestFile != "other" {
+		t.Errorf("unexpected stats of the root: %+v", stats)
+	}
+	if _, err := fstest.Stats(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func ExampleFindFS_executables() {
+	fsys := fstest.MapFS{
+		"bin/tool":   &fstest.MapFile{Mode: 0755},
+		"bin/README": &fstest.MapFile{Mode: 0644},
+		"run.sh":     &fstest.MapFile{Mode: 0700},
+	}
+	matches, err := fstest.FindFS(fsys, func(name string, d fs.DirEntry) bool {
+		info, err := d.Info()
+		return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(matches)
+	// Output: [bin/tool run.sh]
+}
+
+func ExampleFindFS_empty() {
+	fsys := fstest.MapFS{
+		"data/empty": &fstest.MapFile{Mode: 0644},
+		"data/file":  &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"log":        &fstest.MapFile{Mode: 0644},
+	}
+	matches, err := fstest.FindFS(fsys, func(name string, d fs.DirEntry) bool {
+		info, err := d.Info()
+		return err == nil && info.Mode().IsRegular() && info.Size() == 0
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(matches)
+	// Output: [data/empty log]
+}
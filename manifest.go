@@ -0,0 +1,232 @@
+package fstest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// WriteManifest writes a manifest of the files of fsys to w, which can be
+// checked into version control and compared with a file system by
+// EqualManifest.
+//
+// Manifests are line-oriented, with one entry per file sorted by path, and do
+// not include the root directory. The grammar of the lines is:
+//
+//	line   = mode " " size " " path [ " -> " target ] "\n"
+//	mode   = the string form of fs.FileMode (e.g. "-rw-r--r--", "drwxr-xr-x")
+//	size   = decimal size of regular files, or "-" for other types of files
+//	path   = slash-separated path, quoted if needed (see below)
+//	target = target of symbolic links, quoted if needed
+//
+// Paths and targets are written as Go string literals when they are empty,
+// or contain spaces, double quotes, or characters which are not printable, so
+// that the lines can be parsed unambiguously. Empty lines and lines starting
+// with "#" are ignored by EqualManifest. Modification times and the content of
+// files are not part of manifests.
+func WriteManifest(w io.Writer, fsys fs.FS) error {
+	entries, err := List(fsys)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		bw.WriteString(formatManifestEntry(entry))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// EqualManifest compares the files of fsys with the entries of the manifest
+// read from r, in the format written by WriteManifest, returning nil if they
+// match, or an error listing all the differences found.
+//
+// The manifest describes the expected files: the differences are reported as
+// *EqualError values of kind Removed for the files of the manifest missing in
+// fsys, Added for the files of fsys missing in the manifest, and TypeChanged,
+// ModeChanged, SizeChanged, or SymlinkChanged for the files which differ. The
+// function returns an error without comparing the files if the manifest cannot
+// be parsed.
+func EqualManifest(fsys fs.FS, r io.Reader) error {
+	want, err := readManifest(r)
+	if err != nil {
+		return err
+	}
+	got, err := List(fsys)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, j := 0, 0; i < len(want) || j < len(got); {
+		switch {
+		case j == len(got) || (i < len(want) && want[i].Path < got[j].Path):
+			errs = append(errs, equalError(want[i].Path, mismatchf(Removed, "%s", want[i].Mode, "none", "file missing")))
+			i++
+		case i == len(want) || got[j].Path < want[i].Path:
+			errs = append(errs, equalError(got[j].Path, mismatchf(Added, "%s", "none", got[j].Mode, "file not in manifest")))
+			j++
+		default:
+			if err := equalManifestEntry(want[i], got[j]); err != nil {
+				errs = append(errs, err)
+			}
+			i++
+			j++
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func equalManifestEntry(want, got Entry) error {
+	name := want.Path
+	switch {
+	case want.Mode.Type() != got.Mode.Type():
+		return equalError(name, mismatchf(TypeChanged, "%s", want.Mode.Type(), got.Mode.Type(), "file types mismatch"))
+	case want.Mode != got.Mode:
+		return equalError(name, mismatchf(ModeChanged, "%s", want.Mode, got.Mode, "file modes mismatch"))
+	case want.Mode.IsRegular() && want.Size != got.Size:
+		return equalError(name, mismatchf(SizeChanged, "%d", want.Size, got.Size, "files sizes mismatch"))
+	case want.Target != got.Target:
+		return equalError(name, mismatchf(SymlinkChanged, "%q", want.Target, got.Target, "symbolic links mismatch"))
+	}
+	return nil
+}
+
+func formatManifestEntry(entry Entry) string {
+	size := "-"
+	if entry.Mode.IsRegular() {
+		size = strconv.FormatInt(entry.Size, 10)
+	}
+	line := entry.Mode.String() + " " + size + " " + quoteManifest(entry.Path)
+	if entry.Mode.Type() == fs.ModeSymlink {
+		line += " -> " + quoteManifest(entry.Target)
+	}
+	return line
+}
+
+func quoteManifest(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"") || strconv.Quote(s) != `"`+s+`"` {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func readManifest(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseManifestEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", lineno, err)
+		}
+		if n := len(entries); n > 0 && entries[n-1].Path >= entry.Path {
+			return nil, fmt.Errorf("manifest line %d: %q is not sorted after %q", lineno, entry.Path, entries[n-1].Path)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, s.Err()
+}
+
+func parseManifestEntry(line string) (Entry, error) {
+	var entry Entry
+	mode, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return entry, errors.New("missing size")
+	}
+	m, err := parseFileMode(mode)
+	if err != nil {
+		return entry, err
+	}
+	entry.Mode = m
+
+	size, rest, ok := strings.Cut(rest, " ")
+	if !ok {
+		return entry, errors.New("missing path")
+	}
+	if size != "-" {
+		if entry.Size, err = strconv.ParseInt(size, 10, 64); err != nil || entry.Size < 0 {
+			return entry, fmt.Errorf("invalid size: %q", size)
+		}
+	}
+
+	if entry.Path, rest, err = unquoteManifest(rest); err != nil {
+		return entry, err
+	}
+	if !fs.ValidPath(entry.Path) || entry.Path == "." {
+		return entry, fmt.Errorf("invalid path: %q", entry.Path)
+	}
+	if rest != "" {
+		target, ok := strings.CutPrefix(rest, " -> ")
+		if !ok || entry.Mode.Type() != fs.ModeSymlink {
+			return entry, fmt.Errorf("unexpected content after the path: %q", rest)
+		}
+		if entry.Target, rest, err = unquoteManifest(target); err != nil {
+			return entry, err
+		}
+		if rest != "" {
+			return entry, fmt.Errorf("unexpected content after the target: %q", rest)
+		}
+	}
+	return entry, nil
+}
+
+// unquoteManifest parses the path or target at the beginning of s, returning
+// it along with the rest of s.
+func unquoteManifest(s string) (value, rest string, err error) {
+	if strings.HasPrefix(s, `"`) {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid quoted string: %s", s)
+		}
+		value, _ = strconv.Unquote(quoted)
+		return value, s[len(quoted):], nil
+	}
+	value, rest, _ = strings.Cut(s, " ")
+	if rest != "" {
+		rest = " " + rest
+	}
+	return value, rest, nil
+}
+
+// parseFileMode parses the string form of fs.FileMode, which is a list of
+// letters representing the type bits followed by the nine permission bits.
+func parseFileMode(s string) (fs.FileMode, error) {
+	const typeLetters = "dalTLDpSugct?"
+	const permLetters = "rwxrwxrwx"
+	if len(s) < len(permLetters) {
+		return 0, fmt.Errorf("invalid mode: %q", s)
+	}
+	types, perms := s[:len(s)-len(permLetters)], s[len(s)-len(permLetters):]
+
+	var mode fs.FileMode
+	if types != "-" {
+		for _, c := range types {
+			i := strings.IndexRune(typeLetters, c)
+			if i < 0 {
+				return 0, fmt.Errorf("invalid mode: %q", s)
+			}
+			mode |= 1 << (32 - 1 - i)
+		}
+	}
+	for i, c := range perms {
+		switch c {
+		case rune(permLetters[i]):
+			mode |= 1 << (len(permLetters) - 1 - i)
+		case '-':
+		default:
+			return 0, fmt.Errorf("invalid mode: %q", s)
+		}
+	}
+	if mode.String() != s {
+		return 0, fmt.Errorf("invalid mode: %q", s)
+	}
+	return mode, nil
+}
@@ -0,0 +1,88 @@
+package fstest_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bin":             &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"bin/tool":        &fstest.MapFile{Mode: 0755 | fs.ModeSetuid, Data: []byte("#!/bin/sh")},
+		"etc/hosts":       &fstest.MapFile{Mode: 0644, Data: []byte("127.0.0.1 localhost\n")},
+		"etc/my file":     &fstest.MapFile{Mode: 0600},
+		"etc/link":        &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("my file")},
+		"etc/arrow -> no": &fstest.MapFile{Mode: 0644},
+	}
+
+	var buf bytes.Buffer
+	if err := fstest.WriteManifest(&buf, fsys); err != nil {
+		t.Fatal(err)
+	}
+	const want = `drwxr-xr-x - bin
+urwxr-xr-x 9 bin/tool
+dr-xr-xr-x - etc
+-rw-r--r-- 0 "etc/arrow -> no"
+-rw-r--r-- 20 etc/hosts
+Lrwxrwxrwx - etc/link -> "my file"
+-rw------- 0 "etc/my file"
+`
+	if buf.String() != want {
+		t.Errorf("manifest mismatch:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+	if err := fstest.EqualManifest(fsys, strings.NewReader("# comment\n\n"+buf.String())); err != nil {
+		t.Error(err)
+	}
+
+	changed := fsys.Clone()
+	changed["bin/tool"].Mode = 0700
+	changed["etc/hosts"].Data = nil
+	changed["etc/link"].Data = []byte("hosts")
+	changed["etc/new"] = &fstest.MapFile{Mode: 0644}
+	delete(changed, "etc/my file")
+
+	err := fstest.EqualManifest(changed, strings.NewReader(want))
+	for _, kind := range []error{
+		fstest.ErrModeMismatch,
+		fstest.ErrSizeMismatch,
+		fstest.ErrSymlinkMismatch,
+	} {
+		if !errors.Is(err, kind) {
+			t.Errorf("expected %v, got %v", kind, err)
+		}
+	}
+	var diffs []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			diffs = append(diffs, pathErr.Path)
+		}
+	}
+	if got := strings.Join(diffs, ","); got != "bin/tool,etc/hosts,etc/link,etc/my file,etc/new" {
+		t.Errorf("differences mismatch: %s", got)
+	}
+}
+
+func TestManifestErrors(t *testing.T) {
+	tests := []string{
+		"-rw-r--r--",
+		"-rw-r--r-- 12",
+		"-rw-r--z-- 12 file",
+		"-rw-r--r-- twelve file",
+		"-rw-r--r-- 12 ../file",
+		"-rw-r--r-- 12 \"file",
+		"-rw-r--r-- 12 file -> target",
+		"Lrwxrwxrwx - link -> target extra",
+		"-rw-r--r-- 1 b\n-rw-r--r-- 1 a",
+	}
+	for _, test := range tests {
+		if err := fstest.EqualManifest(fstest.MapFS{}, strings.NewReader(test)); err == nil || !strings.HasPrefix(err.Error(), "manifest line") {
+			t.Errorf("%q: expected a parse error, got %v", test, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"unsafe"
+)
+
+// ReadMapFS returns a MapFS holding a copy of the directory at root in fsys,
+// which can be used to freeze the state of a file system into a fixture. The
+// keys of the returned map are relative to root.
+//
+// Regular files, directories, and symbolic links are copied, along with their
+// modes and modification times; other types of files are copied without data.
+// The root directory itself is not part of the returned map.
+func ReadMapFS(fsys fs.FS, root string) (MapFS, error) {
+	mapfs := make(MapFS)
+	buf := make([]byte, equalFSBufSize)
+
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == root {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := &MapFile{Mode: info.Mode(), ModTime: modTime(info)}
+		switch d.Type() {
+		case 0: // regular
+			file.Data, err = readFile(fsys, name, info.Size(), buf)
+		case fs.ModeSymlink:
+			var link string
+			link, err = readLink(fsys, name)
+			file.Data = []byte(link)
+		}
+		if err != nil {
+			return err
+		}
+		if root != "." {
+			name = name[len(root)+1:]
+		}
+		mapfs[name] = file
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapfs, nil
+}
+
+// readFile reads the content of the named file, using buf to copy the data
+// into a slice allocated to the expected size of the file.
+func readFile(fsys fs.FS, name string, size int64, buf []byte) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, 0, size)
+	for {
+		n, err := f.Read(buf)
+		data = append(data, buf[:n]...)
+		if err != nil {
+			if err == io.EOF {
+				return data, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// Diff compares the entries of fsys and other, returning the sorted lists of
+// paths which were added in other, removed from fsys, and changed between the
+// two. An entry is considered changed when its content (or symbolic link
+// target), mode, or modification time differ.
+//
+// The comparison is made on the map keys, it does not account for directories
+// synthesized by MapFS; use EqualFS to compare the file systems that the maps
+// represent.
+func (fsys MapFS) Diff(other MapFS) (added, removed, changed []string) {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	for _, name := range sortedKeys(fsys) {
+		file, otherFile := fsys[name], other[name]
+		switch {
+		case otherFile == nil:
+			if file != nil {
+				removed = append(removed, name)
+			}
+		case file == nil:
+			added = append(added, name)
+		case !equalMapFile(file, otherFile):
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range sortedKeys(other) {
+		if fsys[name] == nil && other[name] != nil {
+			added = append(added, name)
+		}
+	}
+	return added, removed, changed
+}
+
+func equalMapFile(a, b *MapFile) bool {
+	return a.Mode == b.Mode && a.ModTime.Equal(b.ModTime) && bytes.Equal(a.Data, b.Data)
+}
+
+// Clone returns a deep copy of fsys, holding new *MapFile values with copies
+// of their Data, so that modifying the clone never affects fsys. This allows
+// deriving fixtures from a common base in subtests which modify them.
+//
+// Entries sharing the same *MapFile in fsys, like the hard links created by
+// Link, share the same copy in the clone. The MapFileSys values are copied as
+// well, with their read scripts starting over; other values of the Sys field
+// are shared with fsys.
+func (fsys MapFS) Clone() MapFS {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	if fsys == nil {
+		return nil
+	}
+	clone := make(MapFS, len(fsys))
+	files := make(map[*MapFile]*MapFile)
+	for name, file := range fsys {
+		if file == nil {
+			clone[name] = nil
+			continue
+		}
+		copied := files[file]
+		if copied == nil {
+			copied = &MapFile{
+				Data:    bytes.Clone(file.Data),
+				Mode:    file.Mode,
+				ModTime: file.ModTime,
+				Sys:     file.Sys,
+			}
+			if sys, ok := file.Sys.(*MapFileSys); ok {
+				copied.Sys = &MapFileSys{
+					Info:       sys.Info,
+					ReadScript: append([]ReadStep(nil), sys.ReadScript...),
+					Ino:        sys.Ino,
+					Uid:        sys.Uid,
+					Gid:        sys.Gid,
+					Xattrs:     cloneXattrs(sys.Xattrs),
+					implicit:   sys.implicit,
+				}
+			}
+			files[file] = copied
+		}
+		clone[name] = copied
+	}
+	return clone
+}
+
+// MergeFS returns a MapFS combining the entries of layers, which allows
+// assembling fixtures from reusable fragments. The entries of the returned map
+// are copies, so it can be modified without affecting the layers.
+//
+// Unlike OverlayFS, layers do not shadow each other: the function returns an
+// error listing the conflicts found if an entry exists in several layers with
+// different content, mode, or modification time, or if a file of a layer is
+// the parent of an entry of another layer. Directories existing in several
+// layers are merged without conflicts, using the entry of the first layer.
+func MergeFS(layers ...MapFS) (MapFS, error) {
+	merged := make(MapFS)
+	layerOf := make(map[string]int)
+	var errs []error
+
+	for i, layer := range layers {
+		layer = layer.Clone()
+		for _, name := range sortedKeys(layer) {
+			file := layer[name]
+			if file == nil {
+				continue
+			}
+			prev := merged[name]
+			switch {
+			case prev == nil:
+				merged[name], layerOf[name] = file, i
+			case prev.Mode.IsDir() && file.Mode.IsDir():
+			case !equalMapFile(prev, file):
+				errs = append(errs, mergeError(name, fmt.Errorf("%w of layer %d in layer %d", errConflict, layerOf[name], i)))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(merged) {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if parent := merged[dir]; parent != nil && !parent.Mode.IsDir() {
+				errs = append(errs, mergeError(name, fmt.Errorf("%w: %s", errParentNotDir, dir)))
+				break
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+var errConflict = errors.New("conflicting entry")
+
+func mergeError(name string, err error) error {
+	return &fs.PathError{Op: "merge", Path: name, Err: err}
+}
+
+// CheckNoAliasing verifies that the entries of a and b share no mutable state,
+// which would make them unsafe to modify independently, for example after
+// cloning a MapFS. The function returns an error listing the paths of the
+// entries of a and b which are the same *MapFile, or whose Data slices share
+// the same backing array.
+func CheckNoAliasing(a, b MapFS) error {
+	var errs []error
+	for _, nameA := range sortedKeys(a) {
+		fileA := a[nameA]
+		if fileA == nil {
+			continue
+		}
+		for _, nameB := range sortedKeys(b) {
+			fileB := b[nameB]
+			switch {
+			case fileA == fileB:
+				errs = append(errs, checkErrorf(nameA, "file aliased by %q", nameB))
+			case fileB != nil && overlap(fileA.Data, fileB.Data):
+				errs = append(errs, checkErrorf(nameA, "data aliased by %q", nameB))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overlap returns true if the backing arrays of a and b overlap, considering
+// their capacity since appending to one of the slices could modify the other.
+func overlap(a, b []byte) bool {
+	if cap(a) == 0 || cap(b) == 0 {
+		return false
+	}
+	startA := uintptr(unsafe.Pointer(unsafe.SliceData(a)))
+	startB := uintptr(unsafe.Pointer(unsafe.SliceData(b)))
+	return startA < startB+uintptr(cap(b)) && startB < startA+uintptr(cap(a))
+}
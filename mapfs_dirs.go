@@ -0,0 +1,155 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+	"testing/fstest"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// VirtualDirs returns a view of fsys where the directories synthesized by the
+// map for the files they contain, which have no entries of their own, report
+// the permission bits perm and the modification time modTime.
+//
+// By default, synthesized directories report a zero modification time, which
+// EqualFS does not compare, and the permission bits 0555, or no permission
+// bits when opened, which depend on how the directories are reached. Comparing
+// the view against a materialized tree (e.g. a directory written to disk)
+// checks the directories predictably instead.
+//
+// The view supports the Sub method, which returns views configured with the
+// same values.
+func (fsys MapFS) VirtualDirs(perm fs.FileMode, modTime time.Time) fs.FS {
+	return &virtualDirFS{fsys: fsys, dir: ".", perm: perm.Perm(), modTime: modTime}
+}
+
+type virtualDirFS struct {
+	fsys    MapFS
+	dir     string
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+func (v *virtualDirFS) fullName(name string) string {
+	return (&subFS{v.fsys, v.dir}).fullName(name)
+}
+
+// virtual returns true if the named entry is a directory synthesized by the
+// map.
+func (v *virtualDirFS) virtual(name string) bool {
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	if v.fsys[name] != nil {
+		return false
+	}
+	info, err := fstest.MapFS(v.fsys).Stat(name)
+	return err == nil && info.IsDir()
+}
+
+func (v *virtualDirFS) info(info fs.FileInfo) fs.FileInfo {
+	return virtualDirInfo{FileInfo: info, perm: v.perm, modTime: v.modTime}
+}
+
+// entries replaces the entries of the directories synthesized by the map in
+// the listing of dir.
+func (v *virtualDirFS) entries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	for i, entry := range entries {
+		if entry.IsDir() && v.virtual(path.Join(dir, entry.Name())) {
+			if info, err := entry.Info(); err == nil {
+				entries[i] = fs.FileInfoToDirEntry(v.info(info))
+			}
+		}
+	}
+	return entries
+}
+
+func (v *virtualDirFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	name = v.fullName(name)
+	f, err := v.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return f, nil
+	}
+	return &virtualDirFile{d, v, name}, nil
+}
+
+func (v *virtualDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	name = v.fullName(name)
+	entries, err := v.fsys.ReadDir(name)
+	return v.entries(name, entries), err
+}
+
+func (v *virtualDirFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return v.fsys.ReadFile(v.fullName(name))
+}
+
+func (v *virtualDirFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return v.fsys.ReadLink(v.fullName(name))
+}
+
+func (v *virtualDirFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	name = v.fullName(name)
+	info, err := v.fsys.Stat(name)
+	if err == nil && v.virtual(name) {
+		info = v.info(info)
+	}
+	return info, err
+}
+
+func (v *virtualDirFS) Sub(dir string) (fs.FS, error) {
+	info, err := v.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errNotDir}
+	}
+	return &virtualDirFS{fsys: v.fsys, dir: v.fullName(dir), perm: v.perm, modTime: v.modTime}, nil
+}
+
+var (
+	_ fs.ReadDirFS      = (*virtualDirFS)(nil)
+	_ fs.ReadFileFS     = (*virtualDirFS)(nil)
+	_ fs.StatFS         = (*virtualDirFS)(nil)
+	_ fs.SubFS          = (*virtualDirFS)(nil)
+	_ fslink.ReadLinkFS = (*virtualDirFS)(nil)
+)
+
+type virtualDirFile struct {
+	fs.ReadDirFile
+	fsys *virtualDirFS
+	name string
+}
+
+func (d *virtualDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	return d.fsys.entries(d.name, entries), err
+}
+
+func (d *virtualDirFile) Stat() (fs.FileInfo, error) {
+	info, err := d.ReadDirFile.Stat()
+	if err == nil && d.fsys.virtual(d.name) {
+		info = d.fsys.info(info)
+	}
+	return info, err
+}
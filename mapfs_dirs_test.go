@@ -0,0 +1,72 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMapFSVirtualDirs(t *testing.T) {
+	modTime := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a/b/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime},
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "b", "file"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a/b/file", "a/b", "a", "."} {
+		if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	view := fsys.VirtualDirs(0750, modTime)
+	if err := fstest.TestFS(view, "a", "a/b", "a/b/file"); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{".", "a", "a/b"} {
+		info, err := fs.Stat(view, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != fs.ModeDir|0750 || !info.ModTime().Equal(modTime) {
+			t.Errorf("%s: unexpected directory information: %v %v", name, info.Mode(), info.ModTime())
+		}
+	}
+	// The information of directories is compared at the maximum depth.
+	if err := fstest.EqualFSWith(os.DirFS(dir), view, fstest.MaxDepth(0)); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(os.DirFS(dir), fsys, fstest.MaxDepth(0)); !errors.Is(err, fstest.ErrModeMismatch) {
+		t.Errorf("expected a mode mismatch without the view, got %v", err)
+	}
+	if err := fstest.EqualFSWith(os.DirFS(dir), fsys.VirtualDirs(0700, modTime), fstest.MaxDepth(0)); !errors.Is(err, fstest.ErrModeMismatch) {
+		t.Errorf("expected a mode mismatch, got %v", err)
+	}
+	if err := fstest.EqualFSWith(os.DirFS(dir), fsys.VirtualDirs(0750, modTime.Add(time.Hour)), fstest.MaxDepth(0)); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a time mismatch, got %v", err)
+	}
+	// The times of the views are compared without system-specific
+	// information as well.
+	if err := fstest.EqualFSWith(view, fsys.VirtualDirs(0750, modTime.Add(time.Hour)), fstest.MaxDepth(0)); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a time mismatch between views, got %v", err)
+	}
+
+	sub, err := fs.Sub(view, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFSWith(os.DirFS(filepath.Join(dir, "a")), sub, fstest.MaxDepth(0)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,149 @@
+package fstest
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// MapFileSys may be set as the Sys field of a MapFile to extend the behavior
+// of MapFS for this entry.
+type MapFileSys struct {
+	// When non-nil, Info is returned by Stat and ReadDir instead of the
+	// information synthesized from the MapFile. The content of the file is
+	// still served from the MapFile's Data.
+	Info fs.FileInfo
+	// When non-empty, ReadScript describes the outcome of the first calls to
+	// Read on the file, which allows simulating transient read failures.
+	//
+	// Each step applies to one call to Read with a non-empty buffer, including
+	// the reads made by MapFS.ReadFile to load the file content. The steps
+	// are consumed across all the opened instances of the file: reopening the
+	// file resets the read offset but the script resumes where it stopped, so
+	// a file can fail when first read and succeed when reopened. Once the
+	// script is exhausted, reads are served normally. Calls to ReadAt are not
+	// affected by the script.
+	ReadScript []ReadStep
+	// When non-zero, Ino is the inode number reported for the entry, which
+	// allows modeling hard links: entries with the same inode number are
+	// considered links of the same file by CheckInodeUniqueness and the
+	// DetectHardlinks option.
+	Ino uint64
+	// Owner and group of the entry, which determine the class of permission
+	// bits applying to the users of file systems returned by MapFS.As. The
+	// entries without MapFileSys are owned by root (uid and gid zero).
+	Uid int
+	Gid int
+	// Extended attributes of the entry, exposed by the ListXattr and GetXattr
+	// methods of MapFS.
+	Xattrs map[string][]byte
+
+	mutex sync.Mutex
+	steps int
+	// implicit is set on the entries created by Chtimes for the directories
+	// synthesized by the map, which keep granting all the permissions.
+	implicit bool
+}
+
+// ReadStep is a step of the read script of a MapFileSys.
+type ReadStep struct {
+	// Maximum number of bytes of the file content returned by the read.
+	N int
+	// Error returned by the read, after the N bytes.
+	Err error
+}
+
+func (sys *MapFileSys) nextReadStep() (ReadStep, bool) {
+	sys.mutex.Lock()
+	defer sys.mutex.Unlock()
+	if sys.steps == len(sys.ReadScript) {
+		return ReadStep{}, false
+	}
+	step := sys.ReadScript[sys.steps]
+	sys.steps++
+	return step, true
+}
+
+// SetInfo installs info as the file information returned by fsys for the
+// named entry, giving full control over the values reported by Stat and
+// ReadDir, including the underlying system-specific data returned by Sys.
+func (fsys MapFS) SetInfo(name string, info fs.FileInfo) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	file := fsys[name]
+	if file == nil {
+		return &fs.PathError{Op: "setinfo", Path: name, Err: fs.ErrNotExist}
+	}
+	sys, _ := file.Sys.(*MapFileSys)
+	if sys == nil {
+		newFile := *file
+		newFile.Sys = &MapFileSys{Info: info}
+		fsys.replace(file, &newFile)
+		return nil
+	}
+	sys.Info = info
+	return nil
+}
+
+func (fsys MapFS) info(name string) fs.FileInfo {
+	if sys := fsys.sys(name); sys != nil {
+		return sys.Info
+	}
+	return nil
+}
+
+func (fsys MapFS) sys(name string) *MapFileSys {
+	if file := fsys[name]; file != nil {
+		sys, _ := file.Sys.(*MapFileSys)
+		return sys
+	}
+	return nil
+}
+
+type infoFile struct {
+	fs.File
+	info fs.FileInfo
+}
+
+func (f *infoFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *infoFile) ReadAt(b []byte, offset int64) (int, error) {
+	return readFileAt(f.File, b, offset)
+}
+
+func (f *infoFile) Seek(offset int64, whence int) (int64, error) {
+	return seekFile(f.File, offset, whence)
+}
+
+type scriptFile struct {
+	fs.File
+	sys *MapFileSys
+}
+
+func (f *scriptFile) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return f.File.Read(b)
+	}
+	step, ok := f.sys.nextReadStep()
+	if !ok {
+		return f.File.Read(b)
+	}
+	n := 0
+	if step.N > 0 {
+		var err error
+		if step.N < len(b) {
+			b = b[:step.N]
+		}
+		if n, err = f.File.Read(b); err != nil {
+			return n, err
+		}
+	}
+	return n, step.Err
+}
+
+func (f *scriptFile) ReadAt(b []byte, offset int64) (int, error) {
+	return readFileAt(f.File, b, offset)
+}
+
+func (f *scriptFile) Seek(offset int64, whence int) (int64, error) {
+	return seekFile(f.File, offset, whence)
+}
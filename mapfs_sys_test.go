@@ -0,0 +1,110 @@
+package fstest_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+type customInfo struct{ sys any }
+
+func (customInfo) Name() string       { return "file" }
+func (customInfo) Size() int64        { return 42 }
+func (customInfo) Mode() fs.FileMode  { return 0600 }
+func (customInfo) ModTime() time.Time { return time.Unix(42, 0) }
+func (customInfo) IsDir() bool        { return false }
+func (i customInfo) Sys() any         { return i.sys }
+
+func TestMapFSSetInfo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	info := customInfo{sys: "blocks=8"}
+	if err := fsys.SetInfo("file", info); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.SetInfo("missing", info); err == nil {
+		t.Error("expected an error setting the info of a missing file")
+	}
+
+	s, err := fs.Stat(fsys, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != fs.FileInfo(info) {
+		t.Errorf("stat returned the wrong file information: %v", s)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("wrong number of entries: %d", len(entries))
+	}
+	if s, err := entries[0].Info(); err != nil || s.Sys() != "blocks=8" {
+		t.Errorf("directory entry returned the wrong file information: %v", s)
+	}
+
+	f, err := fsys.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if s, err := f.Stat(); err != nil || s.Size() != 42 {
+		t.Errorf("file returned the wrong file information: %v", s)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Hello World!" {
+		t.Errorf("file content mismatch: %q", data)
+	}
+}
+
+func TestMapFSReadScript(t *testing.T) {
+	errTransient := errors.New("transient")
+
+	newFS := func(script ...fstest.ReadStep) fstest.MapFS {
+		return fstest.MapFS{
+			"file": &fstest.MapFile{
+				Mode: 0644,
+				Data: []byte("Hello World!"),
+				Sys:  &fstest.MapFileSys{ReadScript: script},
+			},
+		}
+	}
+
+	fsys := newFS(fstest.ReadStep{N: 5}, fstest.ReadStep{Err: errTransient})
+	b, err := fs.ReadFile(fsys, "file")
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("expected the scripted error, got %v", err)
+	}
+	if string(b) != "Hello" {
+		t.Errorf("content mismatch before the error: want=%q got=%q", "Hello", b)
+	}
+	b, err = fs.ReadFile(fsys, "file")
+	if err != nil {
+		t.Fatalf("expected the read to succeed once reopened, got %v", err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("content mismatch after reopening: want=%q got=%q", "Hello World!", b)
+	}
+
+	a := newFS(fstest.ReadStep{N: 5, Err: errTransient})
+	if err := fstest.EqualFS(a, newFS(fstest.ReadStep{N: 5, Err: errTransient})); err != nil {
+		t.Errorf("expected the file systems to fail identically: %v", err)
+	}
+	a = newFS(fstest.ReadStep{N: 5, Err: errTransient})
+	var equalErr *fstest.EqualError
+	if err := fstest.EqualFS(a, newFS()); !errors.As(err, &equalErr) || equalErr.Kind != fstest.ErrorChanged {
+		t.Errorf("expected an error difference, got %v", err)
+	}
+}
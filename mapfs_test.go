@@ -0,0 +1,419 @@
+package fstest_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMapFSDiff(t *testing.T) {
+	a := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/mode":    &fstest.MapFile{Mode: 0644},
+		"dir/time":    &fstest.MapFile{Mode: 0644},
+		"dir/symlink": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("file")},
+		"removed":     &fstest.MapFile{Mode: 0644},
+	}
+
+	b := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World?")},
+		"dir/mode":    &fstest.MapFile{Mode: 0600},
+		"dir/time":    &fstest.MapFile{Mode: 0644, ModTime: time.Now()},
+		"dir/symlink": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("mode")},
+		"added":       &fstest.MapFile{Mode: 0644},
+	}
+
+	added, removed, changed := a.Diff(b)
+
+	if want := []string{"added"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added mismatch: want=%q got=%q", want, added)
+	}
+	if want := []string{"removed"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed mismatch: want=%q got=%q", want, removed)
+	}
+	if want := []string{"dir/file", "dir/mode", "dir/symlink", "dir/time"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed mismatch: want=%q got=%q", want, changed)
+	}
+
+	if added, removed, changed := a.Diff(a); added != nil || removed != nil || changed != nil {
+		t.Errorf("unexpected differences: added=%q removed=%q changed=%q", added, removed, changed)
+	}
+}
+
+func TestMapFSClone(t *testing.T) {
+	base := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dir/file")},
+	}
+	if err := base.Link("dir/file", "hardlink"); err != nil {
+		t.Fatal(err)
+	}
+	fresh := base.Clone()
+
+	clone := base.Clone()
+	if err := fstest.CheckNoAliasing(base, clone); err != nil {
+		t.Fatal(err)
+	}
+	if clone["dir/file"] != clone["hardlink"] {
+		t.Error("hard links are not preserved in the clone")
+	}
+
+	clone["dir/file"].Data[0] = 'h'
+	clone["link"].Mode = 0644
+	delete(clone, "dir")
+	if err := fstest.EqualFS(base, fresh); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFS(base, clone); err == nil {
+		t.Error("expected the modified clone to differ")
+	}
+}
+
+func TestMergeFS(t *testing.T) {
+	base := fstest.MapFS{
+		"etc":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"etc/hosts":    &fstest.MapFile{Mode: 0644, Data: []byte("localhost")},
+		"etc/app":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"etc/app/base": &fstest.MapFile{Mode: 0644, Data: []byte("base")},
+	}
+	app := fstest.MapFS{
+		"etc":          &fstest.MapFile{Mode: 0700 | fs.ModeDir},
+		"etc/hosts":    &fstest.MapFile{Mode: 0644, Data: []byte("localhost")},
+		"etc/app/main": &fstest.MapFile{Mode: 0644, Data: []byte("main")},
+		"bin/app":      &fstest.MapFile{Mode: 0755, Data: []byte("app")},
+	}
+
+	merged, err := fstest.MergeFS(base, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := fstest.MapFS{
+		"etc":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"etc/hosts":    &fstest.MapFile{Mode: 0644, Data: []byte("localhost")},
+		"etc/app":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"etc/app/base": &fstest.MapFile{Mode: 0644, Data: []byte("base")},
+		"etc/app/main": &fstest.MapFile{Mode: 0644, Data: []byte("main")},
+		"bin/app":      &fstest.MapFile{Mode: 0755, Data: []byte("app")},
+	}
+	if err := fstest.EqualFS(merged, golden); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.CheckNoAliasing(merged, base); err != nil {
+		t.Error(err)
+	}
+
+	conflict := fstest.MapFS{
+		"etc/hosts": &fstest.MapFile{Mode: 0644, Data: []byte("example.com")},
+		"bin":       &fstest.MapFile{Mode: 0644},
+	}
+	_, err = fstest.MergeFS(base, app, conflict)
+	var paths []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		paths = append(paths, err.(*fs.PathError).Path)
+	}
+	if want := []string{"etc/hosts", "bin/app"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("conflicting paths mismatch: want=%q got=%q", want, paths)
+	}
+}
+
+func TestCheckNoAliasing(t *testing.T) {
+	data := []byte("Hello World!")
+	shared := &fstest.MapFile{Mode: 0644, Data: []byte("shared")}
+
+	a := fstest.MapFS{
+		"file":   &fstest.MapFile{Mode: 0644, Data: data},
+		"other":  &fstest.MapFile{Mode: 0644, Data: []byte("other")},
+		"shared": shared,
+	}
+	b := fstest.MapFS{
+		"file":   &fstest.MapFile{Mode: 0644, Data: bytes.Clone(data)},
+		"other":  &fstest.MapFile{Mode: 0644, Data: []byte("other")},
+		"shared": &fstest.MapFile{Mode: 0644, Data: []byte("shared")},
+	}
+	if err := fstest.CheckNoAliasing(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	b["file"].Data = data[6:]
+	b["shared"] = shared
+	err := fstest.CheckNoAliasing(a, b)
+	if err == nil {
+		t.Fatal("expected aliasing to be detected")
+	}
+	var paths []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		paths = append(paths, err.(*fs.PathError).Path)
+	}
+	if want := []string{"file", "shared"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("aliased paths mismatch: want=%q got=%q", want, paths)
+	}
+}
+
+func TestReadMapFS(t *testing.T) {
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("0123456789"), 10000)
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "empty"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "large"), large, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/large", filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+
+	fsys := os.DirFS(dir)
+	snapshot, err := fstest.ReadMapFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(fsys, snapshot); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(snapshot["sub/large"].Data, large) {
+		t.Error("content of the large file mismatch")
+	}
+	if link := string(snapshot["link"].Data); link != "sub/large" {
+		t.Errorf("symbolic link target mismatch: %q", link)
+	}
+
+	sub, err := fstest.ReadMapFS(fsys, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sub["large"]; !ok {
+		t.Error("the keys of the snapshot are not relative to the root")
+	}
+	if err := fstest.EqualFS(os.DirFS(filepath.Join(dir, "sub")), sub); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapFSSub(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir[1]/a.txt":     &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir[1]/b.txt":     &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"dir[1]/sub":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir[1]/sub/c.txt": &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+		"dir[1]/link":      &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("a.txt")},
+		"dir1/d.txt":       &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+	}
+	sub, err := fs.Sub(fsys, "dir[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := sub.(fs.GlobFS).Glob("*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches mismatch: want=%q got=%q", want, matches)
+	}
+	if _, err := fs.Glob(sub, "["); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("expected path.ErrBadPattern, got %v", err)
+	}
+
+	info, err := sub.(fs.StatFS).Stat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() || info.Size() != 1 {
+		t.Errorf("expected the symbolic link to be followed, got %v", info.Mode())
+	}
+	if data, err := sub.(fs.ReadFileFS).ReadFile("sub/c.txt"); err != nil || string(data) != "C" {
+		t.Errorf("unexpected content: %q (%v)", data, err)
+	}
+	if entries, err := sub.(fs.ReadDirFS).ReadDir("."); err != nil || len(entries) != 4 {
+		t.Errorf("unexpected entries: %v (%v)", entries, err)
+	}
+
+	nested, err := fs.Sub(sub, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fs.Glob(nested, "*"); err != nil || !reflect.DeepEqual(matches, []string{"c.txt"}) {
+		t.Errorf("unexpected matches: %q (%v)", matches, err)
+	}
+	if err := fstest.TestFS(sub, "a.txt", "b.txt", "sub/c.txt", "link"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapFSSubErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	_, err := fsys.Sub("dir/file")
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Op != "sub" || pathErr.Path != "dir/file" {
+		t.Errorf("expected an error for the file target, got %v", err)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the file target to exist, got %v", err)
+	}
+
+	_, err = fsys.Sub("dir/missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for the missing target, got %v", err)
+	}
+	if !errors.As(err, &pathErr) || pathErr.Op != "sub" || pathErr.Path != "dir/missing" {
+		t.Errorf("expected an error naming the missing target, got %v", err)
+	}
+
+	sub, err := fs.Sub(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Sub(sub, "file"); err == nil {
+		t.Error("expected an error for the file target of a sub file system")
+	}
+}
+
+func TestMapFSSeek(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"info":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"script": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), Sys: &fstest.MapFileSys{ReadScript: []fstest.ReadStep{{N: 1}}}},
+		"denied": &fstest.MapFile{Mode: 0200, Data: []byte("Hello World!")},
+	}
+	info, _ := fs.Stat(fsys, "info")
+	fsys.SetInfo("info", info)
+
+	for _, name := range []string{"file", "info", "script"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := fsys.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			s, ok := f.(io.ReadSeeker)
+			if !ok {
+				t.Fatal("file does not implement io.Seeker")
+			}
+			if off, err := s.Seek(-6, io.SeekEnd); err != nil || off != 6 {
+				t.Fatalf("seek end: %d (%v)", off, err)
+			}
+			if off, err := s.Seek(1, io.SeekCurrent); err != nil || off != 7 {
+				t.Fatalf("seek current: %d (%v)", off, err)
+			}
+			if b, err := io.ReadAll(s); err != nil || string(b) != "orld!" {
+				t.Errorf("read after seek: %q (%v)", b, err)
+			}
+
+			r, ok := f.(io.ReaderAt)
+			if !ok {
+				t.Fatal("file does not implement io.ReaderAt")
+			}
+			b := make([]byte, 5)
+			if n, err := r.ReadAt(b, 0); err != nil || string(b[:n]) != "Hello" {
+				t.Errorf("read at: %q (%v)", b[:n], err)
+			}
+		})
+	}
+
+	f, err := fsys.Open("denied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if off, err := f.(io.Seeker).Seek(0, io.SeekEnd); err != nil || off != 12 {
+		t.Errorf("seek end: %d (%v)", off, err)
+	}
+	if _, err := f.(io.ReaderAt).ReadAt(make([]byte, 5), 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected a permission error, got %v", err)
+	}
+}
+
+func TestMapFSReadDirPages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"dir/a":       &fstest.MapFile{Mode: 0644},
+		"dir/b":       &fstest.MapFile{Mode: 0644},
+		"dir/c":       &fstest.MapFile{Mode: 0644},
+		"dir/d":       &fstest.MapFile{Mode: 0644},
+		"dir/e":       &fstest.MapFile{Mode: 0644},
+		"virtual/a":   &fstest.MapFile{Mode: 0644},
+		"virtual/b":   &fstest.MapFile{Mode: 0644},
+		"virtual/c/d": &fstest.MapFile{Mode: 0644},
+	}
+	info, _ := fs.Stat(fsys, "dir")
+	fsys.SetInfo("dir", info)
+	info, _ = fs.Stat(fsys, "dir/c")
+	fsys.SetInfo("dir/c", timesInfo{info, time.Time{}, time.Time{}, time.Time{}})
+
+	for _, test := range []struct {
+		dir   string
+		pages [][]string
+	}{
+		{"dir", [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"virtual", [][]string{{"a", "b"}, {"c"}}},
+	} {
+		t.Run(test.dir, func(t *testing.T) {
+			f, err := fsys.Open(test.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			d, ok := f.(fs.ReadDirFile)
+			if !ok {
+				t.Fatal("directory does not implement fs.ReadDirFile")
+			}
+			for _, page := range test.pages {
+				entries, err := d.ReadDir(2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				if !reflect.DeepEqual(names, page) {
+					t.Errorf("unexpected page: %q, want %q", names, page)
+				}
+			}
+			if entries, err := d.ReadDir(2); len(entries) != 0 || err != io.EOF {
+				t.Errorf("expected io.EOF, got %d entries (%v)", len(entries), err)
+			}
+		})
+	}
+
+	// The entries listed by the directory file are consistent with ReadDir.
+	want, err := fs.ReadDir(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Open("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := f.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		wantInfo, _ := want[i].Info()
+		gotInfo, _ := got[i].Info()
+		if !reflect.DeepEqual(wantInfo, gotInfo) {
+			t.Errorf("%s: entry information mismatch: %v != %v", want[i].Name(), wantInfo, gotInfo)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package fstest
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// As returns a view of fsys as seen by a process running with the user id uid
+// and group id gid, which is denied access to the files according to their
+// permission bits.
+//
+// The owner of entries is configured by the Uid and Gid fields of MapFileSys.
+// The owner permission bits apply when uid is the owner of the entry, the
+// group bits when gid is its group, and the other bits otherwise. Reading
+// files and listing directories require the read permission, and accessing
+// the entries of directories requires the execute (search) permission on all
+// their parent directories. Like on Unix systems, uid zero is granted all the
+// permissions.
+//
+// Directories synthesized by the map do not have permission bits and are
+// always accessible. Without As, MapFS applies the owner permission bits.
+func (fsys MapFS) As(uid, gid int) fs.FS {
+	return &userFS{fsys, uid, gid}
+}
+
+type userFS struct {
+	fsys MapFS
+	uid  int
+	gid  int
+}
+
+// access returns true if the user is granted the permissions perm, which is a
+// combination of the bits 4 (read), 2 (write), and 1 (execute), on the named
+// entry of the given mode.
+func (u *userFS) access(name string, mode fs.FileMode, perm fs.FileMode) bool {
+	if u.uid == 0 {
+		return true
+	}
+	uid, gid := 0, 0
+	if sys := u.fsys.sys(name); sys != nil {
+		uid, gid = sys.Uid, sys.Gid
+	}
+	bits := mode.Perm()
+	switch {
+	case u.uid == uid:
+		bits >>= 6
+	case u.gid == gid:
+		bits >>= 3
+	}
+	return bits&perm == perm
+}
+
+// accessFunc is the signature of functions returning true if the permissions
+// perm are granted on the named entry of the given mode.
+type accessFunc func(name string, mode, perm fs.FileMode) bool
+
+// ownerAccess grants the permissions of the owner of entries, which is how
+// MapFS checks permissions when it is not accessed through As.
+func ownerAccess(_ string, mode, perm fs.FileMode) bool {
+	return (mode.Perm()>>6)&perm == perm
+}
+
+// search returns an error if access does not grant the execute permission on
+// one of the parent directories of name. Invalid names are left for the
+// caller to report. The read lock must be held by the caller.
+func (fsys MapFS) search(op, name string, access accessFunc) error {
+	if !fs.ValidPath(name) {
+		return nil
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if file := fsys[dir]; file != nil && file.Mode.IsDir() && !access(dir, file.Mode, 1) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+	return nil
+}
+
+func (u *userFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return u.fsys.open(name, u.access)
+}
+
+func (u *userFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return u.fsys.readDir(name, u.access)
+}
+
+func (u *userFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return u.fsys.readLink(name, u.access)
+}
+
+func (u *userFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	return u.fsys.stat(name, u.access)
+}
+
+// check returns an error if access does not grant the permissions perm on the
+// named entry, or the permission to search its parent directories. The mode
+// installed by SetInfo takes precedence over the mode of the map entry, and
+// directories synthesized by the map grant all the permissions. The read lock
+// must be held by the caller.
+func (fsys MapFS) check(op, name string, perm fs.FileMode, access accessFunc) error {
+	if err := fsys.search(op, name, access); err != nil {
+		return err
+	}
+	if file := fsys[name]; file != nil && perm != 0 && fs.ValidPath(name) {
+		mode := file.Mode
+		if info := fsys.info(name); info != nil {
+			mode = info.Mode()
+		}
+		if !access(name, mode, perm) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+	return nil
+}
+
+var (
+	_ fs.ReadDirFS      = (*userFS)(nil)
+	_ fs.StatFS         = (*userFS)(nil)
+	_ fslink.ReadLinkFS = (*userFS)(nil)
+)
@@ -0,0 +1,210 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMapFSAs(t *testing.T) {
+	owner := func(uid, gid int) *fstest.MapFileSys {
+		return &fstest.MapFileSys{Uid: uid, Gid: gid}
+	}
+	fsys := fstest.MapFS{
+		"home":            &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"home/alice":      &fstest.MapFile{Mode: 0750 | fs.ModeDir, Sys: owner(1000, 100)},
+		"home/alice/note": &fstest.MapFile{Mode: 0640, Data: []byte("note"), Sys: owner(1000, 100)},
+		"home/alice/key":  &fstest.MapFile{Mode: 0600, Data: []byte("key"), Sys: owner(1000, 100)},
+		"home/shared":     &fstest.MapFile{Mode: 0711 | fs.ModeDir, Sys: owner(1000, 100)},
+		"home/shared/doc": &fstest.MapFile{Mode: 0644, Data: []byte("doc"), Sys: owner(1000, 100)},
+		"etc/shadow":      &fstest.MapFile{Mode: 0640, Data: []byte("root")},
+	}
+
+	tests := []struct {
+		uid, gid int
+		readable []string
+		denied   []string
+	}{
+		{
+			uid: 1000, gid: 100,
+			readable: []string{"home/alice/note", "home/alice/key", "home/shared/doc"},
+			denied:   []string{"etc/shadow"},
+		},
+		{
+			uid: 1001, gid: 100,
+			readable: []string{"home/alice/note", "home/shared/doc"},
+			denied:   []string{"home/alice/key", "etc/shadow"},
+		},
+		{
+			uid: 1002, gid: 200,
+			readable: []string{"home/shared/doc"},
+			denied:   []string{"home/alice/note", "home/alice/key", "etc/shadow"},
+		},
+		{
+			uid: 0, gid: 0,
+			readable: []string{"home/alice/note", "home/alice/key", "home/shared/doc", "etc/shadow"},
+		},
+	}
+	for _, test := range tests {
+		user := fsys.As(test.uid, test.gid)
+		for _, name := range test.readable {
+			if _, err := fs.ReadFile(user, name); err != nil {
+				t.Errorf("%d:%d: %s: %v", test.uid, test.gid, name, err)
+			}
+		}
+		for _, name := range test.denied {
+			if _, err := fs.ReadFile(user, name); !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("%d:%d: %s: expected fs.ErrPermission, got %v", test.uid, test.gid, name, err)
+			}
+		}
+	}
+
+	other := fsys.As(1002, 200)
+	if _, err := fs.ReadDir(other, "home/shared"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected fs.ErrPermission listing a directory without read permission, got %v", err)
+	}
+	if _, err := fs.Stat(other, "home/alice/note"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected fs.ErrPermission accessing a directory without search permission, got %v", err)
+	}
+	if entries, err := fs.ReadDir(fsys.As(1001, 100), "home/alice"); err != nil || len(entries) != 2 {
+		t.Errorf("unexpected entries: %v (%v)", entries, err)
+	}
+
+	// Without As, the owner permission bits apply.
+	if _, err := fs.ReadFile(fsys, "etc/shadow"); err != nil {
+		t.Error(err)
+	}
+
+	// The permissions of the user apply to all the operations, even when the
+	// owner of the entries would be denied access.
+	team := fstest.MapFS{
+		"team":      &fstest.MapFile{Mode: 0070 | fs.ModeDir, Sys: owner(1000, 100)},
+		"team/plan": &fstest.MapFile{Mode: 0040, Data: []byte("plan"), Sys: owner(1000, 100)},
+		"team/link": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("plan"), Sys: owner(1000, 100)},
+	}
+	for _, user := range []fs.FS{team.As(1001, 100), team.As(0, 0)} {
+		if _, err := fs.Stat(user, "team/plan"); err != nil {
+			t.Errorf("stat: %v", err)
+		}
+		if entries, err := fs.ReadDir(user, "team"); err != nil || len(entries) != 2 {
+			t.Errorf("readdir: unexpected entries: %v (%v)", entries, err)
+		}
+		if target, err := fslink.ReadLink(user, "team/link"); err != nil || target != "plan" {
+			t.Errorf("readlink: unexpected target: %q (%v)", target, err)
+		}
+		if data, err := fs.ReadFile(user, "team/plan"); err != nil || string(data) != "plan" {
+			t.Errorf("read: unexpected content: %q (%v)", data, err)
+		}
+	}
+	if _, err := fs.Stat(team.As(1000, 100), "team/plan"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected fs.ErrPermission for the owner, got %v", err)
+	}
+}
+
+func TestMapFSPermissions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"private":      &fstest.MapFile{Mode: 0600 | fs.ModeDir},
+		"private/file": &fstest.MapFile{Mode: 0644, Data: []byte("secret")},
+		"readonly":     &fstest.MapFile{Mode: 0555 | fs.ModeDir},
+		"readonly/doc": &fstest.MapFile{Mode: 0444, Data: []byte("doc")},
+	}
+
+	if _, err := fsys.Open("private/file"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("open: expected fs.ErrPermission, got %v", err)
+	}
+	if _, err := fs.Stat(fsys, "private/file"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("stat: expected fs.ErrPermission, got %v", err)
+	}
+	if _, err := fs.ReadFile(fsys, "private/file"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("read: expected fs.ErrPermission, got %v", err)
+	}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			_, err = fs.ReadFile(fsys, path)
+		}
+		return err
+	})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("walk: expected fs.ErrPermission, got %v", err)
+	}
+
+	if err := fsys.WriteFile("readonly/new", nil, 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("create: expected fs.ErrPermission, got %v", err)
+	}
+	if err := fsys.WriteFile("readonly/doc", []byte("changed"), 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("write: expected fs.ErrPermission, got %v", err)
+	}
+	if err := fsys.Remove("readonly/doc"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("remove: expected fs.ErrPermission, got %v", err)
+	}
+	if err := fsys.Rename("readonly/doc", "doc"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("rename: expected fs.ErrPermission, got %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "readonly/doc"); err != nil || string(data) != "doc" {
+		t.Errorf("unexpected content: %q (%v)", data, err)
+	}
+
+	fsys["private"].Mode = 0700 | fs.ModeDir
+	if _, err := fs.ReadFile(fsys, "private/file"); err != nil {
+		t.Error(err)
+	}
+
+	if err := fsys.Chmod("readonly", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if fsys["readonly"].Mode != 0755|fs.ModeDir {
+		t.Errorf("unexpected mode after chmod: %v", fsys["readonly"].Mode)
+	}
+	if err := fsys.WriteFile("readonly/new", nil, 0644); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapFSDenyRead(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file":       &fstest.MapFile{Mode: 0200, Data: []byte("secret")},
+		"hidden":     &fstest.MapFile{Mode: 0300 | fs.ModeDir},
+		"hidden/doc": &fstest.MapFile{Mode: 0644, Data: []byte("doc")},
+		"listed":     &fstest.MapFile{Mode: 0400 | fs.ModeDir},
+	}
+
+	f, err := fsys.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("read file: expected fs.ErrPermission, got %v", err)
+	}
+	if _, ok := f.(fs.ReadDirFile); ok {
+		t.Error("regular file denying reads implements fs.ReadDirFile")
+	}
+
+	d, err := fsys.Open("hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.(fs.ReadDirFile).ReadDir(-1); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("readdir: expected fs.ErrPermission, got %v", err)
+	}
+	if _, err := d.Read(make([]byte, 1)); err == nil || errors.Is(err, fs.ErrPermission) {
+		t.Errorf("read directory: expected the error of reading a directory, got %v", err)
+	}
+	// The directory can be searched even if it cannot be listed.
+	if data, err := fs.ReadFile(fsys, "hidden/doc"); err != nil || string(data) != "doc" {
+		t.Errorf("unexpected content: %q (%v)", data, err)
+	}
+
+	d, err = fsys.Open("listed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.(fs.ReadDirFile).ReadDir(-1); err != nil {
+		t.Errorf("readdir: %v", err)
+	}
+}
@@ -0,0 +1,439 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// Mkdir creates a new directory with the given name and permission bits.
+//
+// Similarly to os.Mkdir, the parent directory must exist, and the function
+// returns an error wrapping fs.ErrExist if the name already exists.
+func (fsys MapFS) Mkdir(name string, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.mkdir(name, perm, time.Now)
+}
+
+func (fsys MapFS) mkdir(name string, perm fs.FileMode, now func() time.Time) error {
+	if err := fsys.checkCreate("mkdir", name); err != nil {
+		return err
+	}
+	if _, err := fstest.MapFS(fsys).Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	fsys[name] = &MapFile{Mode: fs.ModeDir | perm.Perm(), ModTime: now()}
+	return nil
+}
+
+// MkdirAll creates the directory with the given name, along with the parent
+// directories which do not exist, using the permission bits perm for all the
+// directories it creates.
+//
+// Similarly to os.MkdirAll, the function returns nil if the directory already
+// exists, including directories synthesized by the map, and an error if the
+// name or one of its parents exists and is not a directory.
+func (fsys MapFS) MkdirAll(name string, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.mkdirAll(name, perm, time.Now)
+}
+
+func (fsys MapFS) mkdirAll(name string, perm fs.FileMode, now func() time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if info, err := fstest.MapFS(fsys).Stat(name); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+		return nil
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := fsys.mkdirAll(dir, perm, now); err != nil {
+			return err
+		}
+	}
+	return fsys.mkdir(name, perm, now)
+}
+
+// WriteFile writes data to the named file, creating it with the permission
+// bits perm if it does not exist.
+//
+// Similarly to os.WriteFile, the parent directory must exist, and the content
+// of existing files is replaced but their permissions are left unchanged. The
+// data is copied so the caller can reuse the slice after the function returns.
+func (fsys MapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.writeFile(name, data, perm, time.Now)
+}
+
+func (fsys MapFS) writeFile(name string, data []byte, perm fs.FileMode, now func() time.Time) error {
+	if err := fsys.checkCreate("write", name); err != nil {
+		return err
+	}
+	data = append([]byte{}, data...)
+	file := fsys[name]
+	switch {
+	case file == nil:
+		if _, err := fstest.MapFS(fsys).Stat(name); err == nil {
+			return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+		}
+		fsys[name] = &MapFile{Data: data, Mode: perm.Perm(), ModTime: now()}
+	case !ownerAccess(name, file.Mode, 2):
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrPermission}
+	case file.Mode.IsRegular():
+		newFile := *file
+		newFile.Data, newFile.ModTime = data, now()
+		fsys.replace(file, &newFile)
+	default:
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// Symlink creates newname as a symbolic link to oldname, with the permission
+// bits 0777.
+//
+// Similarly to os.Symlink, the target does not need to exist, the parent of
+// newname must exist, and the function returns an error wrapping fs.ErrExist
+// if newname already exists.
+func (fsys MapFS) Symlink(oldname, newname string) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.symlink(oldname, newname, time.Now)
+}
+
+func (fsys MapFS) symlink(oldname, newname string, now func() time.Time) error {
+	if err := fsys.checkLink("symlink", oldname, newname); err != nil {
+		return err
+	}
+	fsys[newname] = &MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte(oldname), ModTime: now()}
+	return nil
+}
+
+// Link creates newname as a hard link to the oldname file, which is modeled by
+// sharing the *MapFile of oldname, so changes made to the entry of one of the
+// names are visible through the other.
+//
+// Similarly to os.Link, oldname must exist and cannot be a directory, the
+// parent of newname must exist, and the function returns an error wrapping
+// fs.ErrExist if newname already exists.
+func (fsys MapFS) Link(oldname, newname string) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	if err := fsys.checkLink("link", oldname, newname); err != nil {
+		return err
+	}
+	info, err := fstest.MapFS(fsys).Stat(oldname)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	fsys[newname] = fsys[oldname]
+	return nil
+}
+
+func (fsys MapFS) checkLink(op, oldname, newname string) error {
+	if err := fsys.checkCreate(op, newname); err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: errors.Unwrap(err)}
+	}
+	if _, err := fstest.MapFS(fsys).Stat(newname); err == nil || fsys[newname] != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+//
+// Similarly to os.Remove, the function returns an error wrapping fs.ErrNotExist
+// if the name does not exist, and an error if it is a directory which is not
+// empty, including directories synthesized by the map for the files they
+// contain.
+func (fsys MapFS) Remove(name string) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.checkParent("remove", name); err != nil {
+		return err
+	}
+	if _, err := fstest.MapFS(fsys).Stat(name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if fsys.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: errDirNotEmpty}
+	}
+	delete(fsys, name)
+	return nil
+}
+
+// RemoveAll removes the named file or directory and all the files it
+// contains.
+//
+// Similarly to os.RemoveAll, the function returns nil if the name does not
+// exist.
+func (fsys MapFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	if _, err := fstest.MapFS(fsys).Stat(name); err != nil {
+		return nil
+	}
+	if err := fsys.checkParent("removeall", name); err != nil {
+		return err
+	}
+	prefix := name + "/"
+	for key := range fsys {
+		if key == name || strings.HasPrefix(key, prefix) {
+			delete(fsys, key)
+		}
+	}
+	return nil
+}
+
+// Rename renames (moves) oldpath to newpath. When oldpath is a directory, all
+// the files it contains are moved as well.
+//
+// Similarly to os.Rename, the parent of newpath must exist, and an existing
+// file at newpath is replaced. A directory can replace an empty directory, but
+// not a file or a directory which is not empty, and cannot be moved into
+// itself. The function returns an error wrapping fs.ErrNotExist if oldpath
+// does not exist.
+func (fsys MapFS) Rename(oldpath, newpath string) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	if !fs.ValidPath(oldpath) || oldpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if err := fsys.checkParent("rename", oldpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.Unwrap(err)}
+	}
+	oldInfo, err := fstest.MapFS(fsys).Stat(oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if err := fsys.checkCreate("rename", newpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.Unwrap(err)}
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if newInfo, err := fstest.MapFS(fsys).Stat(newpath); err == nil {
+		switch {
+		case oldInfo.IsDir() && !newInfo.IsDir():
+			err = errNotDir
+		case !oldInfo.IsDir() && newInfo.IsDir():
+			err = errIsDirectory
+		case newInfo.IsDir() && fsys.hasChildren(newpath):
+			err = errDirNotEmpty
+		}
+		if err != nil {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+		}
+	}
+	if strings.HasPrefix(newpath, oldpath+"/") {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	// The keys are collected before the entries are moved since keys inserted
+	// while iterating over the map may or may not be visited.
+	var keys []string
+	for key := range fsys {
+		if key == oldpath || strings.HasPrefix(key, oldpath+"/") {
+			keys = append(keys, key)
+		}
+	}
+	delete(fsys, newpath)
+	for _, key := range keys {
+		fsys[newpath+key[len(oldpath):]] = fsys[key]
+		delete(fsys, key)
+	}
+	return nil
+}
+
+func (fsys MapFS) hasChildren(name string) bool {
+	prefix := name + "/"
+	for key := range fsys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errDirNotEmpty = errors.New("directory not empty")
+	errIsDirectory = errors.New("is a directory")
+)
+
+// Chtimes changes the modification time of the named file. MapFile does not
+// carry access times, so atime is ignored.
+//
+// Directories synthesized by the map are materialized as entries of the map to
+// record their modification time.
+func (fsys MapFS) Chtimes(name string, atime, mtime time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	file := fsys[name]
+	if file == nil {
+		info, err := fstest.MapFS(fsys).Stat(name)
+		if err != nil {
+			return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+		}
+		// The entry keeps the permissions of the directories synthesized
+		// by the map, so files can still be created in it.
+		fsys[name] = &MapFile{Mode: info.Mode(), ModTime: mtime, Sys: &MapFileSys{implicit: true}}
+		return nil
+	}
+	newFile := *file
+	newFile.ModTime = mtime
+	fsys.replace(file, &newFile)
+	return nil
+}
+
+// Chmod changes the permission bits of the named entry to those of mode, like
+// os.Chmod. Directories synthesized by the map become entries of the map,
+// which are subject to the permission checks like the others.
+func (fsys MapFS) Chmod(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrInvalid}
+	}
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	if err := fsys.search("chmod", name, ownerAccess); err != nil {
+		return err
+	}
+	file := fsys[name]
+	if file == nil {
+		info, err := fstest.MapFS(fsys).Stat(name)
+		if err != nil {
+			return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+		}
+		fsys[name] = &MapFile{Mode: info.Mode().Type() | mode.Perm(), ModTime: info.ModTime()}
+		return nil
+	}
+	if sys := fsys.sys(name); sys != nil {
+		sys.implicit = false
+	}
+	newFile := *file
+	newFile.Mode = file.Mode.Type() | mode.Perm()
+	fsys.replace(file, &newFile)
+	return nil
+}
+
+// replace replaces file with newFile in all the entries of fsys, so the hard
+// links created by Link keep sharing the same *MapFile.
+func (fsys MapFS) replace(file, newFile *MapFile) {
+	for name, f := range fsys {
+		if f == file {
+			fsys[name] = newFile
+		}
+	}
+}
+
+func (fsys MapFS) checkCreate(op, name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := fstest.MapFS(fsys).Stat(dir)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errParentNotDir}
+	}
+	return fsys.checkParent(op, name)
+}
+
+// checkParent returns an error if the owner is not granted the permissions to
+// search the parent directories of name and to modify the entries of its
+// parent, which are required to create, remove, or rename the named entry.
+// Like the directories synthesized by the map, the entries created for them
+// by Chtimes grant all the permissions.
+func (fsys MapFS) checkParent(op, name string) error {
+	if err := fsys.search(op, name, ownerAccess); err != nil {
+		return err
+	}
+	dir := path.Dir(name)
+	if sys := fsys.sys(dir); sys != nil && sys.implicit {
+		return nil
+	}
+	if file := fsys[dir]; file != nil && file.Mode.IsDir() && !ownerAccess(dir, file.Mode, 2|1) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+// ClockMapFS is a MapFS whose methods creating or modifying files set their
+// modification times from Clock instead of the real time, which makes the
+// trees built by tests reproducible, for example to compare them with EqualFS.
+// Tests can install a fixed clock, or a clock advancing at each call to order
+// the modifications.
+//
+// The Mkdir, MkdirAll, WriteFile, and Symlink methods read the clock; the other
+// methods of MapFS are inherited as-is. The zero value of Clock uses the real
+// time, like MapFS does.
+type ClockMapFS struct {
+	MapFS
+	Clock func() time.Time
+}
+
+func (fsys ClockMapFS) now() func() time.Time {
+	if fsys.Clock == nil {
+		return time.Now
+	}
+	return fsys.Clock
+}
+
+// Mkdir is like MapFS.Mkdir but sets the modification time from the clock.
+func (fsys ClockMapFS) Mkdir(name string, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.mkdir(name, perm, fsys.now())
+}
+
+// MkdirAll is like MapFS.MkdirAll but sets the modification times from the
+// clock.
+func (fsys ClockMapFS) MkdirAll(name string, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.mkdirAll(name, perm, fsys.now())
+}
+
+// WriteFile is like MapFS.WriteFile but sets the modification time from the
+// clock.
+func (fsys ClockMapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.writeFile(name, data, perm, fsys.now())
+}
+
+// Symlink is like MapFS.Symlink but sets the modification time from the clock.
+func (fsys ClockMapFS) Symlink(oldname, newname string) error {
+	mapfsMutex.Lock()
+	defer mapfsMutex.Unlock()
+	return fsys.symlink(oldname, newname, fsys.now())
+}
+
+var (
+	_ WritableFS = (MapFS)(nil)
+	_ WritableFS = ClockMapFS{}
+)
@@ -0,0 +1,351 @@
+package fstest_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMapFSWrite(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file":          &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"implicit/file": &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+	}
+
+	if err := fsys.MkdirAll("a/b/c", 0750); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("a/b/c/%d", i)
+		if err := fsys.WriteFile(name, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.Mkdir("a/d", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fstest.List(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, fmt.Sprintf("%s %s", entry.Path, entry.Mode))
+	}
+	want := []string{
+		"a drwxr-x---",
+		"a/b drwxr-x---",
+		"a/b/c drwxr-x---",
+		"a/b/c/0 -rw-------",
+		"a/b/c/1 -rw-------",
+		"a/b/c/2 -rw-------",
+		"a/d drwx------",
+		"file -rw-r--r--",
+		"implicit dr-xr-xr-x",
+		"implicit/file -rw-r--r--",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("entries mismatch:\nwant: %q\ngot:  %q", want, got)
+	}
+
+	tests := []struct {
+		scenario string
+		err      error
+		want     error
+	}{
+		{"mkdir existing directory", fsys.Mkdir("a/b", 0755), fs.ErrExist},
+		{"mkdir existing file", fsys.Mkdir("file", 0755), fs.ErrExist},
+		{"mkdir missing parent", fsys.Mkdir("x/y", 0755), fs.ErrNotExist},
+		{"mkdirall existing directory", fsys.MkdirAll("a/b", 0755), nil},
+		{"mkdirall implicit directory", fsys.MkdirAll("implicit", 0755), nil},
+		{"mkdirall invalid path", fsys.MkdirAll("../a", 0755), fs.ErrInvalid},
+		{"write missing parent", fsys.WriteFile("x/y", nil, 0644), fs.ErrNotExist},
+		{"write directory", fsys.WriteFile("a", nil, 0644), fs.ErrInvalid},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.scenario, test.want, test.err)
+		}
+	}
+
+	for _, name := range []string{"file", "file/dir"} {
+		err := fsys.MkdirAll(name, 0755)
+		if err == nil {
+			t.Errorf("%s: expected an error creating a directory over a file", name)
+		} else if want := "mkdir file: not a directory"; err.Error() != want {
+			t.Errorf("%s: error mismatch: want=%q got=%q", name, want, err)
+		}
+	}
+	if _, ok := fsys["x"]; ok {
+		t.Error("failed operations must not create entries")
+	}
+}
+
+func TestMapFSRemove(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":      &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/sub/file":  &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"implicit/file": &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+		"dirfile":       &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+	}
+
+	for _, name := range []string{"dir", "implicit", "dir/sub"} {
+		if err := fsys.Remove(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected an error removing a directory which is not empty, got %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		scenario string
+		err      error
+		want     error
+	}{
+		{"remove missing file", fsys.Remove("missing"), fs.ErrNotExist},
+		{"remove root", fsys.Remove("."), fs.ErrInvalid},
+		{"remove file", fsys.Remove("dir/file"), nil},
+		{"remove empty directory", fsys.Remove("empty"), nil},
+		{"remove implicit directory file", fsys.Remove("implicit/file"), nil},
+		{"removeall directory", fsys.RemoveAll("dir"), nil},
+		{"removeall missing file", fsys.RemoveAll("missing"), nil},
+		{"removeall root", fsys.RemoveAll("."), fs.ErrInvalid},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.scenario, test.want, test.err)
+		}
+	}
+
+	want := fstest.MapFS{
+		"dirfile": &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+	}
+	if err := fstest.EqualFS(want, fsys); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapFSRename(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/x":       &fstest.MapFile{Mode: 0644, Data: []byte("X")},
+		"a/sub/y":   &fstest.MapFile{Mode: 0644, Data: []byte("Y")},
+		"ab/z":      &fstest.MapFile{Mode: 0644, Data: []byte("Z")},
+		"a.txt":     &fstest.MapFile{Mode: 0644, Data: []byte("T")},
+		"old":       &fstest.MapFile{Mode: 0644, Data: []byte("old")},
+		"new":       &fstest.MapFile{Mode: 0600, Data: []byte("new")},
+		"empty":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"full/file": &fstest.MapFile{Mode: 0644, Data: []byte("F")},
+	}
+
+	tests := []struct {
+		scenario string
+		err      error
+		want     error
+	}{
+		{"missing source", fsys.Rename("missing", "b"), fs.ErrNotExist},
+		{"missing parent", fsys.Rename("old", "missing/new"), fs.ErrNotExist},
+		{"directory into itself", fsys.Rename("a", "a/sub/a"), fs.ErrInvalid},
+		{"file over file", fsys.Rename("new", "old"), nil},
+		{"directory move", fsys.Rename("a", "b"), nil},
+		{"directory over empty directory", fsys.Rename("b", "empty"), nil},
+		{"same path", fsys.Rename("a.txt", "a.txt"), nil},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.scenario, test.want, test.err)
+		}
+	}
+
+	for _, paths := range [][2]string{
+		{"empty", "full"},
+		{"empty", "old"},
+		{"old", "full"},
+	} {
+		if err := fsys.Rename(paths[0], paths[1]); err == nil {
+			t.Errorf("%s -> %s: expected an error", paths[0], paths[1])
+		}
+	}
+	err := fsys.Rename("empty", "old")
+	if want := "rename empty old: not a directory"; err == nil || err.Error() != want {
+		t.Errorf("directory over file: error mismatch: want=%q got=%v", want, err)
+	}
+
+	want := fstest.MapFS{
+		"empty":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"empty/x":     &fstest.MapFile{Mode: 0644, Data: []byte("X")},
+		"empty/sub/y": &fstest.MapFile{Mode: 0644, Data: []byte("Y")},
+		"ab/z":        &fstest.MapFile{Mode: 0644, Data: []byte("Z")},
+		"a.txt":       &fstest.MapFile{Mode: 0644, Data: []byte("T")},
+		"old":         &fstest.MapFile{Mode: 0600, Data: []byte("new")},
+		"full/file":   &fstest.MapFile{Mode: 0644, Data: []byte("F")},
+	}
+	if err := fstest.EqualFS(want, fsys); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapFSLinks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+	}
+
+	tests := []struct {
+		scenario string
+		err      error
+		want     error
+	}{
+		{"symlink", fsys.Symlink("dir/file", "link"), nil},
+		{"dangling symlink", fsys.Symlink("missing", "dir/dangling"), nil},
+		{"symlink exists", fsys.Symlink("dir", "link"), fs.ErrExist},
+		{"symlink missing parent", fsys.Symlink("dir", "missing/link"), fs.ErrNotExist},
+		{"hard link", fsys.Link("dir/file", "hardlink"), nil},
+		{"hard link missing", fsys.Link("missing", "other"), fs.ErrNotExist},
+		{"hard link exists", fsys.Link("dir/file", "link"), fs.ErrExist},
+		{"hard link directory", fsys.Link("dir", "dirlink"), fs.ErrPermission},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.scenario, test.want, test.err)
+		}
+	}
+
+	links := map[string]string{"link": "dir/file", "dir/dangling": "missing"}
+	if err := fstest.VerifySymlinks(fsys, links); err != nil {
+		t.Error(err)
+	}
+	if fsys["hardlink"] != fsys["dir/file"] {
+		t.Error("hard links do not share their entry")
+	}
+	if err := fsys.WriteFile("hardlink", []byte("B"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile(fsys, "dir/file"); string(data) != "B" {
+		t.Errorf("content mismatch after writing through a hard link: %q", data)
+	}
+}
+
+func TestMapFSConcurrentWrites(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":  &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("0")},
+		"link": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+	}
+	if err := fsys.Link("file", "hardlink"); err != nil {
+		t.Fatal(err)
+	}
+
+	var group sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		group.Add(2)
+		go func(i int) {
+			defer group.Done()
+			for n := 0; n < 100; n++ {
+				name := fmt.Sprintf("dir/%d", i)
+				data := []byte(fmt.Sprint(n))
+				if err := fsys.WriteFile("file", data, 0644); err != nil {
+					t.Error(err)
+				}
+				if err := fsys.WriteFile(name, data, 0644); err != nil {
+					t.Error(err)
+				}
+				if err := fsys.Rename(name, name+".renamed"); err != nil {
+					t.Error(err)
+				}
+				if err := fsys.Remove(name + ".renamed"); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+		go func() {
+			defer group.Done()
+			for n := 0; n < 100; n++ {
+				f, err := fsys.Open("file")
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if _, err := io.ReadAll(f); err != nil {
+					t.Error(err)
+				}
+				if info, err := f.Stat(); err != nil {
+					t.Error(err)
+				} else {
+					_ = info.ModTime()
+				}
+				f.Close()
+				if _, err := fs.ReadDir(fsys, "dir"); err != nil {
+					t.Error(err)
+				}
+				if _, err := fs.Stat(fsys, "hardlink"); err != nil {
+					t.Error(err)
+				}
+				if _, err := fsys.ReadLink("link"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	group.Wait()
+
+	a, _ := fs.ReadFile(fsys, "file")
+	b, _ := fs.ReadFile(fsys, "hardlink")
+	if string(a) != "99" || string(b) != "99" {
+		t.Errorf("hard links content mismatch: %q != %q", a, b)
+	}
+}
+
+func TestClockMapFS(t *testing.T) {
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	newFS := func() fstest.ClockMapFS {
+		now := t0
+		return fstest.ClockMapFS{
+			MapFS: fstest.MapFS{},
+			Clock: func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			},
+		}
+	}
+	build := func(fsys fstest.ClockMapFS) {
+		t.Helper()
+		if err := fsys.MkdirAll("a/b", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile("a/b/file", []byte("Hello World!"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Symlink("b/file", "a/link"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b := newFS(), newFS()
+	build(a)
+	build(b)
+	if err := fstest.EqualFSWith(a.MapFS, b.MapFS, fstest.MaxDepth(0)); err != nil {
+		t.Error(err)
+	}
+	for i, name := range []string{"a", "a/b", "a/b/file", "a/link"} {
+		if got, want := a.MapFS[name].ModTime, t0.Add(time.Duration(i+1)*time.Second); !got.Equal(want) {
+			t.Errorf("%s: modification time mismatch: want=%v got=%v", name, want, got)
+		}
+	}
+
+	// The zero value of the clock uses the real time.
+	fsys := fstest.ClockMapFS{MapFS: fstest.MapFS{}}
+	start := time.Now()
+	if err := fsys.WriteFile("file", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if modTime := fsys.MapFS["file"].ModTime; modTime.Before(start) {
+		t.Errorf("expected the real time, got %v", modTime)
+	}
+}
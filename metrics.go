@@ -0,0 +1,55 @@
+package fstest
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MetricsSink is an interface receiving the counters of a comparison as it
+// progresses, configured with the WithMetrics option.
+//
+// The methods may be called concurrently and must be safe for concurrent use.
+type MetricsSink interface {
+	// AddFiles is called with the number of files which were compared,
+	// including directories and symbolic links.
+	AddFiles(n int64)
+	// AddBytes is called with the number of bytes read from the file systems.
+	AddBytes(n int64)
+	// AddDifferences is called with the number of differences found.
+	AddDifferences(n int64)
+	// AddErrors is called with the number of errors which interrupted the
+	// comparison.
+	AddErrors(n int64)
+}
+
+// Metrics is a MetricsSink implementation using atomic counters.
+//
+// Metrics implements expvar.Var, so it can be published with expvar.Publish
+// to expose the counters of comparisons.
+type Metrics struct {
+	Files       atomic.Int64
+	Bytes       atomic.Int64
+	Differences atomic.Int64
+	Errors      atomic.Int64
+}
+
+func (m *Metrics) AddFiles(n int64) { m.Files.Add(n) }
+
+func (m *Metrics) AddBytes(n int64) { m.Bytes.Add(n) }
+
+func (m *Metrics) AddDifferences(n int64) { m.Differences.Add(n) }
+
+func (m *Metrics) AddErrors(n int64) { m.Errors.Add(n) }
+
+// String returns a JSON representation of the counters.
+func (m *Metrics) String() string {
+	return fmt.Sprintf(`{"files":%d,"bytes":%d,"differences":%d,"errors":%d}`,
+		m.Files.Load(), m.Bytes.Load(), m.Differences.Load(), m.Errors.Load())
+}
+
+// WithMetrics configures the comparison to report its progress to m, which is
+// updated as files are compared instead of once the comparison completes like
+// the Report returned by EqualFSReport.
+func WithMetrics(m MetricsSink) EqualOption {
+	return func(opts *equalOptions) { opts.metrics = m }
+}
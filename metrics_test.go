@@ -0,0 +1,42 @@
+package fstest_test
+
+import (
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithMetrics(t *testing.T) {
+	a := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("AAA")},
+		"dir/b": &fstest.MapFile{Mode: 0644, Data: []byte("BBBB")},
+		"dir/c": &fstest.MapFile{Mode: 0644, Data: []byte("CC")},
+	}
+	b := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0644, Data: []byte("AAA")},
+		"dir/b": &fstest.MapFile{Mode: 0644, Data: []byte("XXXX")},
+		"dir/c": &fstest.MapFile{Mode: 0644, Data: []byte("XX")},
+	}
+
+	m := new(fstest.Metrics)
+	if err := fstest.EqualFSAll(a, b, fstest.WithMetrics(m)); err == nil {
+		t.Fatal("expected differences")
+	}
+	if n := m.Files.Load(); n != 4 {
+		t.Errorf("files mismatch: want=4 got=%d", n)
+	}
+	if n := m.Bytes.Load(); n != 18 {
+		t.Errorf("bytes mismatch: want=18 got=%d", n)
+	}
+	if n := m.Differences.Load(); n != 2 {
+		t.Errorf("differences mismatch: want=2 got=%d", n)
+	}
+	if n := m.Errors.Load(); n != 0 {
+		t.Errorf("errors mismatch: want=0 got=%d", n)
+	}
+
+	const want = `{"files":4,"bytes":18,"differences":2,"errors":0}`
+	if s := m.String(); s != want {
+		t.Errorf("string mismatch: want=%s got=%s", want, s)
+	}
+}
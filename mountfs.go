@@ -0,0 +1,282 @@
+package fstest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// MountFS returns a file system composed of the file systems in mounts, each
+// mounted at the path of its key.
+//
+// Names are resolved by the file system mounted at the longest path prefixing
+// them. Mount points do not need to exist in the file systems they are mounted
+// on; the directories leading to mount points are synthesized if needed, and
+// the listing of directories containing mount points is merged with the mount
+// points they contain. A file system mounted at "." serves as the root of the
+// composite file system, otherwise the root is synthesized.
+//
+// The function panics if a key of mounts is not a valid path.
+func MountFS(mounts map[string]fs.FS) fs.FS {
+	fsys := &mountFS{mounts: make(map[string]fs.FS, len(mounts))}
+	for name, mount := range mounts {
+		if !fs.ValidPath(name) {
+			panic("fstest.MountFS: invalid mount point: " + name)
+		}
+		fsys.mounts[name] = mount
+		fsys.points = append(fsys.points, name)
+	}
+	sort.Strings(fsys.points)
+	return fsys
+}
+
+type mountFS struct {
+	mounts map[string]fs.FS
+	points []string
+}
+
+// resolve returns the file system mounted at the longest prefix of name, and
+// the name relative to the root of this file system.
+func (fsys *mountFS) resolve(name string) (fs.FS, string) {
+	for dir := name; ; dir = path.Dir(dir) {
+		if mount, ok := fsys.mounts[dir]; ok {
+			if dir == "." {
+				return mount, name
+			}
+			if dir == name {
+				return mount, "."
+			}
+			return mount, name[len(dir)+1:]
+		}
+		if dir == "." {
+			return nil, ""
+		}
+	}
+}
+
+// children returns the names of the mount points directly under dir, or of the
+// directories leading to them.
+func (fsys *mountFS) children(dir string) []string {
+	var names []string
+	for _, point := range fsys.points {
+		var rest string
+		switch {
+		case point == ".":
+			continue
+		case dir == ".":
+			rest = point
+		case strings.HasPrefix(point, dir+"/"):
+			rest = point[len(dir)+1:]
+		default:
+			continue
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (fsys *mountFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if len(fsys.children(name)) != 0 {
+		info, err := fsys.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &mountDir{name: name, info: info, entries: entries}, nil
+	}
+	mount, relName := fsys.resolve(name)
+	if mount == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := mount.Open(relName)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := f.(fs.ReadDirFile); ok && relName == "." && name != "." {
+		f = &mountRoot{d, path.Base(name)}
+	}
+	return f, nil
+}
+
+func (fsys *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	children := fsys.children(name)
+	mount, relName := fsys.resolve(name)
+	if mount == nil && len(children) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var entries []fs.DirEntry
+	if mount != nil {
+		var err error
+		entries, err = fs.ReadDir(mount, relName)
+		if err != nil && (len(children) == 0 || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, err
+		}
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries)+len(children))
+	for _, entry := range entries {
+		if !containsString(children, entry.Name()) {
+			merged = append(merged, entry)
+		}
+	}
+	for _, child := range children {
+		info, err := fsys.Stat(path.Join(name, child))
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, fs.FileInfoToDirEntry(info))
+	}
+	sortEntries(merged)
+	return merged, nil
+}
+
+func (fsys *mountFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	mount, relName := fsys.resolve(name)
+	if mount == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	return readLink(mount, relName)
+}
+
+func (fsys *mountFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	mount, relName := fsys.resolve(name)
+	if mount != nil {
+		info, err := fsys.stat(mount, name, relName)
+		if err == nil {
+			return info, nil
+		}
+		if len(fsys.children(name)) == 0 || !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	} else if len(fsys.children(name)) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &dirInfo{name: path.Base(name), perm: 0555}, nil
+}
+
+func (fsys *mountFS) stat(mount fs.FS, name, relName string) (fs.FileInfo, error) {
+	if relName != "." || name == "." {
+		return fs.Stat(mount, relName)
+	}
+	// The information of mount points is obtained from the opened root
+	// directory so it is consistent with the information of mountRoot.
+	f, err := mount.Open(relName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &renamedInfo{info, path.Base(name)}, nil
+}
+
+var (
+	_ fs.ReadDirFS      = (*mountFS)(nil)
+	_ fs.StatFS         = (*mountFS)(nil)
+	_ fslink.ReadLinkFS = (*mountFS)(nil)
+)
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+type mountDir struct {
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *mountDir) Close() error { return nil }
+
+func (d *mountDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *mountDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+func (d *mountDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+// mountRoot is the root directory of a mounted file system, which reports the
+// name of the mount point instead of ".".
+type mountRoot struct {
+	fs.ReadDirFile
+	name string
+}
+
+func (d *mountRoot) Stat() (fs.FileInfo, error) {
+	info, err := d.ReadDirFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &renamedInfo{info, d.name}, nil
+}
+
+// dirInfo is the information of synthesized directories.
+type dirInfo struct {
+	name string
+	perm fs.FileMode
+}
+
+func (info *dirInfo) Name() string { return info.name }
+
+func (info *dirInfo) Size() int64 { return 0 }
+
+func (info *dirInfo) Mode() fs.FileMode { return fs.ModeDir | info.perm }
+
+func (info *dirInfo) ModTime() time.Time { return time.Time{} }
+
+func (info *dirInfo) IsDir() bool { return true }
+
+func (info *dirInfo) Sys() any { return nil }
+
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info *renamedInfo) Name() string { return info.name }
@@ -0,0 +1,52 @@
+package fstest_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMountFS(t *testing.T) {
+	fsys := fstest.MountFS(map[string]fs.FS{
+		".": fstest.MapFS{
+			"README":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+			"lib/a/file": &fstest.MapFile{Mode: 0644, Data: []byte("shadowed")},
+		},
+		"lib/a": fstest.MapFS{
+			"a.go": &fstest.MapFile{Mode: 0644, Data: []byte("package a")},
+		},
+		"lib/b": fstest.MapFS{
+			"b.go":    &fstest.MapFile{Mode: 0644, Data: []byte("package b")},
+			"symlink": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("b.go")},
+		},
+	})
+
+	golden := fstest.MapFS{
+		"README":        &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"lib/a/a.go":    &fstest.MapFile{Mode: 0644, Data: []byte("package a")},
+		"lib/b/b.go":    &fstest.MapFile{Mode: 0644, Data: []byte("package b")},
+		"lib/b/symlink": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("b.go")},
+	}
+
+	if err := fstest.EqualFS(fsys, golden); err != nil {
+		t.Error(err)
+	}
+
+	link, err := fslink.ReadLink(fsys, "lib/b/symlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "b.go" {
+		t.Errorf("symbolic link mismatch: want=%q got=%q", "b.go", link)
+	}
+
+	if _, err := fs.Stat(fsys, "lib/a/file"); err == nil {
+		t.Error("expected the file under the mount point to be shadowed")
+	}
+
+	if err := fstest.CheckReadDirPaging(fsys, "lib", 1); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,106 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ErrSymlinkUnsupported is the error wrapped by the errors of the operations
+// on symbolic links of the file systems returned by NoSymlinkFS.
+var ErrSymlinkUnsupported = errors.New("symbolic links not supported")
+
+// NoSymlinkFS returns a file system wrapping fsys which simulates platforms
+// and formats without support for symbolic links, allowing to test the
+// fallback behavior of code using symbolic links.
+//
+// Reading symbolic links of the returned file system always fails with errors
+// wrapping ErrSymlinkUnsupported; the other operations are delegated to fsys,
+// so the symbolic links that fsys contains are still listed in directories.
+//
+// The returned file system implements WritableFS, delegating to fsys if it
+// implements WritableFS, or returning errors wrapping fs.ErrPermission
+// otherwise. Its Symlink method always fails with ErrSymlinkUnsupported.
+func NoSymlinkFS(fsys fs.FS) fs.FS {
+	return &noSymlinkFS{fsys}
+}
+
+type noSymlinkFS struct {
+	base fs.FS
+}
+
+func (fsys *noSymlinkFS) Open(name string) (fs.File, error) {
+	return fsys.base.Open(name)
+}
+
+func (fsys *noSymlinkFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *noSymlinkFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys.base, name)
+}
+
+func (fsys *noSymlinkFS) ReadLink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: ErrSymlinkUnsupported}
+}
+
+func (fsys *noSymlinkFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.base, name)
+}
+
+func (fsys *noSymlinkFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fslink.Sub(fsys.base, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &noSymlinkFS{sub}, nil
+}
+
+func (fsys *noSymlinkFS) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrSymlinkUnsupported}
+}
+
+func (fsys *noSymlinkFS) writable(op, name string) (WritableFS, error) {
+	base, ok := fsys.base.(WritableFS)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return base, nil
+}
+
+func (fsys *noSymlinkFS) Chtimes(name string, atime, mtime time.Time) error {
+	base, err := fsys.writable("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return base.Chtimes(name, atime, mtime)
+}
+
+func (fsys *noSymlinkFS) Mkdir(name string, perm fs.FileMode) error {
+	base, err := fsys.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return base.Mkdir(name, perm)
+}
+
+func (fsys *noSymlinkFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	base, err := fsys.writable("write", name)
+	if err != nil {
+		return err
+	}
+	return base.WriteFile(name, data, perm)
+}
+
+var (
+	_ fs.ReadDirFS      = (*noSymlinkFS)(nil)
+	_ fs.ReadFileFS     = (*noSymlinkFS)(nil)
+	_ fs.StatFS         = (*noSymlinkFS)(nil)
+	_ fs.SubFS          = (*noSymlinkFS)(nil)
+	_ fslink.ReadLinkFS = (*noSymlinkFS)(nil)
+	_ WritableFS        = (*noSymlinkFS)(nil)
+)
@@ -0,0 +1,62 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestNoSymlinkFS(t *testing.T) {
+	base := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	fsys := fstest.NoSymlinkFS(base)
+
+	if err := fstest.TestFS(fsys, "dir/file"); err != nil {
+		t.Error(err)
+	}
+	if _, err := fslink.ReadLink(fsys, "dir/file"); !errors.Is(err, fstest.ErrSymlinkUnsupported) {
+		t.Errorf("expected ErrSymlinkUnsupported, got %v", err)
+	}
+
+	w := fsys.(interface {
+		fstest.WritableFS
+		Symlink(oldname, newname string) error
+	})
+	if err := w.Symlink("file", "dir/link"); !errors.Is(err, fstest.ErrSymlinkUnsupported) {
+		t.Errorf("expected ErrSymlinkUnsupported, got %v", err)
+	}
+	if _, ok := base["dir/link"]; ok {
+		t.Error("the symbolic link was created")
+	}
+	if err := w.WriteFile("dir/other", []byte("other"), 0644); err != nil {
+		t.Error(err)
+	}
+
+	if err := base.Symlink("file", "dir/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(base, fsys); !errors.Is(err, fstest.ErrSymlinkUnsupported) {
+		t.Errorf("expected ErrSymlinkUnsupported, got %v", err)
+	}
+}
+
+func TestNoSymlinkFSSub(t *testing.T) {
+	sub, err := fs.Sub(fstest.NoSymlinkFS(linkFS{fstest.MapFS{
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/link": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+	}}), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fslink.ReadLink(sub, "link"); !errors.Is(err, fstest.ErrSymlinkUnsupported) {
+		t.Errorf("sub readlink: expected ErrSymlinkUnsupported, got %v", err)
+	}
+	if data, err := fs.ReadFile(sub, "file"); err != nil || string(data) != "Hello World!" {
+		t.Errorf("sub read: %q (%v)", data, err)
+	}
+}
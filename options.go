@@ -0,0 +1,454 @@
+package fstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"net/http"
+	"path"
+	"time"
+)
+
+// EqualOption represents options used to configure the comparison of file
+// systems performed by EqualFSWith.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	skipLargerThan   int64
+	sizeRounding     int64
+	modeMask         fs.FileMode
+	ignoreModTime    bool
+	ignoreAccessTime bool
+	ignoreChangeTime bool
+	requireModTime   bool
+	requireAccess    bool
+	requireChange    bool
+	timeTolerance    time.Duration
+	include          []string
+	contentType      bool
+	filesOnly        bool
+	maxDepth         int
+	workers          int
+	followSymlinks   bool
+	symlinkAsTarget  bool
+	detectHardlinks  bool
+	compareXattrs    bool
+	exclude          []string
+	metrics          MetricsSink
+	warning          func(error)
+	encodings        []Encoding
+	digestOnly       bool
+	structureOnly    bool
+	hash             func() hash.Hash
+	whitespace       *WhitespaceMode
+	reportWhitespace bool
+	textDiff         bool
+	implicitDirs     bool
+	implicitPerm     fs.FileMode
+	// Set when the comparison is made by EqualFSContext, the comparison is
+	// interrupted when the context is canceled.
+	ctx context.Context
+	// Set when the comparison verifies the groups of hard links.
+	hardlinks *hardlinks
+	// Set when the comparison compares directories concurrently.
+	parallel *parallel
+	// Set when the comparison is made by MirrorCheck.
+	mirror bool
+	// Set when the comparison is made by EqualFSReport.
+	report *Report
+	// Set when the comparison is made by EqualFSAll, which records the
+	// differences in errs instead of returning them.
+	errs *[]error
+	// Set by WithPerPathOptions; global is the set of options that the
+	// options of each path are derived from.
+	perPath func(string) []EqualOption
+	global  *equalOptions
+}
+
+func newEqualOptions(options []EqualOption) *equalOptions {
+	opts := &equalOptions{
+		skipLargerThan: -1,
+		maxDepth:       -1,
+		modeMask:       fs.ModePerm,
+		ctx:            context.Background(),
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+	return opts
+}
+
+func (opts *equalOptions) observe(name string, typ fs.FileMode, start time.Time) {
+	if opts.report != nil {
+		opts.report.observe(name, typ, start)
+	}
+}
+
+func (opts *equalOptions) observeBytes(n1, n2 int) {
+	if opts.report != nil {
+		opts.report.Bytes += int64(n1)
+	}
+	if opts.metrics != nil {
+		opts.metrics.AddBytes(int64(n1 + n2))
+	}
+}
+
+func (opts *equalOptions) observeFile() {
+	if opts.metrics != nil {
+		opts.metrics.AddFiles(1)
+	}
+}
+
+func (opts *equalOptions) observeError(err error) {
+	if opts.metrics != nil {
+		var equalErr *EqualError
+		if errors.As(err, &equalErr) {
+			opts.metrics.AddDifferences(1)
+		} else {
+			opts.metrics.AddErrors(1)
+		}
+	}
+}
+
+func (opts *equalOptions) warn(err error) {
+	if opts.warning != nil {
+		opts.warning(err)
+	}
+}
+
+// forPath returns the options used to compare the file at name, which are the
+// global options augmented with the options returned by the function passed
+// to WithPerPathOptions.
+func (opts *equalOptions) forPath(name string) *equalOptions {
+	if opts.global != nil {
+		opts = opts.global
+	}
+	if opts.perPath == nil {
+		return opts
+	}
+	options := opts.perPath(name)
+	if len(options) == 0 {
+		return opts
+	}
+	derived := *opts
+	derived.global = opts
+	// Clip the slices so appending to them does not overwrite the values of
+	// the options derived for other paths.
+	derived.include = derived.include[:len(derived.include):len(derived.include)]
+	derived.exclude = derived.exclude[:len(derived.exclude):len(derived.exclude)]
+	derived.encodings = derived.encodings[:len(derived.encodings):len(derived.encodings)]
+	for _, opt := range options {
+		opt(&derived)
+	}
+	return &derived
+}
+
+// record returns err unless it is a difference between the file systems which
+// has been recorded because the comparison collects all differences.
+func (opts *equalOptions) record(err error) error {
+	var equalErr *EqualError
+	if opts.errs != nil && errors.As(err, &equalErr) {
+		opts.observeError(err)
+		*opts.errs = append(*opts.errs, err)
+		return nil
+	}
+	return err
+}
+
+func (opts *equalOptions) filter(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if len(opts.include) == 0 && len(opts.exclude) == 0 && !opts.filesOnly {
+		return entries
+	}
+	filtered := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if opts.filesOnly && !entry.Type().IsDir() && !entry.Type().IsRegular() {
+			continue
+		}
+		if opts.match(path.Join(dir, entry.Name()), entry.IsDir()) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+func (opts *equalOptions) match(name string, isDir bool) bool {
+	if matchAny(opts.exclude, name) {
+		return false
+	}
+	return isDir || len(opts.include) == 0 || matchAny(opts.include, name)
+}
+
+func (opts *equalOptions) contentTypeChange(source, target []byte) string {
+	if opts.contentType {
+		sourceType := http.DetectContentType(source)
+		targetType := http.DetectContentType(target)
+		if sourceType != targetType {
+			return fmt.Sprintf(": content-type changed: %s -> %s", sourceType, targetType)
+		}
+	}
+	return ""
+}
+
+func (opts *equalOptions) roundSize(size int64) int64 {
+	if block := opts.sizeRounding; block > 0 {
+		size = ((size + block - 1) / block) * block
+	}
+	return size
+}
+
+func (opts *equalOptions) skipContent(info fs.FileInfo) bool {
+	return opts.skipLargerThan >= 0 && info.Size() > opts.skipLargerThan
+}
+
+// EqualFSWith is like EqualFS but the comparison can be configured by passing
+// a list of options.
+func EqualFSWith(a, b fs.FS, opts ...EqualOption) error {
+	return equalFS(a, b, nil, newEqualOptions(opts))
+}
+
+// EqualSubtree is like EqualFSWith but compares the directory at subPath in a
+// against the whole file system b.
+//
+// The function returns an error if subPath does not exist in a or is not a
+// directory.
+func EqualSubtree(a fs.FS, subPath string, b fs.FS, opts ...EqualOption) error {
+	info, err := fs.Stat(a, subPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "equal", Path: subPath, Err: errNotDir}
+	}
+	return EqualFSWith(&prefixFS{a, subPath}, b, opts...)
+}
+
+// MirrorCheck verifies that target is a mirror of source: every file of source
+// must exist in target and be equal, but files existing only in target are not
+// considered differences. This is useful to validate one-way synchronizations
+// where the target may retain files deleted from the source.
+//
+// The files existing only in target are reported to the function configured
+// with OnWarning, as errors of kind Added.
+func MirrorCheck(source, target fs.FS, opts ...EqualOption) error {
+	options := newEqualOptions(opts)
+	options.mirror = true
+	return equalFS(source, target, nil, options)
+}
+
+// OnWarning configures the comparison to call fn with the observations which
+// are not considered differences, such as the files existing only in the
+// target of MirrorCheck.
+func OnWarning(fn func(err error)) EqualOption {
+	return func(opts *equalOptions) { opts.warning = fn }
+}
+
+// SkipLargerThan configures the comparison to skip reading the content of
+// files larger than the given size. The metadata of those files, including
+// their sizes, are still compared, which means that files of different sizes
+// are still reported as different.
+func SkipLargerThan(size int64) EqualOption {
+	return func(opts *equalOptions) { opts.skipLargerThan = size }
+}
+
+// WithSizeRounding configures the comparison to round the sizes of files up to
+// a multiple of the given block size before comparing them, which tolerates
+// file systems reporting sizes padded to block boundaries.
+//
+// Because the sizes may differ, the content of the files is compared up to the
+// end of the shorter file; the bytes that the longer file has in excess must
+// all be zeros. Files with the same rounded size but trailing non-zero bytes
+// are still reported as different.
+func WithSizeRounding(block int64) EqualOption {
+	return func(opts *equalOptions) { opts.sizeRounding = block }
+}
+
+// IgnoreModTime configures the comparison to ignore the modification times of
+// files, which are otherwise compared when both file systems report them.
+func IgnoreModTime() EqualOption {
+	return func(opts *equalOptions) { opts.ignoreModTime = true }
+}
+
+// IgnoreAccessTime configures the comparison to ignore the access times of
+// files, which are otherwise compared when both file systems report them.
+func IgnoreAccessTime() EqualOption {
+	return func(opts *equalOptions) { opts.ignoreAccessTime = true }
+}
+
+// IgnoreChangeTime configures the comparison to ignore the change times of
+// files, which are otherwise compared when both file systems report them.
+func IgnoreChangeTime() EqualOption {
+	return func(opts *equalOptions) { opts.ignoreChangeTime = true }
+}
+
+// RequireModTime configures the comparison to report a difference of kind
+// TimeChanged when either file system reports a zero modification time for a
+// file, instead of skipping the comparison of the times, which is useful to
+// write strict conformance tests of file systems claiming to support them.
+// The modification times are those returned by the ModTime method of the file
+// information, so the entries of a MapFS with a non-zero ModTime satisfy the
+// requirement.
+//
+// The missing times are reported even when a tolerance is configured with
+// ModTimeTolerance, which only applies to the times reported by both file
+// systems. IgnoreModTime takes precedence over RequireModTime.
+func RequireModTime() EqualOption {
+	return func(opts *equalOptions) { opts.requireModTime = true }
+}
+
+// RequireAccessTime is like RequireModTime but for the access times of files.
+func RequireAccessTime() EqualOption {
+	return func(opts *equalOptions) { opts.requireAccess = true }
+}
+
+// RequireChangeTime is like RequireModTime but for the change times of files.
+func RequireChangeTime() EqualOption {
+	return func(opts *equalOptions) { opts.requireChange = true }
+}
+
+// ModTimeTolerance configures the comparison to consider the times of files
+// equal when they are within d of each other, which is useful to compare file
+// systems storing times with different granularities (e.g. FAT file systems
+// round modification times to 2 seconds). The tolerance applies to the
+// modification, access, and change times, but not to the times missing on one
+// side when they are required with RequireModTime and similar options.
+func ModTimeTolerance(d time.Duration) EqualOption {
+	return func(opts *equalOptions) { opts.timeTolerance = d }
+}
+
+// WithModeMask configures the comparison to only compare the bits of file
+// modes which are set in mask, instead of the permission bits fs.ModePerm.
+// The mask can include bits such as fs.ModeSetuid or fs.ModeSticky to compare
+// them as well. The types of files are always compared.
+func WithModeMask(mask fs.FileMode) EqualOption {
+	return func(opts *equalOptions) { opts.modeMask = mask &^ fs.ModeType }
+}
+
+// PlatformNeutralModes configures the comparison to only compare the bits of
+// file modes which are meaningful on both unix and Windows, which are the owner
+// read, write, and execute permissions (0700). The group and other permissions,
+// as well as the setuid, setgid, and sticky bits, are ignored. The types of
+// files are always compared.
+//
+// This option is a preset of WithModeMask, useful to compare file systems
+// captured on Windows, where all permission classes mirror the owner's, against
+// golden data produced on unix.
+func PlatformNeutralModes() EqualOption {
+	return WithModeMask(0700)
+}
+
+// DigestOnly configures the comparison to trust the digests of the content of
+// files implementing io.WriterTo.
+//
+// When the files on both sides implement io.WriterTo, their content is written
+// to SHA-256 hashes and the digests compared, which can be faster than reading
+// the files for some backends. By default, files with the same digest are then
+// compared byte by byte, since distinct contents could produce the same digest.
+// With this option, the files are considered equal when their digests match,
+// which halves the amount of data read at the risk of missing differences in
+// the (astronomically unlikely) event of a hash collision.
+func DigestOnly() EqualOption {
+	return func(opts *equalOptions) { opts.digestOnly = true }
+}
+
+// StructureOnly configures the comparison to skip reading the content of
+// files, comparing only the names, types, modes, sizes, and times (unless
+// ignored) of the files found in the directory trees, which all come from the
+// file metadata. Directories are still traversed at every level, so added and
+// removed files are reported.
+//
+// This turns the comparison of large trees into a fast metadata pass, but
+// files of the same size with different contents are considered equal.
+func StructureOnly() EqualOption {
+	return func(opts *equalOptions) { opts.structureOnly = true }
+}
+
+// CompareContentHash configures the comparison to compare the content of files
+// by writing them to hashes created by calling h, and comparing the digests,
+// instead of comparing the content byte by byte.
+//
+// This bounds the work to a single sequential pass over each file, and the
+// reported difference only indicates that the digests differ rather than the
+// location of the first difference. The digests are trusted, so files with
+// different contents producing the same digest are considered equal. The
+// option has no effect when combined with WithSizeRounding, since padded files
+// would produce different digests.
+func CompareContentHash(h func() hash.Hash) EqualOption {
+	return func(opts *equalOptions) { opts.hash = h }
+}
+
+// Include configures the comparison to only consider files matching one of the
+// patterns. The patterns use the syntax of path.Match, with the addition of
+// "**" which matches any number of directories (e.g. "**/*.go").
+//
+// Directories are always traversed so that the files they contain can be
+// matched, but regular files, symbolic links, and other types of files which
+// do not match any of the patterns are ignored on both sides.
+func Include(patterns ...string) EqualOption {
+	return func(opts *equalOptions) { opts.include = append(opts.include, patterns...) }
+}
+
+// Exclude configures the comparison to ignore files matching one of the
+// patterns, using the same syntax as Include. Excluded directories are not
+// traversed. Exclusion is applied to both sides, so an excluded file existing
+// in only one of the file systems is not reported as a difference.
+func Exclude(patterns ...string) EqualOption {
+	return func(opts *equalOptions) { opts.exclude = append(opts.exclude, patterns...) }
+}
+
+// DetectContentType configures the comparison to detect the content types of
+// files which have different contents, using http.DetectContentType, and add
+// the change of content type to the error message if they differ (e.g.
+// "content-type changed: image/png -> image/jpeg").
+//
+// The detection only runs when a content mismatch is found, it does not slow
+// down the comparison of equal files.
+func DetectContentType() EqualOption {
+	return func(opts *equalOptions) { opts.contentType = true }
+}
+
+// FilesOnly configures the comparison to ignore symbolic links, devices, named
+// pipes, sockets, and any other file which is neither a regular file nor a
+// directory. Directories are still traversed, and their entries compared
+// without the ignored files.
+func FilesOnly() EqualOption {
+	return func(opts *equalOptions) { opts.filesOnly = true }
+}
+
+// MaxDepth configures the comparison to not descend into directories deeper
+// than n levels below the root. Depth 0 only compares the listing of the root
+// directory; depth 1 also compares the listings of its subdirectories, and so
+// on. The entries found at the maximum depth are still compared, including the
+// content of regular files, and the information reported by Stat for the
+// directories which are not traversed. A negative depth removes the limit,
+// which is the default.
+//
+// Symbolic links are never followed by the comparison, their targets are
+// compared instead, so a symbolic link to a directory does not count as a
+// directory and does not increase the depth of the files it leads to.
+func MaxDepth(n int) EqualOption {
+	return func(opts *equalOptions) { opts.maxDepth = n }
+}
+
+// WithPerPathOptions configures the comparison to use different options for
+// different paths. The function is called with the path of each file compared
+// (including "." for the root directory) and returns options augmenting the
+// global options during the comparison of this path.
+//
+// The global options passed to EqualFSWith are applied first, followed by the
+// options returned for the path, which therefore take precedence. The options
+// returned for a directory are not inherited by the files it contains, but the
+// options filtering directory entries (such as Include, Exclude, or FilesOnly)
+// apply to the entries of the directory. For example, to skip the content of
+// files under the "cache" directory:
+//
+//	fstest.WithPerPathOptions(func(name string) []fstest.EqualOption {
+//		if strings.HasPrefix(name, "cache/") {
+//			return []fstest.EqualOption{fstest.SkipLargerThan(0)}
+//		}
+//		return nil
+//	})
+func WithPerPathOptions(fn func(path string) []EqualOption) EqualOption {
+	return func(opts *equalOptions) { opts.perPath = fn }
+}
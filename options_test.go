@@ -0,0 +1,589 @@
+package fstest_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestSkipLargerThan(t *testing.T) {
+	a := fstest.MapFS{
+		"small": &fstest.MapFile{Mode: 0644, Data: []byte("small")},
+		"large": &fstest.MapFile{Mode: 0644, Data: bytes.Repeat([]byte("A"), 4096)},
+	}
+
+	b := fstest.MapFS{
+		"small": &fstest.MapFile{Mode: 0644, Data: []byte("small")},
+		"large": &fstest.MapFile{Mode: 0644, Data: bytes.Repeat([]byte("B"), 4096)},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected content mismatch without skipping large files")
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.SkipLargerThan(1024)); err != nil {
+		t.Error(err)
+	}
+
+	b["small"] = &fstest.MapFile{Mode: 0644, Data: []byte("SMALL")}
+	if err := fstest.EqualFSWith(a, b, fstest.SkipLargerThan(1024)); err == nil {
+		t.Error("expected content mismatch of small files")
+	}
+
+	b["small"] = a["small"]
+	b["large"] = &fstest.MapFile{Mode: 0644, Data: bytes.Repeat([]byte("B"), 2048)}
+	if err := fstest.EqualFSWith(a, b, fstest.SkipLargerThan(1024)); err == nil {
+		t.Error("expected size mismatch of large files")
+	}
+}
+
+func TestWithSizeRounding(t *testing.T) {
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("abc")},
+	}
+
+	tests := []struct {
+		scenario string
+		data     string
+		block    int64
+		equal    bool
+	}{
+		{"padded", "abc\x00\x00\x00\x00\x00", 8, true},
+		{"not padded", "abc", 8, true},
+		{"different block", "abc\x00\x00\x00\x00\x00", 4, false},
+		{"content mismatch", "abd\x00\x00\x00\x00\x00", 8, false},
+		{"non-zero padding", "abc\x00\x00\x00\x00x", 8, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			b := fstest.MapFS{
+				"file": &fstest.MapFile{Mode: 0644, Data: []byte(test.data)},
+			}
+			err := fstest.EqualFSWith(a, b, fstest.WithSizeRounding(test.block))
+			if test.equal && err != nil {
+				t.Error(err)
+			}
+			if !test.equal && err == nil {
+				t.Error("expected a difference")
+			}
+			if err := fstest.EqualFSWith(b, a, fstest.WithSizeRounding(test.block)); (err == nil) != test.equal {
+				t.Errorf("comparison is not symmetric: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 24)...)
+	jpg := append([]byte("\xff\xd8\xff\xe0"), make([]byte, 28)...)
+
+	a := fstest.MapFS{"image": &fstest.MapFile{Mode: 0644, Data: png}}
+	b := fstest.MapFS{"image": &fstest.MapFile{Mode: 0644, Data: jpg}}
+
+	err := fstest.EqualFSWith(a, b, fstest.DetectContentType())
+	if err == nil {
+		t.Fatal("expected a content mismatch")
+	}
+	if want := "content-type changed: image/png -> image/jpeg"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error message does not mention the content type change: %v", err)
+	}
+
+	err = fstest.EqualFS(a, b)
+	if err == nil {
+		t.Fatal("expected a content mismatch")
+	}
+	if strings.Contains(err.Error(), "content-type") {
+		t.Errorf("error message mentions the content type without the option: %v", err)
+	}
+}
+
+func TestFilesOnly(t *testing.T) {
+	a := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/symlink": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+		"dir/pipe":    &fstest.MapFile{Mode: 0600 | fs.ModeNamedPipe},
+	}
+
+	b := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected a difference without the option")
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.FilesOnly()); err != nil {
+		t.Error(err)
+	}
+
+	b["dir/file"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World?")}
+	if err := fstest.EqualFSWith(a, b, fstest.FilesOnly()); err == nil {
+		t.Error("expected a content mismatch of regular files")
+	}
+}
+
+func TestExclude(t *testing.T) {
+	a := fstest.MapFS{
+		".DS_Store":         &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"src/.DS_Store":     &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"src/main.go":       &fstest.MapFile{Mode: 0644, Data: []byte("package main")},
+		"cache/a":           &fstest.MapFile{Mode: 0644, Data: []byte("1")},
+		"cache/b":           &fstest.MapFile{Mode: 0644, Data: []byte("2")},
+		"build/tmp/out.txt": &fstest.MapFile{Mode: 0644, Data: []byte("output")},
+	}
+
+	b := fstest.MapFS{
+		"src/main.go": &fstest.MapFile{Mode: 0644, Data: []byte("package main")},
+		"cache":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"cache/c":     &fstest.MapFile{Mode: 0644, Data: []byte("3")},
+		"build/tmp":   &fstest.MapFile{Mode: 0644, Data: []byte("not a directory")},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected a difference without the option")
+	}
+
+	exclude := fstest.Exclude("**/.DS_Store", "cache/*", "build/tmp")
+	if err := fstest.EqualFSWith(a, b, exclude); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(b, a, exclude); err != nil {
+		t.Error(err)
+	}
+
+	b["src/main.go"] = &fstest.MapFile{Mode: 0644, Data: []byte("package test")}
+	if err := fstest.EqualFSWith(a, b, exclude); !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Errorf("expected a content mismatch of files which are not excluded, got %v", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	a := fstest.MapFS{
+		"file":              &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/file":          &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"dir/sub/file":      &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+		"dir/sub/deep/file": &fstest.MapFile{Mode: 0644, Data: []byte("D")},
+	}
+
+	b := fstest.MapFS{
+		"file":         &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/file":     &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"dir/sub/file": &fstest.MapFile{Mode: 0644, Data: []byte("X")},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected a difference without the option")
+	}
+	for _, n := range []int{0, 1} {
+		if err := fstest.EqualFSWith(a, b, fstest.MaxDepth(n)); err != nil {
+			t.Errorf("depth %d: %v", n, err)
+		}
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.MaxDepth(2)); !errors.Is(err, fstest.ErrEntriesMismatch) {
+		t.Errorf("depth 2: expected a mismatch of directory entries, got %v", err)
+	}
+
+	b["dir"] = &fstest.MapFile{Mode: 0700 | fs.ModeDir}
+	if err := fstest.EqualFSWith(a, b, fstest.MaxDepth(0)); !errors.Is(err, fstest.ErrModeMismatch) {
+		t.Errorf("expected a mode mismatch of the directory at the maximum depth, got %v", err)
+	}
+}
+
+func TestWithPerPathOptions(t *testing.T) {
+	a := fstest.MapFS{
+		"cache/data": &fstest.MapFile{Mode: 0644, Data: []byte("AAAA")},
+		"tmp/a.log":  &fstest.MapFile{Mode: 0644, Data: []byte("log")},
+		"bin/tool":   &fstest.MapFile{Mode: 0755, Data: []byte("tool")},
+	}
+	b := fstest.MapFS{
+		"cache/data": &fstest.MapFile{Mode: 0644, Data: []byte("BBBB")},
+		"tmp/b.log":  &fstest.MapFile{Mode: 0644, Data: []byte("log")},
+		"bin/tool":   &fstest.MapFile{Mode: 0755, Data: []byte("tool")},
+	}
+
+	perPath := fstest.WithPerPathOptions(func(name string) []fstest.EqualOption {
+		switch {
+		case strings.HasPrefix(name, "cache/"):
+			return []fstest.EqualOption{fstest.SkipLargerThan(0)}
+		case name == "tmp":
+			return []fstest.EqualOption{fstest.Exclude("tmp/*.log")}
+		}
+		return nil
+	})
+
+	if err := fstest.EqualFSWith(a, b, perPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFSWith(a, b); err == nil {
+		t.Error("expected the file systems to differ without per-path options")
+	}
+
+	b["bin/tool"] = &fstest.MapFile{Mode: 0755, Data: []byte("TOOL")}
+	if err := fstest.EqualFSWith(a, b, perPath); err == nil {
+		t.Error("expected the content of bin/tool to be compared")
+	}
+}
+
+func TestEqualSubtree(t *testing.T) {
+	a := fstest.MapFS{
+		"file":         &fstest.MapFile{Mode: 0644, Data: []byte("root")},
+		"sub/file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"sub/dir/file": &fstest.MapFile{Mode: 0600, Data: []byte("42")},
+	}
+	b := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/file": &fstest.MapFile{Mode: 0600, Data: []byte("42")},
+	}
+
+	if err := fstest.EqualSubtree(a, "sub", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualSubtree(a, ".", b); err == nil {
+		t.Error("expected the root of a to differ from b")
+	}
+	if err := fstest.EqualSubtree(a, "missing", b); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected an error for the missing subtree, got %v", err)
+	}
+	if err := fstest.EqualSubtree(a, "file", b); err == nil {
+		t.Error("expected an error for a subtree which is not a directory")
+	}
+}
+
+func TestPlatformNeutralModes(t *testing.T) {
+	unix := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"bin/tool": &fstest.MapFile{Mode: 0755 | fs.ModeSetuid, Data: []byte("42")},
+	}
+	windows := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0666, Data: []byte("Hello World!")},
+		"bin/tool": &fstest.MapFile{Mode: 0777, Data: []byte("42")},
+	}
+
+	if err := fstest.EqualFS(unix, windows); err == nil {
+		t.Error("expected the modes to differ")
+	}
+	if err := fstest.EqualFSWith(unix, windows, fstest.PlatformNeutralModes()); err != nil {
+		t.Error(err)
+	}
+
+	windows["file"].Mode = 0444
+	if err := fstest.EqualFSWith(unix, windows, fstest.PlatformNeutralModes()); err == nil {
+		t.Error("expected the owner permissions to differ")
+	}
+	if err := fstest.EqualFSWith(unix, unix, fstest.WithModeMask(fs.ModePerm|fs.ModeSetuid)); err != nil {
+		t.Error(err)
+	}
+	windows["file"].Mode = 0644
+	windows["bin/tool"].Mode = 0755
+	if err := fstest.EqualFS(unix, windows); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(unix, windows, fstest.WithModeMask(fs.ModePerm|fs.ModeSetuid)); err == nil {
+		t.Error("expected the setuid bits to differ")
+	}
+}
+
+func TestMirrorCheck(t *testing.T) {
+	source := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("42")},
+	}
+	target := fstest.MapFS{
+		"file":       &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/file":   &fstest.MapFile{Mode: 0644, Data: []byte("42")},
+		"dir/stale":  &fstest.MapFile{Mode: 0644},
+		"stale/file": &fstest.MapFile{Mode: 0644},
+	}
+
+	var warnings []string
+	onWarning := fstest.OnWarning(func(err error) {
+		var pathErr *fs.PathError
+		if !errors.Is(err, fstest.ErrAdded) || !errors.As(err, &pathErr) {
+			t.Errorf("unexpected warning: %v", err)
+			return
+		}
+		warnings = append(warnings, pathErr.Path)
+	})
+
+	if err := fstest.MirrorCheck(source, target, onWarning); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dir/stale", "stale"}; strings.Join(warnings, ",") != strings.Join(want, ",") {
+		t.Errorf("warnings mismatch: want=%q got=%q", want, warnings)
+	}
+	if err := fstest.MirrorCheck(target, source); !errors.Is(err, fstest.ErrRemoved) {
+		t.Errorf("expected a missing file to be reported, got %v", err)
+	}
+
+	target["dir/file"].Data = []byte("24")
+	if err := fstest.MirrorCheck(source, target); !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Errorf("expected a content mismatch, got %v", err)
+	}
+}
+
+// writerToFS returns files implementing io.WriterTo and counts the number of
+// times files are opened.
+type writerToFS struct {
+	fstest.MapFS
+	opens *int
+}
+
+func (fsys writerToFS) Open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	*fsys.opens++
+	return &writerToFile{f}, nil
+}
+
+type writerToFile struct{ fs.File }
+
+func (f *writerToFile) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, struct{ io.Reader }{f.File})
+}
+
+func TestDigestOnly(t *testing.T) {
+	newFS := func(data string, opens *int) fs.FS {
+		return writerToFS{fstest.MapFS{
+			"file": &fstest.MapFile{Mode: 0644, Data: []byte(data)},
+		}, opens}
+	}
+
+	var opens int
+	if err := fstest.EqualFS(newFS("Hello World!", &opens), newFS("Hello World!", &opens)); err != nil {
+		t.Fatal(err)
+	}
+	if opens != 4 {
+		t.Errorf("expected the files to be reopened to verify their content, got %d opens", opens)
+	}
+
+	opens = 0
+	if err := fstest.EqualFSWith(newFS("Hello World!", &opens), newFS("Hello World!", &opens), fstest.DigestOnly()); err != nil {
+		t.Fatal(err)
+	}
+	if opens != 2 {
+		t.Errorf("expected the files to be opened once, got %d opens", opens)
+	}
+
+	var equalErr *fstest.EqualError
+	err := fstest.EqualFSWith(newFS("Hello World!", &opens), newFS("Hello Tests!", &opens), fstest.DigestOnly())
+	if !errors.As(err, &equalErr) || equalErr.Kind != fstest.ContentChanged {
+		t.Errorf("expected a content difference, got %v", err)
+	}
+}
+
+func TestStructureOnly(t *testing.T) {
+	newFS := func(files map[string]string, opens *int) fs.FS {
+		fsys := fstest.MapFS{
+			"dir": &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		}
+		for name, data := range files {
+			fsys[name] = &fstest.MapFile{Mode: 0644, Data: []byte(data)}
+		}
+		return writerToFS{fsys, opens}
+	}
+
+	var opens int
+	a := newFS(map[string]string{"dir/file": "Hello World!"}, &opens)
+	b := newFS(map[string]string{"dir/file": "Hello Tests!"}, &opens)
+	if err := fstest.EqualFSWith(a, b, fstest.StructureOnly()); err != nil {
+		t.Error(err)
+	}
+	if opens != 0 {
+		t.Errorf("expected the files not to be opened, got %d opens", opens)
+	}
+
+	tests := []struct {
+		scenario string
+		files    map[string]string
+		kind     fstest.Kind
+	}{
+		{"added", map[string]string{"dir/file": "Hello World!", "dir/other": ""}, fstest.EntriesChanged},
+		{"removed", map[string]string{}, fstest.EntriesChanged},
+		{"resized", map[string]string{"dir/file": "Hello"}, fstest.SizeChanged},
+	}
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			var equalErr *fstest.EqualError
+			err := fstest.EqualFSWith(a, newFS(test.files, &opens), fstest.StructureOnly())
+			if !errors.As(err, &equalErr) || equalErr.Kind != test.kind {
+				t.Errorf("expected a difference of kind %v, got %v", test.kind, err)
+			}
+		})
+	}
+}
+
+func TestIgnoreTimes(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+
+	newFS := func(modTime, accessTime, changeTime time.Time) fstest.MapFS {
+		fsys := fstest.MapFS{
+			"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		}
+		info, _ := fs.Stat(fsys, "file")
+		fsys.SetInfo("file", timesInfo{info, modTime, accessTime, changeTime})
+		return fsys
+	}
+	a := newFS(t0, t0, t0)
+
+	tests := []struct {
+		scenario string
+		target   fstest.MapFS
+		option   fstest.EqualOption
+	}{
+		{"modification", newFS(t1, t0, t0), fstest.IgnoreModTime()},
+		{"access", newFS(t0, t1, t0), fstest.IgnoreAccessTime()},
+		{"change", newFS(t0, t0, t1), fstest.IgnoreChangeTime()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			if err := fstest.EqualFS(a, test.target); !errors.Is(err, fstest.ErrTimeMismatch) {
+				t.Errorf("expected a time mismatch, got %v", err)
+			}
+			if err := fstest.EqualFSWith(a, test.target, test.option); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRequireTimes(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0}}
+	z := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A")}}
+
+	if err := fstest.EqualFS(a, z); err != nil {
+		t.Errorf("expected missing times to be skipped by default, got %v", err)
+	}
+	if err := fstest.EqualFSWith(a, a, fstest.RequireModTime()); err != nil {
+		t.Error(err)
+	}
+	b := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0.Add(time.Second)}}
+	err := fstest.EqualFSWith(a, b, fstest.RequireModTime())
+	if !errors.Is(err, fstest.ErrTimeMismatch) || strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected the modification times to be compared, got %v", err)
+	}
+	for _, opts := range [][]fstest.EqualOption{
+		{fstest.RequireModTime()},
+		{fstest.RequireModTime(), fstest.ModTimeTolerance(time.Hour)},
+	} {
+		if err := fstest.EqualFSWith(a, z, opts...); !errors.Is(err, fstest.ErrTimeMismatch) {
+			t.Errorf("expected a time mismatch, got %v", err)
+		}
+		if err := fstest.EqualFSWith(z, a, opts...); !errors.Is(err, fstest.ErrTimeMismatch) {
+			t.Errorf("expected a time mismatch, got %v", err)
+		}
+	}
+	if err := fstest.EqualFSWith(a, z, fstest.RequireModTime(), fstest.IgnoreModTime()); err != nil {
+		t.Errorf("expected IgnoreModTime to take precedence, got %v", err)
+	}
+
+	// MapFS does not report access and change times unless configured with
+	// SetInfo.
+	if err := fstest.EqualFSWith(a, a, fstest.RequireAccessTime()); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a missing access time, got %v", err)
+	}
+	if err := fstest.EqualFSWith(a, a, fstest.RequireChangeTime()); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a missing change time, got %v", err)
+	}
+	info, _ := fs.Stat(a, "file")
+	a.SetInfo("file", timesInfo{info, t0, t0, t0})
+	if err := fstest.EqualFSWith(a, a, fstest.RequireAccessTime(), fstest.RequireChangeTime()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModTimeTolerance(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0}}
+	b := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0.Add(time.Second)}}
+	c := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0.Add(-3 * time.Second)}}
+	z := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A")}}
+
+	if err := fstest.EqualFS(a, b); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a time mismatch without the option, got %v", err)
+	}
+	tolerance := fstest.ModTimeTolerance(2 * time.Second)
+	if err := fstest.EqualFSWith(a, b, tolerance); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(b, a, tolerance); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(a, c, tolerance); !errors.Is(err, fstest.ErrTimeMismatch) {
+		t.Errorf("expected a time mismatch beyond the tolerance, got %v", err)
+	}
+	if err := fstest.EqualFSWith(a, z, tolerance); err != nil {
+		t.Errorf("zero times must be ignored: %v", err)
+	}
+
+	// The tolerance is inclusive, and applies to the times read from the
+	// system-specific information of real files as well.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "file"), t0, t0.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		delta time.Duration
+		equal bool
+	}{
+		{0, true},
+		{2 * time.Second, true},
+		{-2 * time.Second, false},
+		{-2*time.Second - time.Millisecond, false},
+	} {
+		d := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("A"), ModTime: t0.Add(test.delta)}}
+		err := fstest.EqualFSWith(d, os.DirFS(dir), tolerance, fstest.IgnoreAccessTime(), fstest.IgnoreChangeTime(), fstest.WithModeMask(0))
+		if test.equal && err != nil {
+			t.Errorf("delta=%v: %v", test.delta, err)
+		}
+		if !test.equal && !errors.Is(err, fstest.ErrTimeMismatch) {
+			t.Errorf("delta=%v: expected a time mismatch, got %v", test.delta, err)
+		}
+	}
+}
+
+type timesInfo struct {
+	fs.FileInfo
+	modTime, accessTime, changeTime time.Time
+}
+
+func (info timesInfo) ModTime() time.Time    { return info.modTime }
+func (info timesInfo) AccessTime() time.Time { return info.accessTime }
+func (info timesInfo) ChangeTime() time.Time { return info.changeTime }
+
+func TestCompareContentHash(t *testing.T) {
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: bytes.Repeat([]byte("Hello World!"), 10000)},
+	}
+	b := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: bytes.Repeat([]byte("Hello Tests!"), 10000)},
+	}
+
+	if err := fstest.EqualFSWith(a, a, fstest.CompareContentHash(sha1.New)); err != nil {
+		t.Error(err)
+	}
+	err := fstest.EqualFSWith(a, b, fstest.CompareContentHash(sha1.New))
+	if !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Fatalf("expected a content mismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("expected the error to report a digest mismatch, got %v", err)
+	}
+}
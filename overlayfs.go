@@ -0,0 +1,187 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// WhiteoutPrefix is the prefix of the names of whiteout files in the upper
+// layer of an OverlayFS.
+const WhiteoutPrefix = ".wh."
+
+// OverlayFS returns a file system merging the layers lower and upper, like the
+// overlay file systems of containers.
+//
+// The entries of upper shadow the entries of lower with the same names, and
+// the listings of directories existing in both layers are merged, the upper
+// layer winning when both contain an entry of the same name. A directory of
+// the upper layer shadowing a file of the lower layer, or a file shadowing a
+// directory, hides the entry of the lower layer entirely.
+//
+// Entries of the lower layer are deleted by whiteout files in the upper layer,
+// following the convention of OCI image layers: a file named ".wh.<name>" in a
+// directory of the upper layer hides the entry <name> of the lower layer in
+// this directory, along with all the files it contains. Whiteout files are not
+// visible in the file system, and only apply to the lower layer.
+func OverlayFS(lower, upper fs.FS) fs.FS {
+	return &overlayFS{lower: lower, upper: upper}
+}
+
+type overlayFS struct {
+	lower fs.FS
+	upper fs.FS
+}
+
+// visible returns true if the entry at name of the lower layer is visible,
+// which is the case when neither the entry nor any of its parent directories
+// are deleted by whiteout files or shadowed by non-directory files of the
+// upper layer.
+func (fsys *overlayFS) visible(name string) (bool, error) {
+	for p := name; p != "."; p = path.Dir(p) {
+		whiteout := path.Join(path.Dir(p), WhiteoutPrefix+path.Base(p))
+		if _, err := fs.Stat(fsys.upper, whiteout); err == nil {
+			return false, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		if p == name {
+			continue
+		}
+		if info, err := fs.Stat(fsys.upper, p); err == nil {
+			if !info.IsDir() {
+				return false, nil
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// resolve returns whether the entry at name is served by the upper layer (or
+// by the lower layer), and whether the entry is a directory of both layers
+// which needs to be merged. The layers are not compared directly since file
+// systems may not be comparable (e.g. MapFS).
+func (fsys *overlayFS) resolve(op, name string) (upper, merge bool, err error) {
+	if !fs.ValidPath(name) {
+		return false, false, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if strings.HasPrefix(path.Base(name), WhiteoutPrefix) {
+		return false, false, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	visible, err := fsys.visible(name)
+	if err != nil {
+		return false, false, err
+	}
+	info, err := fs.Stat(fsys.upper, name)
+	switch {
+	case err == nil:
+	case !errors.Is(err, fs.ErrNotExist):
+		return false, false, err
+	case visible:
+		return false, false, nil
+	default:
+		return false, false, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() && visible {
+		lower, err := fs.Stat(fsys.lower, name)
+		if err == nil {
+			return true, lower.IsDir(), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, false, err
+		}
+	}
+	return true, false, nil
+}
+
+func (fsys *overlayFS) layer(upper bool) fs.FS {
+	if upper {
+		return fsys.upper
+	}
+	return fsys.lower
+}
+
+func (fsys *overlayFS) Open(name string) (fs.File, error) {
+	upper, _, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if upper {
+		// Directories of the upper layer are synthesized to hide the whiteout
+		// files and merge the entries of the lower layer.
+		info, err := fs.Stat(fsys.upper, name)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			entries, err := fsys.ReadDir(name)
+			if err != nil {
+				return nil, err
+			}
+			return &mountDir{name: name, info: info, entries: entries}, nil
+		}
+	}
+	return fsys.layer(upper).Open(name)
+}
+
+func (fsys *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, merge, err := fsys.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys.layer(upper), name)
+	if err != nil || !upper {
+		return entries, err
+	}
+
+	var names, whiteouts []string
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if whiteout, ok := strings.CutPrefix(entry.Name(), WhiteoutPrefix); ok {
+			whiteouts = append(whiteouts, whiteout)
+			continue
+		}
+		names = append(names, entry.Name())
+		merged = append(merged, entry)
+	}
+	if merge {
+		lower, err := fs.ReadDir(fsys.lower, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range lower {
+			if !containsString(names, entry.Name()) && !containsString(whiteouts, entry.Name()) {
+				merged = append(merged, entry)
+			}
+		}
+	}
+	sortEntries(merged)
+	return merged, nil
+}
+
+func (fsys *overlayFS) ReadLink(name string) (string, error) {
+	upper, _, err := fsys.resolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return readLink(fsys.layer(upper), name)
+}
+
+func (fsys *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	upper, _, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys.layer(upper), name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*overlayFS)(nil)
+	_ fs.StatFS         = (*overlayFS)(nil)
+	_ fslink.ReadLinkFS = (*overlayFS)(nil)
+)
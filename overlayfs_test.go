@@ -0,0 +1,61 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOverlayFS(t *testing.T) {
+	lower := fstest.MapFS{
+		"config":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"config/base.yml": &fstest.MapFile{Mode: 0644, Data: []byte("base")},
+		"config/app.yml":  &fstest.MapFile{Mode: 0644, Data: []byte("lower")},
+		"config/old.yml":  &fstest.MapFile{Mode: 0644, Data: []byte("deleted")},
+		"data":            &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"data/file":       &fstest.MapFile{Mode: 0644, Data: []byte("deleted")},
+		"shadowed":        &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"shadowed/file":   &fstest.MapFile{Mode: 0644, Data: []byte("shadowed")},
+		"link":            &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("config/base.yml")},
+	}
+	upper := fstest.MapFS{
+		"config":             &fstest.MapFile{Mode: 0700 | fs.ModeDir},
+		"config/app.yml":     &fstest.MapFile{Mode: 0644, Data: []byte("upper")},
+		"config/.wh.old.yml": &fstest.MapFile{Mode: 0644},
+		"config/local":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"config/local/a.yml": &fstest.MapFile{Mode: 0644, Data: []byte("local")},
+		".wh.data":           &fstest.MapFile{Mode: 0644},
+		"shadowed":           &fstest.MapFile{Mode: 0644, Data: []byte("file")},
+		"link":               &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("config/app.yml")},
+	}
+	golden := fstest.MapFS{
+		"config":             &fstest.MapFile{Mode: 0700 | fs.ModeDir},
+		"config/base.yml":    &fstest.MapFile{Mode: 0644, Data: []byte("base")},
+		"config/app.yml":     &fstest.MapFile{Mode: 0644, Data: []byte("upper")},
+		"config/local":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"config/local/a.yml": &fstest.MapFile{Mode: 0644, Data: []byte("local")},
+		"shadowed":           &fstest.MapFile{Mode: 0644, Data: []byte("file")},
+		"link":               &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("config/app.yml")},
+	}
+	fsys := fstest.OverlayFS(lower, upper)
+
+	if err := fstest.EqualFS(fsys, golden); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.TestFS(fsys, "config/base.yml", "config/app.yml", "config/local/a.yml", "shadowed"); err != nil {
+		t.Error(err)
+	}
+
+	link, err := fslink.ReadLink(fsys, "link")
+	if err != nil || link != "config/app.yml" {
+		t.Errorf("symbolic link mismatch: want=%q got=%q (%v)", "config/app.yml", link, err)
+	}
+	for _, name := range []string{"config/old.yml", "config/.wh.old.yml", "data", "data/file", "shadowed/file"} {
+		if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected fs.ErrNotExist, got %v", name, err)
+		}
+	}
+}
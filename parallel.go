@@ -0,0 +1,120 @@
+package fstest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Parallel configures the comparison to compare subdirectories concurrently,
+// using up to workers goroutines. Each worker uses its own buffer of the same
+// size as the buffer of the comparison. The comparison is serial when workers
+// is less than or equal to one, which is the default.
+//
+// When a difference is found, the comparison of the directories which come
+// after it in path order is interrupted, while the comparison of the ones
+// which come before carries on so that the difference returned is the first
+// one in path order, like with a serial comparison.
+//
+// The option has no effect on the comparisons made by EqualFSAll, MirrorCheck,
+// and EqualFSReport, which are always serial. The file systems compared must
+// be safe for concurrent use.
+func Parallel(workers int) EqualOption {
+	return func(opts *equalOptions) { opts.workers = workers }
+}
+
+// parallel is the state shared by the workers of a parallel comparison.
+type parallel struct {
+	group   sync.WaitGroup
+	buffers chan []byte
+	size    int
+	mutex   sync.Mutex
+	err     error
+	path    string
+}
+
+func newParallel(workers, size int) *parallel {
+	p := &parallel{buffers: make(chan []byte, workers-1), size: size}
+	for i := 1; i < workers; i++ {
+		p.buffers <- nil
+	}
+	return p
+}
+
+// errStopped is returned by the comparisons interrupted because a difference
+// was found earlier in path order.
+var errStopped = errors.New("comparison stopped")
+
+// spawn starts the comparison of the directory at name in a new goroutine if
+// a worker is available, returning false otherwise.
+func (p *parallel) spawn(name string, compare func(buf []byte) error) bool {
+	var buf []byte
+	select {
+	case buf = <-p.buffers:
+	default:
+		return false
+	}
+	if buf == nil {
+		buf = make([]byte, p.size)
+	}
+	p.group.Add(1)
+	go func() {
+		defer p.group.Done()
+		defer func() { p.buffers <- buf }()
+		p.fail(name, compare(buf))
+	}()
+	return true
+}
+
+// wait waits for the workers to complete and returns the error found first in
+// path order, if any.
+func (p *parallel) wait(err error) error {
+	p.fail(".", err)
+	p.group.Wait()
+	return p.err
+}
+
+// fail records err if it happened earlier in path order than the error
+// recorded so far. The error is located at its path if it has one, or at the
+// path of the directory being compared otherwise.
+func (p *parallel) fail(name string, err error) {
+	if err == nil || errors.Is(err, errStopped) {
+		return
+	}
+	if errPath := errorPath(err); errPath != "" {
+		name = errPath
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.err == nil || comparePaths(name, p.path) < 0 {
+		p.err, p.path = err, name
+	}
+}
+
+// stopped returns true if an error was recorded before name in path order,
+// meaning that the comparison of name is not necessary anymore.
+func (p *parallel) stopped(name string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err != nil && comparePaths(p.path, name) < 0
+}
+
+// comparePaths compares paths in the order that the files they name are
+// visited by a depth-first traversal of directories sorted by name.
+func comparePaths(a, b string) int {
+	if a == "." {
+		a = ""
+	}
+	if b == "." {
+		b = ""
+	}
+	for a != "" && b != "" {
+		var elemA, elemB string
+		elemA, a, _ = strings.Cut(a, "/")
+		elemB, b, _ = strings.Cut(b, "/")
+		if c := strings.Compare(elemA, elemB); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,49 @@
+package fstest_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestParallel(t *testing.T) {
+	a := fstest.MapFS{}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			name := fmt.Sprintf("dir-%d/sub-%d/file", i, j)
+			a[name] = &fstest.MapFile{Mode: 0644, Data: []byte(name)}
+		}
+	}
+
+	b := fstest.MapFS{}
+	for name, file := range a {
+		b[name] = file
+	}
+
+	for _, workers := range []int{-1, 0, 1, 2, 8} {
+		if err := fstest.EqualFSWith(a, b, fstest.Parallel(workers)); err != nil {
+			t.Errorf("workers=%d: %v", workers, err)
+		}
+	}
+
+	b["dir-3/sub-7/file"] = &fstest.MapFile{Mode: 0644, Data: []byte("dir-3/sub-7/FILE")}
+	b["dir-5/sub-1/file"] = &fstest.MapFile{Mode: 0600, Data: []byte("dir-5/sub-1/file")}
+	b["dir-8/sub-2/file"] = &fstest.MapFile{Mode: 0644 | fs.ModeSymlink, Data: []byte("file")}
+
+	want := fstest.EqualFS(a, b)
+	if !errors.Is(want, fstest.ErrContentMismatch) {
+		t.Fatalf("expected a content mismatch, got %v", want)
+	}
+
+	for _, workers := range []int{1, 2, 8} {
+		for i := 0; i < 10; i++ {
+			err := fstest.EqualFSWith(a, b, fstest.Parallel(workers))
+			if err == nil || err.Error() != want.Error() {
+				t.Fatalf("workers=%d: error mismatch:\nwant: %v\ngot:  %v", workers, want, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ErrNoSpace is the error wrapped by the errors of the writes exceeding the
+// quota of the file systems returned by QuotaFS, like ENOSPC is returned when
+// writing to a full disk.
+var ErrNoSpace = errors.New("no space left on device")
+
+// QuotaFS returns a file system wrapping fsys which simulates a disk of
+// maxBytes bytes, allowing to test how code handles writes failing on a full
+// disk.
+//
+// The file system counts the bytes written by WriteFile, regardless of the
+// files being overwritten or removed later; the writes failing for other
+// reasons are not counted. A write exceeding the quota is
+// partial, like the writes to a full disk: the file is written with the bytes
+// of data which fit in the remaining space, and an error wrapping ErrNoSpace
+// is returned. All the writes of non-empty data fail once the quota is
+// exhausted. The other operations, including the creation of directories and
+// symbolic links, do not count towards the quota and are delegated to fsys.
+func QuotaFS(fsys WritableFS, maxBytes int64) WritableFS {
+	return &quotaFS{base: fsys, max: maxBytes}
+}
+
+type quotaFS struct {
+	base  WritableFS
+	max   int64
+	mutex sync.Mutex
+	used  int64
+}
+
+// reserve returns the number of bytes out of n which fit in the remaining
+// space, and accounts for them.
+func (fsys *quotaFS) reserve(n int64) int64 {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	if free := fsys.max - fsys.used; n > free {
+		n = free
+	}
+	if n < 0 {
+		n = 0
+	}
+	fsys.used += n
+	return n
+}
+
+func (fsys *quotaFS) release(n int64) {
+	fsys.mutex.Lock()
+	fsys.used -= n
+	fsys.mutex.Unlock()
+}
+
+func (fsys *quotaFS) Open(name string) (fs.File, error) {
+	return fsys.base.Open(name)
+}
+
+func (fsys *quotaFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *quotaFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys.base, name)
+}
+
+func (fsys *quotaFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *quotaFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.base, name)
+}
+
+func (fsys *quotaFS) Chtimes(name string, atime, mtime time.Time) error {
+	return fsys.base.Chtimes(name, atime, mtime)
+}
+
+func (fsys *quotaFS) Mkdir(name string, perm fs.FileMode) error {
+	return fsys.base.Mkdir(name, perm)
+}
+
+func (fsys *quotaFS) Symlink(oldname, newname string) error {
+	base, ok := fsys.base.(symlinkFS)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrSymlinkUnsupported}
+	}
+	return base.Symlink(oldname, newname)
+}
+
+func (fsys *quotaFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	n := fsys.reserve(int64(len(data)))
+	if err := fsys.base.WriteFile(name, data[:n], perm); err != nil {
+		fsys.release(n)
+		return err
+	}
+	if n < int64(len(data)) {
+		return &fs.PathError{Op: "write", Path: name, Err: ErrNoSpace}
+	}
+	return nil
+}
+
+var (
+	_ fs.ReadDirFS      = (*quotaFS)(nil)
+	_ fs.ReadFileFS     = (*quotaFS)(nil)
+	_ fs.StatFS         = (*quotaFS)(nil)
+	_ fslink.ReadLinkFS = (*quotaFS)(nil)
+	_ WritableFS        = (*quotaFS)(nil)
+)
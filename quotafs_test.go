@@ -0,0 +1,42 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestQuotaFS(t *testing.T) {
+	base := fstest.MapFS{}
+	fsys := fstest.QuotaFS(base, 10)
+
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("dir/a", []byte("Hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("dir/b", []byte("World!"), 0644); !errors.Is(err, fstest.ErrNoSpace) {
+		t.Fatalf("expected fstest.ErrNoSpace, got %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "dir/b"); err != nil || string(data) != "World" {
+		t.Errorf("expected a partial write, got %q (%v)", data, err)
+	}
+	if err := fsys.WriteFile("dir/c", []byte("!"), 0644); !errors.Is(err, fstest.ErrNoSpace) {
+		t.Errorf("expected fstest.ErrNoSpace, got %v", err)
+	}
+	if err := fsys.WriteFile("dir/d", nil, 0644); err != nil {
+		t.Errorf("writing empty files should not fail: %v", err)
+	}
+
+	// Failed writes do not consume the quota.
+	fsys = fstest.QuotaFS(base, 5)
+	if err := fsys.WriteFile("missing/file", []byte("Hello"), 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if err := fsys.WriteFile("dir/a", []byte("Hello"), 0644); err != nil {
+		t.Error(err)
+	}
+}
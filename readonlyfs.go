@@ -0,0 +1,128 @@
+package fstest
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ReadOnlyFS returns a file system passing the read operations through to fsys
+// and rejecting all the modifications with errors wrapping fs.ErrPermission,
+// which allows asserting that code does not modify a fixture, for example when
+// the fixture is shared across subtests.
+//
+// The returned file system implements the methods of MapFS modifying file
+// systems (e.g. WriteFile, Remove, or Rename), and the files it opens have
+// Write and Truncate methods, all of which fail. The ReadLink and Sub methods
+// are supported if fsys supports them; the file systems returned by Sub are
+// read-only as well.
+func ReadOnlyFS(fsys fs.FS) fs.FS {
+	return &readOnlyFS{fsys}
+}
+
+type readOnlyFS struct {
+	base fs.FS
+}
+
+func (fsys *readOnlyFS) Open(name string) (fs.File, error) {
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{f, name}, nil
+}
+
+func (fsys *readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys.base, name)
+}
+
+func (fsys *readOnlyFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.base, name)
+}
+
+func (fsys *readOnlyFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fslink.Sub(fsys.base, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFS{sub}, nil
+}
+
+func (fsys *readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return readOnlyError("chtimes", name)
+}
+
+func (fsys *readOnlyFS) Link(oldname, newname string) error {
+	return readOnlyError("link", newname)
+}
+
+func (fsys *readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (fsys *readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (fsys *readOnlyFS) Remove(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (fsys *readOnlyFS) RemoveAll(name string) error {
+	return readOnlyError("removeall", name)
+}
+
+func (fsys *readOnlyFS) Rename(oldpath, newpath string) error {
+	return readOnlyError("rename", oldpath)
+}
+
+func (fsys *readOnlyFS) Symlink(oldname, newname string) error {
+	return readOnlyError("symlink", newname)
+}
+
+func (fsys *readOnlyFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return readOnlyError("write", name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*readOnlyFS)(nil)
+	_ fs.ReadFileFS     = (*readOnlyFS)(nil)
+	_ fs.StatFS         = (*readOnlyFS)(nil)
+	_ fs.SubFS          = (*readOnlyFS)(nil)
+	_ fslink.ReadLinkFS = (*readOnlyFS)(nil)
+	_ WritableFS        = (*readOnlyFS)(nil)
+)
+
+func readOnlyError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+type readOnlyFile struct {
+	fs.File
+	name string
+}
+
+func (f *readOnlyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return d.ReadDir(n)
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+func (f *readOnlyFile) Truncate(int64) error {
+	return readOnlyError("truncate", f.name)
+}
@@ -0,0 +1,93 @@
+package fstest_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestReadOnlyFS(t *testing.T) {
+	base := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dir/file")},
+	}
+	fsys := fstest.ReadOnlyFS(base)
+
+	if err := fstest.TestFS(fsys, "dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(base, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.VerifySymlinks(fsys, map[string]string{"link": "dir/file"}); err != nil {
+		t.Error(err)
+	}
+
+	w, ok := fsys.(fstest.WritableFS)
+	if !ok {
+		t.Fatal("the read-only file system does not implement the write methods")
+	}
+	mutations := map[string]error{
+		"mkdir":   w.Mkdir("new", 0755),
+		"write":   w.WriteFile("dir/file", []byte("changed"), 0644),
+		"chtimes": w.Chtimes("dir/file", time.Now(), time.Now()),
+		"touch":   fstest.Touch(w, time.Now()),
+	}
+	for op, err := range mutations {
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: expected fs.ErrPermission, got %v", op, err)
+		}
+	}
+
+	f, err := fsys.Open("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.(io.Writer).Write([]byte("changed")); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("file write: expected fs.ErrPermission, got %v", err)
+	}
+
+	sub, err := fs.Sub(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.(fstest.WritableFS).WriteFile("file", nil, 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("sub write: expected fs.ErrPermission, got %v", err)
+	}
+
+	if err := fstest.EqualFS(fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dir/file")},
+	}, base); err != nil {
+		t.Errorf("the fixture was modified: %v", err)
+	}
+}
+
+// linkFS exposes only the Open and ReadLink methods of a MapFS, so it does not
+// implement fs.SubFS.
+type linkFS struct{ fsys fstest.MapFS }
+
+func (l linkFS) Open(name string) (fs.File, error) { return l.fsys.Open(name) }
+
+func (l linkFS) ReadLink(name string) (string, error) { return l.fsys.ReadLink(name) }
+
+func TestReadOnlyFSSubReadLink(t *testing.T) {
+	sub, err := fs.Sub(fstest.ReadOnlyFS(linkFS{fstest.MapFS{
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/link": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+	}}), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := fslink.ReadLink(sub, "link"); err != nil || link != "file" {
+		t.Errorf("sub readlink: want=file got=%q (%v)", link, err)
+	}
+}
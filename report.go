@@ -0,0 +1,82 @@
+package fstest
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// Report is a summary of the comparison of two file systems.
+type Report struct {
+	// Number of regular files, directories, and symbolic links compared.
+	Files       int
+	Directories int
+	Symlinks    int
+	// Total number of bytes read from the source file system.
+	Bytes int64
+	// Time spent comparing each non-directory file, including the time spent
+	// waiting on I/O from both file systems, in the order they were compared.
+	Timings []Timing
+	// Differences found between the file systems, sorted by path, as they
+	// would be returned by DiffFS.
+	Diffs []Diff
+}
+
+// Timing records the time spent comparing a file.
+type Timing struct {
+	Path     string
+	Duration time.Duration
+}
+
+// EqualFSReport is like EqualFSAll but it also returns a report of the
+// comparison, which allows confirming that the whole directory trees were
+// compared (rather than, say, two empty directories).
+//
+// The comparison does not stop at the first difference: the report counts all
+// the files compared, including the ones which differ, and lists the
+// differences found in its Diffs field, which are those returned by DiffFS. The
+// returned error is the one returned by EqualFSAll; when it is not an
+// EqualErrors value, the report describes the part of the file systems that
+// was compared before the error occurred.
+func EqualFSReport(a, b fs.FS, opts ...EqualOption) (Report, error) {
+	report := new(Report)
+	options := newEqualOptions(opts)
+	options.report = report
+	diffs, err := diffFS(a, b, options)
+	report.Diffs = diffs
+	return *report, err
+}
+
+// String formats the report as a human-readable summary, with one line
+// counting the files compared followed by the list of differences.
+func (r Report) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "compared %d files, %d directories, %d symbolic links (%d bytes)\n",
+		r.Files, r.Directories, r.Symlinks, r.Bytes)
+	if len(r.Diffs) == 0 {
+		s.WriteString("no differences\n")
+		return s.String()
+	}
+	fmt.Fprintf(&s, "%d differences:\n", len(r.Diffs))
+	for _, diff := range r.Diffs {
+		// Messages may span multiple lines (e.g. with TextDiff), they are
+		// indented below the line of the difference.
+		message := strings.ReplaceAll(strings.TrimSuffix(diff.Message, "\n"), "\n", "\n    ")
+		fmt.Fprintf(&s, "  %s (%s): %s\n", diff.Path, diff.Kind, message)
+	}
+	return s.String()
+}
+
+func (r *Report) observe(name string, typ fs.FileMode, start time.Time) {
+	switch typ {
+	case fs.ModeDir:
+		r.Directories++
+		return
+	case fs.ModeSymlink:
+		r.Symlinks++
+	case 0:
+		r.Files++
+	}
+	r.Timings = append(r.Timings, Timing{Path: name, Duration: time.Since(start)})
+}
@@ -0,0 +1,108 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestEqualFSReport(t *testing.T) {
+	a := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/symlink": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("file")},
+	}
+
+	report, err := fstest.EqualFSReport(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 1 || report.Directories != 2 || report.Symlinks != 1 {
+		t.Errorf("wrong number of files compared: files=%d directories=%d symlinks=%d",
+			report.Files, report.Directories, report.Symlinks)
+	}
+	if report.Bytes != 12 {
+		t.Errorf("wrong number of bytes compared: want=12 got=%d", report.Bytes)
+	}
+}
+
+func TestEqualFSReportDiffs(t *testing.T) {
+	a := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/mode": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"last":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	b := a.Clone()
+	b["dir/file"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello Tests!")}
+	b["dir/mode"] = &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!")}
+
+	report, err := fstest.EqualFSReport(a, b)
+	var errs fstest.EqualErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected two differences, got %v", err)
+	}
+	// The comparison continues after the differences, and the files which
+	// differ are counted as compared.
+	if report.Files != 3 || report.Directories != 2 {
+		t.Errorf("wrong number of files compared: files=%d directories=%d", report.Files, report.Directories)
+	}
+	if len(report.Timings) != 3 {
+		t.Errorf("wrong number of timings: %d", len(report.Timings))
+	}
+	if len(report.Diffs) != 2 {
+		t.Fatalf("wrong number of differences: %d", len(report.Diffs))
+	}
+	diffs, err := fstest.DiffFS(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Diffs, diffs) {
+		t.Errorf("differences mismatch: want=%v got=%v", diffs, report.Diffs)
+	}
+	if diff := report.Diffs[0]; diff.Path != "dir/file" || diff.Kind != fstest.ContentChanged {
+		t.Errorf("unexpected difference: %v", diff)
+	}
+	if diff := report.Diffs[1]; diff.Path != "dir/mode" || diff.Kind != fstest.ModeChanged {
+		t.Errorf("unexpected difference: %v", diff)
+	}
+
+	want := "compared 3 files, 2 directories, 0 symbolic links (24 bytes)\n" +
+		"2 differences:\n" +
+		"  dir/file (content changed): file content mismatch at offset 6: want=0x57 got=0x54\n" +
+		"  dir/mode (mode changed): file modes mismatch: want=-rw-r--r-- got=-rw-------\n"
+	if s := report.String(); s != want {
+		t.Errorf("unexpected report:\n%s\nwant:\n%s", s, want)
+	}
+
+	report, _ = fstest.EqualFSReport(a, a)
+	if s := report.String(); !strings.HasSuffix(s, "\nno differences\n") {
+		t.Errorf("unexpected report:\n%s", s)
+	}
+}
+
+func TestEqualFSReportSlowFS(t *testing.T) {
+	const delay = 10 * time.Millisecond
+
+	a := fstest.MapFS{
+		"fast": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	b := fstest.SlowFS(a, delay)
+
+	report, err := fstest.EqualFSReport(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Timings) != 1 {
+		t.Fatalf("wrong number of timings: %d", len(report.Timings))
+	}
+	// At least the open, stat, and read operations must have been delayed.
+	if timing := report.Timings[0]; timing.Duration < 3*delay {
+		t.Errorf("%s: comparison was too fast: %v", timing.Path, timing.Duration)
+	}
+}
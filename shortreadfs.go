@@ -0,0 +1,76 @@
+package fstest
+
+import (
+	"io/fs"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ShortReadFS returns a file system whose files return at most maxBytesPerRead
+// bytes from each call to Read, which is allowed by io.Reader but rarely
+// happens with in-memory file systems like MapFS. This flushes out bugs in
+// code assuming that a single call to Read fills the buffer. Values of
+// maxBytesPerRead less than 1 are treated as 1.
+//
+// The short reads never signal io.EOF early: the end of file is only reported
+// once all the data was returned. The file system does not implement
+// fs.ReadFileFS, so fs.ReadFile reads files through Open as well. It can be
+// combined with other wrappers such as FaultFS or SlowFS.
+func ShortReadFS(fsys fs.FS, maxBytesPerRead int) fs.FS {
+	if maxBytesPerRead < 1 {
+		maxBytesPerRead = 1
+	}
+	return &shortReadFS{fsys, maxBytesPerRead}
+}
+
+type shortReadFS struct {
+	base fs.FS
+	max  int
+}
+
+func (fsys *shortReadFS) Open(name string) (fs.File, error) {
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &shortReadFile{f, name, fsys.max}, nil
+}
+
+func (fsys *shortReadFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *shortReadFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *shortReadFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.base, name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*shortReadFS)(nil)
+	_ fs.StatFS         = (*shortReadFS)(nil)
+	_ fslink.ReadLinkFS = (*shortReadFS)(nil)
+)
+
+type shortReadFile struct {
+	fs.File
+	name string
+	max  int
+}
+
+func (f *shortReadFile) Read(b []byte) (int, error) {
+	if len(b) > f.max {
+		b = b[:f.max]
+	}
+	return f.File.Read(b)
+}
+
+func (f *shortReadFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return d.ReadDir(n)
+}
@@ -0,0 +1,60 @@
+package fstest_test
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestShortReadFS(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	base := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: data},
+	}
+	fsys := fstest.ShortReadFS(fstest.SlowFS(base, 0), 7)
+	if err := fstest.TestFS(fsys, "dir/file"); err != nil {
+		t.Error(err)
+	}
+
+	f, err := fsys.Open("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []byte
+	var reads int
+	buf := make([]byte, 100)
+	for {
+		n, err := f.Read(buf)
+		if n > 7 {
+			t.Fatalf("read %d bytes, expected at most 7", n)
+		}
+		got = append(got, buf[:n]...)
+		reads++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, expected the %d bytes of the file", len(got), len(data))
+	}
+	if reads < len(data)/7 {
+		t.Errorf("expected at least %d reads, got %d", len(data)/7, reads)
+	}
+
+	if b, err := fs.ReadFile(fstest.SlowFS(fsys, time.Microsecond), "dir/file"); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("fs.ReadFile returned %d bytes (%v)", len(b), err)
+	}
+}
@@ -0,0 +1,150 @@
+package fstest
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// SlowFS returns a file system which sleeps for the given delay before each
+// operation on fsys, including the reads on files that it opened.
+//
+// The wrapper is useful to test timeouts and cancellation in code reading from
+// file systems, or to simulate high-latency network mounts.
+func SlowFS(fsys fs.FS, delay time.Duration) fs.FS {
+	return SlowFSLatency(fsys, Latency{
+		Open:     delay,
+		Read:     delay,
+		ReadDir:  delay,
+		ReadLink: delay,
+		Stat:     delay,
+	})
+}
+
+// Latency configures the delays of the operations of the file systems returned
+// by SlowFSLatency and SlowFSContext.
+type Latency struct {
+	// Delays before each operation of the given type. The Stat delay applies
+	// to both the Stat method of the file system and of the files it opened,
+	// and the ReadDir delay to both the ReadDir method of the file system and
+	// of the directories it opened.
+	Open     time.Duration
+	Read     time.Duration
+	ReadDir  time.Duration
+	ReadLink time.Duration
+	Stat     time.Duration
+	// Delay for each byte returned by Read, which happens after the data was
+	// read. It simulates the bandwidth of slow network mounts.
+	PerByte time.Duration
+}
+
+// SlowFSLatency is like SlowFS but the delays of operations are configured by
+// latency.
+func SlowFSLatency(fsys fs.FS, latency Latency) fs.FS {
+	return SlowFSContext(context.Background(), fsys, latency)
+}
+
+// SlowFSContext is like SlowFSLatency but the delays are interrupted when ctx
+// is canceled, in which case the operations return the context error. The
+// operations invoked after ctx was canceled also return the context error,
+// without being delegated to fsys.
+func SlowFSContext(ctx context.Context, fsys fs.FS, latency Latency) fs.FS {
+	return &slowFS{fsys, latency, ctx}
+}
+
+type slowFS struct {
+	base    fs.FS
+	latency Latency
+	ctx     context.Context
+}
+
+func (fsys *slowFS) wait(delay time.Duration) error {
+	if delay <= 0 {
+		return fsys.ctx.Err()
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-fsys.ctx.Done():
+		return fsys.ctx.Err()
+	}
+}
+
+func (fsys *slowFS) Open(name string) (fs.File, error) {
+	if err := fsys.wait(fsys.latency.Open); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &slowFile{f, name, fsys}, nil
+}
+
+func (fsys *slowFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := fsys.wait(fsys.latency.ReadDir); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return fs.ReadDir(fsys.base, name)
+}
+
+func (fsys *slowFS) ReadLink(name string) (string, error) {
+	if err := fsys.wait(fsys.latency.ReadLink); err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return readLink(fsys.base, name)
+}
+
+func (fsys *slowFS) Stat(name string) (fs.FileInfo, error) {
+	if err := fsys.wait(fsys.latency.Stat); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return fs.Stat(fsys.base, name)
+}
+
+var (
+	_ fs.ReadDirFS      = (*slowFS)(nil)
+	_ fs.StatFS         = (*slowFS)(nil)
+	_ fslink.ReadLinkFS = (*slowFS)(nil)
+)
+
+type slowFile struct {
+	fs.File
+	name string
+	fsys *slowFS
+}
+
+func (f *slowFile) Read(b []byte) (int, error) {
+	if err := f.fsys.wait(f.fsys.latency.Read); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	n, err := f.File.Read(b)
+	if n > 0 && f.fsys.latency.PerByte > 0 {
+		if err := f.fsys.wait(time.Duration(n) * f.fsys.latency.PerByte); err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+	}
+	return n, err
+}
+
+func (f *slowFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.fsys.wait(f.fsys.latency.ReadDir); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+	}
+	return d.ReadDir(n)
+}
+
+func (f *slowFile) Stat() (fs.FileInfo, error) {
+	if err := f.fsys.wait(f.fsys.latency.Stat); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+	}
+	return f.File.Stat()
+}
@@ -0,0 +1,61 @@
+package fstest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestSlowFSLatency(t *testing.T) {
+	base := fstest.MapFS{
+		"small": &fstest.MapFile{Mode: 0644, Data: make([]byte, 10)},
+		"large": &fstest.MapFile{Mode: 0644, Data: make([]byte, 1000)},
+	}
+	fsys := fstest.SlowFSLatency(base, fstest.Latency{PerByte: 50 * time.Microsecond})
+
+	elapsed := func(name string) time.Duration {
+		start := time.Now()
+		if _, err := fs.ReadFile(fsys, name); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	if d := elapsed("large"); d < 50*time.Millisecond {
+		t.Errorf("reading 1000 bytes was too fast: %v", d)
+	}
+	if d := elapsed("small"); d >= 50*time.Millisecond {
+		t.Errorf("reading 10 bytes was too slow: %v", d)
+	}
+}
+
+func TestSlowFSContext(t *testing.T) {
+	base := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fsys := fstest.SlowFSContext(ctx, base, fstest.Latency{Read: time.Hour})
+
+	f, err := fsys.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := io.ReadAll(f); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if d := time.Since(start); d > time.Minute {
+		t.Errorf("read was not interrupted: %v", d)
+	}
+	if _, err := fs.Stat(fsys, "file"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled after cancellation, got %v", err)
+	}
+}
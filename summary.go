@@ -0,0 +1,51 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Exit codes returned by EqualFSMain.
+const (
+	ExitEqual       = 0
+	ExitDifferences = 1
+	ExitError       = 2
+)
+
+// EqualFSMain compares two file systems, prints a summary of the comparison to
+// stderr, and returns an exit code: ExitEqual (0) if the file systems are
+// equal, ExitDifferences (1) if differences were found, or ExitError (2) if
+// the comparison failed.
+//
+// The function is intended to be used in the main function of command line
+// tools comparing file systems, it does not exit the program:
+//
+//	os.Exit(fstest.EqualFSMain(os.DirFS(want), os.DirFS(got)))
+func EqualFSMain(a, b fs.FS, opts ...EqualOption) int {
+	return equalFSMain(os.Stderr, a, b, opts...)
+}
+
+func equalFSMain(w io.Writer, a, b fs.FS, opts ...EqualOption) int {
+	err := EqualFSAll(a, b, opts...)
+	if err == nil {
+		fmt.Fprintln(w, "file systems are equal")
+		return ExitEqual
+	}
+	var errs EqualErrors
+	if !errors.As(err, &errs) {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return ExitError
+	}
+	for _, err := range errs {
+		fmt.Fprintln(w, err)
+	}
+	if len(errs) == 1 {
+		fmt.Fprintln(w, "1 difference found")
+	} else {
+		fmt.Fprintf(w, "%d differences found\n", len(errs))
+	}
+	return ExitDifferences
+}
@@ -0,0 +1,34 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+type errorFS struct{ err error }
+
+func (fsys errorFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fsys.err}
+}
+
+func TestEqualFSMain(t *testing.T) {
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	b := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello Tests!")},
+	}
+
+	if code := fstest.EqualFSMain(a, a); code != fstest.ExitEqual {
+		t.Errorf("exit code mismatch: want=%d got=%d", fstest.ExitEqual, code)
+	}
+	if code := fstest.EqualFSMain(a, b); code != fstest.ExitDifferences {
+		t.Errorf("exit code mismatch: want=%d got=%d", fstest.ExitDifferences, code)
+	}
+	if code := fstest.EqualFSMain(a, errorFS{errors.New("boom")}); code != fstest.ExitError {
+		t.Errorf("exit code mismatch: want=%d got=%d", fstest.ExitError, code)
+	}
+}
@@ -0,0 +1,392 @@
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// SymlinkFixture returns a MapFS containing exactly the symbolic links of the
+// links map, where keys are the paths of the links and values their targets,
+// and the directories leading to them.
+//
+// Symbolic links are created with mode 0777, and directories with mode 0755.
+// The targets are not required to exist. The function panics if a key of
+// links is not a valid path, or if a link is the parent of another.
+func SymlinkFixture(links map[string]string) MapFS {
+	fsys := make(MapFS, len(links))
+	for name, target := range links {
+		if !fs.ValidPath(name) || name == "." {
+			panic("fstest.SymlinkFixture: invalid path: " + name)
+		}
+		fsys[name] = &MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte(target)}
+	}
+	for name := range links {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, isLink := links[dir]; isLink {
+				panic("fstest.SymlinkFixture: symbolic link is the parent of another: " + dir)
+			}
+			fsys[dir] = &MapFile{Mode: fs.ModeDir | 0755}
+		}
+	}
+	return fsys
+}
+
+// VerifySymlinks verifies that each of the symbolic links of fsys named by the
+// keys of links reads back the target of the map value.
+//
+// The targets are read with the ReadLink method of fsys, so absolute and empty
+// targets round-trip, while fslink.ReadLink rejects them.
+//
+// All the links are verified, the returned error lists the ones which could not
+// be read or had a different target.
+func VerifySymlinks(fsys fs.FS, links map[string]string) error {
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		target, err := readLink(fsys, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if target != links[name] {
+			errs = append(errs, checkErrorf(name, "symbolic link target mismatch: want=%q got=%q", links[name], target))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckSymlinks walks fsys and resolves each of the symbolic links it contains,
+// returning an error listing the links which cannot be resolved, or nil if all
+// the links lead to existing files. This is useful to validate fixtures before
+// running code following symbolic links, which could loop endlessly on cycles.
+//
+// The errors name the offending links. Links forming cycles, or expanding to
+// paths through more than 255 symbolic links, are reported with errors
+// matching ErrSymlinkCycle, and dangling links with errors matching
+// fs.ErrNotExist. Links with absolute or empty targets, or targets outside of
+// fsys, cannot be resolved within fsys and are reported as well.
+func CheckSymlinks(fsys fs.FS) error {
+	follow := &followFS{base: fsys}
+	var errs []error
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type() != fs.ModeSymlink {
+			return err
+		}
+		resolved, err := follow.resolve("check", name)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if _, err := fs.Stat(fsys, resolved); err != nil {
+			errs = append(errs, checkErrorf(name, "dangling symbolic link to %q: %w", resolved, fs.ErrNotExist))
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// FollowSymlinks configures the comparison to follow symbolic links, comparing
+// the types and contents of the files they point to instead of their targets.
+// Symbolic links to directories are traversed. This allows comparing a file
+// system with one where the links were replaced by copies of their targets.
+//
+// The targets are resolved relative to the directory containing the links, and
+// must remain within the file systems. Broken links, links with absolute or
+// empty targets, and links leading to cycles are reported as errors; the
+// errors of cycles match ErrSymlinkCycle.
+func FollowSymlinks() EqualOption {
+	return func(opts *equalOptions) { opts.followSymlinks = true }
+}
+
+// TreatSymlinkAsTarget configures the comparison to consider a symbolic link
+// equal to a regular file of the other file system when the link refers to a
+// regular file equal to it, which is how some deployment tools dereference
+// links. The file the link refers to is compared with the regular file like
+// two regular files would be, including their modes, sizes, and contents.
+//
+// Unlike FollowSymlinks, the option only applies when the types of the files
+// differ: symbolic links are still compared by target when both file systems
+// have a link at the same path, and links to directories are not traversed.
+// The type mismatch is reported when the link cannot be resolved or does not
+// refer to a regular file.
+func TreatSymlinkAsTarget() EqualOption {
+	return func(opts *equalOptions) { opts.symlinkAsTarget = true }
+}
+
+// equalSymlinkAsTarget compares the file at name, which is a symbolic link in
+// one of the file systems and a regular file in the other, returning false if
+// the link does not refer to a regular file.
+func equalSymlinkAsTarget(source, target fs.FS, name string, sourceType fs.FileMode, buf []byte, opts *equalOptions) (bool, error) {
+	link := target
+	if sourceType == fs.ModeSymlink {
+		link = source
+	}
+	follow := &followFS{base: link}
+	info, err := follow.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+	if sourceType == fs.ModeSymlink {
+		source = follow
+	} else {
+		target = follow
+	}
+	return true, equalFile(source, target, name, buf, opts)
+}
+
+// ErrSymlinkCycle is returned when symbolic links cannot be followed because
+// they form a cycle.
+var ErrSymlinkCycle = errors.New("symlink cycle detected")
+
+var (
+	errSymlinkAbsolute = errors.New("symbolic link target is absolute")
+	errSymlinkEmpty    = errors.New("symbolic link target is empty")
+	errSymlinkEscape   = errors.New("symbolic link target is outside the file system")
+)
+
+// readLink reads the target of the symbolic link at name with the ReadLink
+// method of fsys. Unlike fslink.ReadLink, it does not reject the targets which
+// are not valid relative paths: the wrappers forward them unchanged, and the
+// checks can report them accurately.
+func readLink(fsys fs.FS, name string) (string, error) {
+	if f, ok := fsys.(fslink.ReadLinkFS); ok {
+		return f.ReadLink(name)
+	}
+	return fslink.ReadLink(fsys, name)
+}
+
+// maxSymlinks is the maximum number of symbolic links followed to resolve a
+// path, which bounds the resolution of links expanding to ever-growing paths.
+const maxSymlinks = 255
+
+// followFS is a file system which presents the symbolic links of its base file
+// system as the files they point to.
+type followFS struct {
+	base fs.FS
+}
+
+// resolve returns the name of the file at name once all the symbolic links
+// leading to it are resolved.
+func (fsys *followFS) resolve(op, name string) (string, error) {
+	visited := make(map[string]struct{})
+	for resolved, rest := ".", name; ; {
+		if rest == "." || rest == "" {
+			return resolved, nil
+		}
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		link := path.Join(resolved, elem)
+		target, err := readLink(fsys.base, link)
+		if err != nil {
+			// Not a symbolic link, or a file which does not exist, in which
+			// case the error is reported when opening it.
+			resolved = link
+			continue
+		}
+		switch {
+		case path.IsAbs(target):
+			return "", &fs.PathError{Op: op, Path: link, Err: errSymlinkAbsolute}
+		case target == "":
+			return "", &fs.PathError{Op: op, Path: link, Err: errSymlinkEmpty}
+		}
+		expanded := path.Join(path.Dir(link), target, rest)
+		if !fs.ValidPath(expanded) {
+			return "", &fs.PathError{Op: op, Path: link, Err: errSymlinkEscape}
+		}
+		if _, seen := visited[expanded]; seen || len(visited) == maxSymlinks {
+			return "", &fs.PathError{Op: op, Path: name, Err: ErrSymlinkCycle}
+		}
+		visited[expanded] = struct{}{}
+		resolved, rest = ".", expanded
+	}
+}
+
+func (fsys *followFS) Open(name string) (fs.File, error) {
+	resolved, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.base.Open(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if path.Base(resolved) != path.Base(name) {
+		f = &renamedFile{f, path.Base(name)}
+	}
+	return f, nil
+}
+
+func (fsys *followFS) Stat(name string) (fs.FileInfo, error) {
+	resolved, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(fsys.base, resolved)
+	if err != nil {
+		return nil, err
+	}
+	if path.Base(resolved) != path.Base(name) {
+		info = &renamedInfo{info, path.Base(name)}
+	}
+	return info, nil
+}
+
+func (fsys *followFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, err := fsys.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys.base, resolved)
+	if err != nil {
+		return nil, err
+	}
+	// The entries are modified, they must not be shared with the base file
+	// system.
+	entries = append([]fs.DirEntry(nil), entries...)
+	for i, entry := range entries {
+		if entry.Type() != fs.ModeSymlink {
+			continue
+		}
+		link := path.Join(name, entry.Name())
+		target, err := fsys.resolve("readdir", link)
+		if err != nil {
+			return nil, err
+		}
+		if fsys.cycle(name, target) {
+			return nil, &fs.PathError{Op: "readdir", Path: link, Err: ErrSymlinkCycle}
+		}
+		info, err := fs.Stat(fsys.base, target)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = fs.FileInfoToDirEntry(&renamedInfo{info, entry.Name()})
+	}
+	return entries, nil
+}
+
+// cycle returns true if target, the resolved name of a symbolic link found in
+// the directory at name, is the directory or one of the directories leading to
+// it, which would make the traversal of the file system endless.
+func (fsys *followFS) cycle(name, target string) bool {
+	for dir := name; ; dir = path.Dir(dir) {
+		resolved, err := fsys.resolve("readdir", dir)
+		if err == nil && (target == "." || target == resolved || strings.HasPrefix(resolved, target+"/")) {
+			return true
+		}
+		if dir == "." {
+			return false
+		}
+	}
+}
+
+var (
+	_ fs.ReadDirFS = (*followFS)(nil)
+	_ fs.StatFS    = (*followFS)(nil)
+)
+
+// renamedFile is a file opened under a different name than the one reported by
+// its Stat method.
+type renamedFile struct {
+	fs.File
+	name string
+}
+
+func (f *renamedFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &renamedInfo{info, f.name}, nil
+}
+
+func (f *renamedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+}
+
+// TestReadLinkFS is like TestFS but it also tests the implementation of the
+// ReadLink method of fsys.
+//
+// The symbolic links are discovered by walking fsys. The function verifies
+// that ReadLink returns a non-empty target for each of them, and the same
+// target on repeated calls and through fslink.Sub; that it returns errors
+// wrapping fs.ErrInvalid for the files which are not symbolic links, and
+// fs.ErrNotExist for names which do not exist; and that it rejects invalid
+// paths.
+func TestReadLinkFS(fsys fslink.ReadLinkFS, expected ...string) error {
+	errs := []error{TestFS(fsys, expected...)}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		errs = append(errs, testReadLink(fsys, name, d.Type()))
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, name := range []string{"missing.fstest", "missing.fstest/file"} {
+		if _, err := fsys.ReadLink(name); !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, checkErrorf(name, "readlink of a missing file: want fs.ErrNotExist got %v", err))
+		}
+	}
+	for _, name := range []string{"/", "/link", "../link", "a/../link", "a//link", "a/"} {
+		if _, err := fsys.ReadLink(name); err == nil {
+			errs = append(errs, checkErrorf(name, "readlink of an invalid path did not fail"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func testReadLink(fsys fslink.ReadLinkFS, name string, typ fs.FileMode) error {
+	target, err := fsys.ReadLink(name)
+	if typ != fs.ModeSymlink {
+		if !errors.Is(err, fs.ErrInvalid) {
+			return checkErrorf(name, "readlink of a %v file: want fs.ErrInvalid got %v", typ, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return checkErrorf(name, "readlink: %v", err)
+	}
+	if target == "" {
+		return checkErrorf(name, "readlink returned an empty target")
+	}
+	if again, err := fsys.ReadLink(name); err != nil || again != target {
+		return checkErrorf(name, "readlink is not consistent: want %q got %q (%v)", target, again, err)
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		return nil
+	}
+	// The views returned by fslink.Sub for file systems which do not implement
+	// fs.SubFS reject absolute targets, like fslink.ReadLink does.
+	if path.IsAbs(target) {
+		return nil
+	}
+	sub, err := fslink.Sub(fsys, path.Clean(dir))
+	if err != nil {
+		return checkErrorf(name, "sub: %v", err)
+	}
+	if subTarget, err := readLink(sub, base); err != nil || subTarget != target {
+		return checkErrorf(name, "readlink through fs.Sub mismatch: want %q got %q (%v)", target, subTarget, err)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestSymlinkFixture(t *testing.T) {
+	links := map[string]string{
+		"link":          "target",
+		"dir/link":      "../link",
+		"dir/sub/link":  "/absolute/path",
+		"dir/sub/other": "",
+	}
+	fsys := fstest.SymlinkFixture(links)
+
+	if len(fsys) != 6 {
+		t.Errorf("number of entries mismatch: want=6 got=%d", len(fsys))
+	}
+	for _, dir := range []string{"dir", "dir/sub"} {
+		if f := fsys[dir]; f == nil || f.Mode != fs.ModeDir|0755 {
+			t.Errorf("%s: missing parent directory", dir)
+		}
+	}
+	if f := fsys["link"]; f == nil || f.Mode != fs.ModeSymlink|0777 {
+		t.Errorf("link: invalid symbolic link")
+	}
+	if err := fstest.VerifySymlinks(fsys, links); err != nil {
+		t.Fatal(err)
+	}
+
+	// The wrappers forward the targets of the links unchanged, including the
+	// absolute and empty ones.
+	wrappers := map[string]fs.FS{
+		"cache":    fstest.CacheFS(fsys),
+		"case":     fstest.CaseInsensitiveFS(fsys),
+		"context":  fstest.NewContextFS(fsys),
+		"counting": fstest.NewCountingFS(fsys),
+		"fault":    fstest.NewFaultFS(fsys),
+		"mount":    fstest.MountFS(map[string]fs.FS{".": fsys}),
+		"overlay":  fstest.OverlayFS(fsys, fstest.MapFS{}),
+		"readonly": fstest.ReadOnlyFS(fsys),
+		"slow":     fstest.SlowFS(fsys, 0),
+		"time":     fstest.FixedTimeFS(fsys, time.Unix(0, 0)),
+	}
+	for name, wrapper := range wrappers {
+		if err := fstest.VerifySymlinks(wrapper, links); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if err := fstest.EqualFS(fsys, fstest.ReadOnlyFS(fsys)); err != nil {
+		t.Error(err)
+	}
+
+	fsys["dir/link"].Data = []byte("../other")
+	delete(fsys, "link")
+
+	err := fstest.VerifySymlinks(fsys, links)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected an error for the missing link, got %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "dir/link" {
+		t.Errorf("expected an error for the changed link, got %v", err)
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	a := fstest.MapFS{
+		"data/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":      &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("data/file")},
+		"dirlink":   &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("data")},
+		"chain":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dirlink/file")},
+	}
+
+	b := fstest.MapFS{
+		"data/file":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":         &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dirlink/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"chain":        &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("./data/../link")},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected a difference without the option")
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.FollowSymlinks()); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(b, a, fstest.FollowSymlinks()); err != nil {
+		t.Error(err)
+	}
+
+	b["dirlink/file"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World?")}
+	if err := fstest.EqualFSWith(a, b, fstest.FollowSymlinks()); !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Errorf("expected a content mismatch, got %v", err)
+	}
+}
+
+func TestTreatSymlinkAsTarget(t *testing.T) {
+	a := fstest.MapFS{
+		"app/config":   &fstest.MapFile{Mode: 0644, Data: []byte("debug=true")},
+		"app/data":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("../shared")},
+		"link":         &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("app")},
+		"shared":       &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"store":        &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"store/config": &fstest.MapFile{Mode: 0644, Data: []byte("debug=true")},
+	}
+	b := fstest.MapFS{
+		"app/config":   &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("../store/config")},
+		"app/data":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"link":         &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("app")},
+		"shared":       &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"store":        &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"store/config": &fstest.MapFile{Mode: 0644, Data: []byte("debug=true")},
+	}
+
+	if err := fstest.EqualFS(a, b); !errors.Is(err, fstest.ErrTypeMismatch) {
+		t.Errorf("expected a type mismatch without the option, got %v", err)
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.TreatSymlinkAsTarget()); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(b, a, fstest.TreatSymlinkAsTarget()); err != nil {
+		t.Error(err)
+	}
+
+	b["app/data"] = &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!")}
+	if err := fstest.EqualFSWith(a, b, fstest.TreatSymlinkAsTarget()); !errors.Is(err, fstest.ErrModeMismatch) {
+		t.Errorf("expected a mode mismatch, got %v", err)
+	}
+	b["app/data"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World?")}
+	if err := fstest.EqualFSWith(a, b, fstest.TreatSymlinkAsTarget()); !errors.Is(err, fstest.ErrContentMismatch) {
+		t.Errorf("expected a content mismatch, got %v", err)
+	}
+
+	// Links to directories and broken links are still type mismatches.
+	b["app/data"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")}
+	a["app/data"] = &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("../store")}
+	if err := fstest.EqualFSWith(a, b, fstest.TreatSymlinkAsTarget()); !errors.Is(err, fstest.ErrTypeMismatch) {
+		t.Errorf("expected a type mismatch for the link to a directory, got %v", err)
+	}
+	a["app/data"] = &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("../missing")}
+	if err := fstest.EqualFSWith(a, b, fstest.TreatSymlinkAsTarget()); !errors.Is(err, fstest.ErrTypeMismatch) {
+		t.Errorf("expected a type mismatch for the broken link, got %v", err)
+	}
+}
+
+func TestFollowSymlinksErrors(t *testing.T) {
+	tests := []struct {
+		scenario string
+		links    map[string]string
+		err      error
+	}{
+		{"broken", map[string]string{"link": "missing"}, fs.ErrNotExist},
+		{"loop", map[string]string{"link": "link"}, fstest.ErrSymlinkCycle},
+		{"cycle", map[string]string{"a": "b", "b": "a"}, fstest.ErrSymlinkCycle},
+		{"parent", map[string]string{"dir/up": ".."}, fstest.ErrSymlinkCycle},
+		{"siblings", map[string]string{"a/link": "../b", "b/link": "../a"}, fstest.ErrSymlinkCycle},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			fsys := fstest.SymlinkFixture(test.links)
+			if err := fstest.EqualFSWith(fsys, fsys, fstest.FollowSymlinks()); !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestTestReadLinkFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"dir/link": &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+		"link":     &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("dir")},
+	}
+	if err := fstest.TestReadLinkFS(fsys, "dir/file", "dir/link", "link"); err != nil {
+		t.Error(err)
+	}
+	// File systems which do not implement fs.SubFS, and links with absolute
+	// targets, are valid.
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dir", "file"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"dir/link":     "file",
+		"dir/absolute": filepath.Join(dir, "dir", "file"),
+		"link":         "dir",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fstest.TestReadLinkFS(dirReadLinkFS(dir), "dir/file", "dir/link", "dir/absolute", "link"); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.TestReadLinkFS(brokenReadLinkFS{fsys}); err == nil {
+		t.Error("expected errors for the broken implementation of ReadLink")
+	}
+}
+
+// dirReadLinkFS is a directory of the local file system implementing only the
+// Open, ReadLink, and Lstat methods; in particular, it does not implement
+// fs.SubFS. Lstat is required by the checks of the standard library for the
+// file systems having a ReadLink method.
+type dirReadLinkFS string
+
+func (dir dirReadLinkFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Lstat(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
+func (dir dirReadLinkFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(dir)).Open(name)
+}
+
+func (dir dirReadLinkFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	path := filepath.Join(string(dir), filepath.FromSlash(name))
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Type() != fs.ModeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Readlink(path)
+}
+
+// brokenReadLinkFS returns the content of files as link targets.
+type brokenReadLinkFS struct{ fstest.MapFS }
+
+func (fsys brokenReadLinkFS) ReadLink(name string) (string, error) {
+	data, err := fs.ReadFile(fsys.MapFS, name)
+	return string(data), err
+}
+
+func TestCheckSymlinks(t *testing.T) {
+	fsys := fstest.SymlinkFixture(map[string]string{
+		"ok":       "dir/file",
+		"dir/up":   "../ok",
+		"dir/loop": "loop",
+		"a":        "b",
+		"b":        "a",
+		"dangling": "missing",
+		"absolute": "/etc/hosts",
+		"empty":    "",
+	})
+	fsys["dir/file"] = &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")}
+
+	err := fstest.CheckSymlinks(fsys)
+	if !errors.Is(err, fstest.ErrSymlinkCycle) {
+		t.Errorf("expected a symlink cycle, got %v", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a dangling symlink, got %v", err)
+	}
+
+	var offending []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		offending = append(offending, pathErr.Path)
+		// Absolute and empty targets are reported as such rather than as
+		// dangling links.
+		if name := pathErr.Path; (name == "absolute" || name == "empty") && (errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), name)) {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	want := []string{"a", "absolute", "b", "dangling", "dir/loop", "empty"}
+	if !reflect.DeepEqual(offending, want) {
+		t.Errorf("offending links mismatch: want=%q got=%q", want, offending)
+	}
+
+	for _, name := range want {
+		delete(fsys, name)
+	}
+	if err := fstest.CheckSymlinks(fsys); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,240 @@
+package fstest
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// WriteTar writes the files of fsys to w as a tar archive.
+//
+// The archive is deterministic: entries are written in lexical order, and only
+// the names, types, permissions, sizes, modification times, and symbolic link
+// targets of files are recorded, without ownership information. The PAX format
+// is used to preserve the sub-second precision of modification times.
+//
+// Regular files, directories, symbolic links, devices, and named pipes are
+// supported; the function returns an error if fsys contains other types of
+// files.
+func WriteTar(w io.Writer, fsys fs.FS) error {
+	return writeTar(w, fsys, newEqualOptions(nil))
+}
+
+func writeTar(w io.Writer, fsys fs.FS, opts *equalOptions) error {
+	fsys = opts.wrap(fsys)
+	tw := tar.NewWriter(w)
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if !opts.match(name, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if opts.filesOnly && !entry.Type().IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tarHeader(fsys, name, info)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeReg {
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func tarHeader(fsys fs.FS, name string, info fs.FileInfo) (*tar.Header, error) {
+	mode := info.Mode()
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(mode.Perm()),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+	if mode&fs.ModeSetuid != 0 {
+		header.Mode |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		header.Mode |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		header.Mode |= 01000
+	}
+	switch mode.Type() {
+	case 0:
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+	case fs.ModeDir:
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+	case fs.ModeSymlink:
+		link, err := readLink(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = link
+	case fs.ModeDevice:
+		header.Typeflag = tar.TypeBlock
+	case fs.ModeDevice | fs.ModeCharDevice:
+		header.Typeflag = tar.TypeChar
+	case fs.ModeNamedPipe:
+		header.Typeflag = tar.TypeFifo
+	default:
+		return nil, &fs.PathError{Op: "tar", Path: name, Err: fmt.Errorf("unsupported file type: %v", mode.Type())}
+	}
+	return header, nil
+}
+
+// ReadMapFSFromTar reads the tar archive from r and returns a MapFS holding its
+// entries, which allows storing fixtures as tar files.
+//
+// Regular files, directories, symbolic links, devices, and named pipes are
+// loaded with their permissions and modification times. Hard links are loaded
+// as entries sharing the *MapFile of their target, like MapFS.Link. The
+// function returns an error if the archive contains other types of entries,
+// or names which are not valid paths.
+//
+// Archives written by WriteTar are read back as file systems equal to the ones
+// they were written from.
+func ReadMapFSFromTar(r io.Reader) (MapFS, error) {
+	fsys := make(MapFS)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				return fsys, nil
+			}
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if !fs.ValidPath(name) {
+			return nil, &fs.PathError{Op: "untar", Path: header.Name, Err: fs.ErrInvalid}
+		}
+		if name == "." {
+			continue
+		}
+		file := &MapFile{Mode: header.FileInfo().Mode(), ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			file.Data = make([]byte, header.Size)
+			if _, err := io.ReadFull(tr, file.Data); err != nil {
+				return nil, &fs.PathError{Op: "untar", Path: name, Err: err}
+			}
+		case tar.TypeSymlink:
+			file.Data = []byte(header.Linkname)
+		case tar.TypeLink:
+			file = fsys[path.Clean(header.Linkname)]
+			if file == nil {
+				return nil, &fs.PathError{Op: "untar", Path: name, Err: fs.ErrNotExist}
+			}
+		case tar.TypeDir, tar.TypeBlock, tar.TypeChar, tar.TypeFifo:
+		default:
+			return nil, &fs.PathError{Op: "untar", Path: name, Err: fmt.Errorf("unsupported tar entry type: %q", header.Typeflag)}
+		}
+		fsys[name] = file
+	}
+}
+
+// EqualViaTar compares two file systems by serializing them to tar archives
+// with WriteTar and comparing the archives, returning an error describing the
+// first differing tar header or data region.
+//
+// This is a different lens on the comparison made by EqualFSWith, which can be
+// easier to reason about when developing archivers, and cross-validates the
+// determinism of WriteTar: both functions disagreeing on whether file systems
+// are equal is the sign of a bug. The Include, Exclude, FilesOnly, and
+// ImplicitDirs options are applied to the files written to the archives, other
+// options are ignored.
+//
+// The archives are streamed and compared entry by entry, without being held in
+// memory. Differences are reported as errors wrapping *EqualError, like the
+// other comparison functions.
+func EqualViaTar(a, b fs.FS, opts ...EqualOption) error {
+	options := newEqualOptions(opts)
+	sourceStream := tarStream(a, options)
+	defer sourceStream.Close()
+	targetStream := tarStream(b, options)
+	defer targetStream.Close()
+
+	source := tar.NewReader(sourceStream)
+	target := tar.NewReader(targetStream)
+	buf := make([]byte, equalFSBufSize)
+
+	for i := 0; ; i++ {
+		sourceHeader, err1 := source.Next()
+		targetHeader, err2 := target.Next()
+		if err1 != nil && err1 != io.EOF {
+			return err1
+		}
+		if err2 != nil && err2 != io.EOF {
+			return err2
+		}
+		switch {
+		case err1 == io.EOF && err2 == io.EOF:
+			return nil
+		case err1 == io.EOF:
+			return equalErrorf(targetHeader.Name, Added, "tar entry %d added: %q", i, targetHeader.Name)
+		case err2 == io.EOF:
+			return equalErrorf(sourceHeader.Name, Removed, "tar entry %d removed: %q", i, sourceHeader.Name)
+		}
+		if err := equalTarHeader(i, sourceHeader, targetHeader); err != nil {
+			return err
+		}
+		if err := equalData(source, target, buf, options); err != nil {
+			return equalError(sourceHeader.Name, err)
+		}
+	}
+}
+
+func tarStream(fsys fs.FS, opts *equalOptions) *io.PipeReader {
+	r, w := io.Pipe()
+	go func() { w.CloseWithError(writeTar(w, fsys, opts)) }()
+	return r
+}
+
+func equalTarHeader(i int, source, target *tar.Header) error {
+	name := source.Name
+	switch {
+	case source.Name != target.Name:
+		return equalError(name, mismatchf(EntriesChanged, "%q", source.Name, target.Name, "tar header %d name mismatch", i))
+	case source.Typeflag != target.Typeflag:
+		return equalError(name, mismatchf(TypeChanged, "%q", source.Typeflag, target.Typeflag, "tar header %d type mismatch", i))
+	case source.Mode != target.Mode:
+		return equalError(name, mismatchf(ModeChanged, "%#o", source.Mode, target.Mode, "tar header %d mode mismatch", i))
+	case !source.ModTime.Equal(target.ModTime):
+		return equalError(name, mismatchf(TimeChanged, "%v", source.ModTime, target.ModTime, "tar header %d modification time mismatch", i))
+	case source.Size != target.Size:
+		return equalError(name, mismatchf(SizeChanged, "%d", source.Size, target.Size, "tar header %d size mismatch", i))
+	case source.Linkname != target.Linkname:
+		return equalError(name, mismatchf(SymlinkChanged, "%q", source.Linkname, target.Linkname, "tar header %d link mismatch", i))
+	}
+	return nil
+}
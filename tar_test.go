@@ -0,0 +1,174 @@
+package fstest_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWriteTar(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 42, time.UTC)
+	fsys := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime},
+		"dir":      &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"dir/file": &fstest.MapFile{Mode: 0600, ModTime: modTime},
+		"link":     &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("file"), ModTime: modTime},
+		"hosts":    &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("/etc/hosts"), ModTime: modTime},
+	}
+
+	var b1, b2 bytes.Buffer
+	if err := fstest.WriteTar(&b1, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.WriteTar(&b2, fsys); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Error("tar archives of the same file system differ")
+	}
+
+	// Absolute targets of symbolic links are stored as-is.
+	r := tar.NewReader(&b1)
+	for {
+		header, err := r.Next()
+		if err != nil {
+			t.Fatalf("symbolic link not found in the archive: %v", err)
+		}
+		if header.Name == "hosts" {
+			if header.Linkname != "/etc/hosts" {
+				t.Errorf("link target mismatch: want=/etc/hosts got=%q", header.Linkname)
+			}
+			break
+		}
+	}
+}
+
+func TestEqualViaTar(t *testing.T) {
+	newFS := func() fstest.MapFS {
+		return fstest.MapFS{
+			"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+			"dir/file": &fstest.MapFile{Mode: 0600, Data: []byte("42")},
+			"link":     &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("file")},
+			"hosts":    &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("/etc/hosts")},
+		}
+	}
+	if err := fstest.EqualViaTar(newFS(), newFS()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scenario string
+		change   func(fstest.MapFS)
+		kind     fstest.Kind
+	}{
+		{
+			scenario: "content",
+			change:   func(fsys fstest.MapFS) { fsys["file"].Data = []byte("Hello Tar!!!") },
+			kind:     fstest.ContentChanged,
+		},
+		{
+			scenario: "mode",
+			change:   func(fsys fstest.MapFS) { fsys["dir/file"].Mode = 0644 },
+			kind:     fstest.ModeChanged,
+		},
+		{
+			scenario: "size",
+			change:   func(fsys fstest.MapFS) { fsys["file"].Data = []byte("Hello") },
+			kind:     fstest.SizeChanged,
+		},
+		{
+			scenario: "link",
+			change:   func(fsys fstest.MapFS) { fsys["link"].Data = []byte("dir/file") },
+			kind:     fstest.SymlinkChanged,
+		},
+		{
+			scenario: "added",
+			change:   func(fsys fstest.MapFS) { fsys["other"] = &fstest.MapFile{Mode: 0644} },
+			kind:     fstest.Added,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			b := newFS()
+			test.change(b)
+
+			var equalErr *fstest.EqualError
+			if err := fstest.EqualViaTar(newFS(), b); !errors.As(err, &equalErr) {
+				t.Fatalf("expected an equal error, got %v", err)
+			}
+			if equalErr.Kind != test.kind {
+				t.Errorf("kind mismatch: want=%v got=%v", test.kind, equalErr.Kind)
+			}
+		})
+	}
+}
+
+func TestReadMapFSFromTar(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 42, time.UTC)
+	fsys := fstest.MapFS{
+		"file":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime},
+		"dir":      &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"dir/file": &fstest.MapFile{Mode: 0600 | fs.ModeSetuid, ModTime: modTime},
+		"dir/pipe": &fstest.MapFile{Mode: 0600 | fs.ModeNamedPipe, ModTime: modTime},
+		"link":     &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("file"), ModTime: modTime},
+		"hosts":    &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("/etc/hosts"), ModTime: modTime},
+	}
+
+	var b bytes.Buffer
+	if err := fstest.WriteTar(&b, fsys); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := fstest.ReadMapFSFromTar(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFSWith(fsys, loaded, fstest.WithModeMask(fs.ModePerm|fs.ModeSetuid)); err != nil {
+		t.Error(err)
+	}
+	if link := string(loaded["link"].Data); link != "file" {
+		t.Errorf("symbolic link target mismatch: %q", link)
+	}
+}
+
+func TestReadMapFSFromTarLinks(t *testing.T) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	headers := []*tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+		{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "file"},
+	}
+	for _, header := range headers {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			tw.Write([]byte("A"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := fstest.ReadMapFSFromTar(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fsys) != 2 || fsys["file"] == nil || fsys["hardlink"] != fsys["file"] {
+		t.Errorf("hard link not loaded as a shared entry: %v", fsys)
+	}
+
+	b.Reset()
+	tw = tar.NewWriter(&b)
+	tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.Close()
+	if _, err := fstest.ReadMapFSFromTar(&b); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected an error for the invalid path, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextDiff configures the comparison to report the differences of content
+// between text files as unified diffs of their lines, embedded in the error
+// messages, which makes the failures of golden file tests easier to read than
+// the offset of the first byte differing. The diffs are appended to the
+// messages of the differences of kind ContentChanged and SizeChanged.
+//
+// Files are considered text when their content is valid UTF-8 and does not
+// contain NUL bytes. The diffs are only produced for files of at most 64 KiB on
+// both sides, since computing them requires loading the files in memory and a
+// time quadratic in the number of lines; the differences of other files are
+// reported as usual. The files differing are read a second time to produce the
+// diff.
+func TextDiff() EqualOption {
+	return func(opts *equalOptions) { opts.textDiff = true }
+}
+
+const (
+	textDiffMaxSize = 64 * 1024
+	textDiffContext = 3
+)
+
+// textDiffError returns err, replaced by a difference embedding the diff of
+// the files at name if the comparison was configured with TextDiff and err
+// reports that their contents or sizes are different text. The kind and the
+// values of the difference are preserved.
+func (opts *equalOptions) textDiffError(source, target fs.FS, name string, err error) error {
+	var equalErr *EqualError
+	if !opts.textDiff || !errors.As(err, &equalErr) {
+		return err
+	}
+	if kind := equalErr.Kind; kind != ContentChanged && kind != SizeChanged {
+		return err
+	}
+	sourceFile, err1 := source.Open(name)
+	if err1 != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	targetFile, err2 := target.Open(name)
+	if err2 != nil {
+		return err
+	}
+	defer targetFile.Close()
+	sourceData, targetData, _, _, err3 := opts.contents(sourceFile, targetFile, false)
+	if err3 != nil {
+		return err
+	}
+	sourceText, ok1 := readText(sourceData)
+	targetText, ok2 := readText(targetData)
+	if !ok1 || !ok2 {
+		return err
+	}
+	diff := unifiedDiff(name, splitLines(sourceText), splitLines(targetText))
+	return equalError(name, equalErr.annotate(":\n"+diff))
+}
+
+// readText returns the content read from r, or false if it cannot be read, is
+// not text, or is too large to be diffed.
+func readText(r io.Reader) (string, bool) {
+	b, err := io.ReadAll(io.LimitReader(r, textDiffMaxSize+1))
+	if err != nil || len(b) > textDiffMaxSize || !isUTF8Text(b) {
+		return "", false
+	}
+	return string(b), true
+}
+
+func isUTF8Text(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// splitLines splits s after each line feed, the last line does not end with
+// a line feed if s does not.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type diffLine struct {
+	op   byte // ' ', '-', or '+'
+	text string
+}
+
+// diffLines returns the edit script transforming a into b, computed from the
+// longest common subsequence of their lines.
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix []diffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffLine{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append(suffix, diffLine{' ', a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	// lcs[i*(m+1)+j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	n, m := len(a), len(b)
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j]
+			default:
+				lcs[i*(m+1)+j] = lcs[i*(m+1)+j+1]
+			}
+		}
+	}
+
+	lines := prefix
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case j == m || (i < n && lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for k := len(suffix) - 1; k >= 0; k-- {
+		lines = append(lines, suffix[k])
+	}
+	return lines
+}
+
+// unifiedDiff formats the differences between the lines of a and b in the
+// unified format, with three lines of context around the changes.
+func unifiedDiff(name string, a, b []string) string {
+	lines := diffLines(a, b)
+	// Line numbers of a and b before each line of the edit script.
+	aLines := make([]int, len(lines)+1)
+	bLines := make([]int, len(lines)+1)
+	for k, line := range lines {
+		aLines[k+1], bLines[k+1] = aLines[k], bLines[k]
+		if line.op != '+' {
+			aLines[k+1]++
+		}
+		if line.op != '-' {
+			bLines[k+1]++
+		}
+	}
+
+	var s strings.Builder
+	fmt.Fprintf(&s, "--- a/%s\n+++ b/%s\n", name, name)
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+		// Extend the hunk while the next change is close enough for their
+		// contexts to overlap.
+		start, end := k-textDiffContext, k
+		if start < 0 {
+			start = 0
+		}
+		for next := end; next < len(lines) && next-end <= 2*textDiffContext; next++ {
+			if lines[next].op != ' ' {
+				end = next + 1
+			}
+		}
+		k, end = end, end+textDiffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+		fmt.Fprintf(&s, "@@ -%s +%s @@\n",
+			hunkRange(aLines[start], aLines[end]-aLines[start]),
+			hunkRange(bLines[start], bLines[end]-bLines[start]))
+		for _, line := range lines[start:end] {
+			s.WriteByte(line.op)
+			s.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				s.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return s.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,88 @@
+package fstest_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestTextDiff(t *testing.T) {
+	lines := func(n int, changes map[int]string) string {
+		var s strings.Builder
+		for i := 1; i <= n; i++ {
+			if line, ok := changes[i]; ok {
+				s.WriteString(line)
+			} else {
+				s.WriteString("line " + string(rune('a'+i-1)) + "\n")
+			}
+		}
+		return s.String()
+	}
+
+	tests := []struct {
+		scenario string
+		source   string
+		target   string
+		diff     string
+	}{
+		{
+			scenario: "one line changed",
+			source:   lines(10, nil),
+			target:   lines(10, map[int]string{5: "line E\n"}),
+			diff: `--- a/file
++++ b/file
+@@ -2,7 +2,7 @@
+ line b
+ line c
+ line d
+-line e
++line E
+ line f
+ line g
+ line h
+`,
+		},
+		{
+			scenario: "distant changes",
+			source:   lines(20, nil),
+			target:   lines(20, map[int]string{1: "", 20: "line t\nline u"}),
+			diff: `--- a/file
++++ b/file
+@@ -1,4 +1,3 @@
+-line a
+ line b
+ line c
+ line d
+@@ -18,3 +17,4 @@
+ line r
+ line s
+ line t
++line u
+\ No newline at end of file
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			source := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte(test.source)}}
+			target := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte(test.target)}}
+			err := fstest.EqualFSWith(source, target, fstest.TextDiff(), fstest.IgnoreModTime())
+			if !errors.Is(err, fstest.ErrContentMismatch) && !errors.Is(err, fstest.ErrSizeMismatch) {
+				t.Fatalf("expected a content mismatch, got %v", err)
+			}
+			if !strings.HasSuffix(err.Error(), test.diff) {
+				t.Errorf("unexpected diff:\n%s\nwant:\n%s", err, test.diff)
+			}
+		})
+	}
+
+	source := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("\x00\x01\x02")}}
+	target := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("\x00\x01\x03")}}
+	err := fstest.EqualFSWith(source, target, fstest.TextDiff())
+	if !errors.Is(err, fstest.ErrContentMismatch) || !strings.Contains(err.Error(), "offset 2") {
+		t.Errorf("expected binary files to report the offset of the difference, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package fstest
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// FixedTimeFS returns a file system which reports t as the modification,
+// access, and change time of every file of fsys.
+//
+// Wrapping both sides of a comparison with FixedTimeFS neutralizes the
+// timestamps of live file systems when comparing them against golden data.
+// The types, modes, sizes, and contents of files are left unchanged. On Linux
+// and Darwin, the Sys method of file information returns a *syscall.Stat_t
+// carrying the altered times, which can be read with the fsinfo package; it
+// returns nil on other platforms so the original times cannot be recovered
+// from system-specific data.
+func FixedTimeFS(fsys fs.FS, t time.Time) fs.FS {
+	return &timeFS{fsys, func(time.Time) time.Time { return t }}
+}
+
+// TruncateTimeFS returns a file system which rounds the modification, access,
+// and change time of every file of fsys down to a multiple of resolution,
+// simulating file systems with coarse timestamp granularity. The times are
+// left unchanged when resolution is less than or equal to zero.
+//
+// The names, sizes, modes, and contents of files are left unchanged. Like with
+// FixedTimeFS, the system-specific data returned by the Sys method of file
+// information carries the truncated times.
+func TruncateTimeFS(fsys fs.FS, resolution time.Duration) fs.FS {
+	return &timeFS{fsys, func(t time.Time) time.Time { return t.Truncate(resolution) }}
+}
+
+type timeFS struct {
+	base fs.FS
+	time func(time.Time) time.Time
+}
+
+func (fsys *timeFS) Open(name string) (fs.File, error) {
+	f, err := fsys.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &timeFile{f, name, fsys}, nil
+}
+
+func (fsys *timeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(fsys.base, name)
+	return fsys.entries(entries), err
+}
+
+func (fsys *timeFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys.base, name)
+}
+
+func (fsys *timeFS) ReadLink(name string) (string, error) {
+	return readLink(fsys.base, name)
+}
+
+func (fsys *timeFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(fsys.base, name)
+	if err != nil {
+		return nil, err
+	}
+	return &timeInfo{info, fsys}, nil
+}
+
+func (fsys *timeFS) entries(entries []fs.DirEntry) []fs.DirEntry {
+	// The entries are modified, they must not be shared with the base file
+	// system.
+	entries = append([]fs.DirEntry(nil), entries...)
+	for i, entry := range entries {
+		entries[i] = &timeEntry{entry, fsys}
+	}
+	return entries
+}
+
+var (
+	_ fs.ReadDirFS      = (*timeFS)(nil)
+	_ fs.ReadFileFS     = (*timeFS)(nil)
+	_ fs.StatFS         = (*timeFS)(nil)
+	_ fslink.ReadLinkFS = (*timeFS)(nil)
+)
+
+type timeFile struct {
+	fs.File
+	name string
+	fsys *timeFS
+}
+
+func (f *timeFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &timeInfo{info, f.fsys}, nil
+}
+
+func (f *timeFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	entries, err := d.ReadDir(n)
+	return f.fsys.entries(entries), err
+}
+
+type timeEntry struct {
+	fs.DirEntry
+	fsys *timeFS
+}
+
+func (e *timeEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return &timeInfo{info, e.fsys}, nil
+}
+
+type timeInfo struct {
+	fs.FileInfo
+	fsys *timeFS
+}
+
+func (info *timeInfo) ModTime() time.Time {
+	return info.fsys.time(info.FileInfo.ModTime())
+}
+
+func (info *timeInfo) AccessTime() time.Time {
+	return info.fsys.time(accessTime(info.FileInfo))
+}
+
+func (info *timeInfo) ChangeTime() time.Time {
+	return info.fsys.time(changeTime(info.FileInfo))
+}
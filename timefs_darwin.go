@@ -0,0 +1,19 @@
+package fstest
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/stealthrocket/fsinfo"
+)
+
+func setStatMode(stat *syscall.Stat_t, mode fs.FileMode) {
+	stat.Mode = uint16(fsinfo.FileMode(mode))
+}
+
+func setStatTimes(stat *syscall.Stat_t, mtime, atime, ctime time.Time) {
+	stat.Mtimespec = timespec(mtime)
+	stat.Atimespec = timespec(atime)
+	stat.Ctimespec = timespec(ctime)
+}
@@ -0,0 +1,19 @@
+package fstest
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/stealthrocket/fsinfo"
+)
+
+func setStatMode(stat *syscall.Stat_t, mode fs.FileMode) {
+	stat.Mode = fsinfo.FileMode(mode)
+}
+
+func setStatTimes(stat *syscall.Stat_t, mtime, atime, ctime time.Time) {
+	stat.Mtim = timespec(mtime)
+	stat.Atim = timespec(atime)
+	stat.Ctim = timespec(ctime)
+}
@@ -0,0 +1,5 @@
+//go:build !darwin && !linux
+
+package fstest
+
+func (info *timeInfo) Sys() any { return nil }
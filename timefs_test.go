@@ -0,0 +1,174 @@
+package fstest_test
+
+import (
+	"io/fs"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fsinfo"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestFixedTimeFS(t *testing.T) {
+	a := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: time.Unix(1, 0)},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: time.Unix(2, 0)},
+	}
+
+	b := fstest.MapFS{
+		"dir":      &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: time.Unix(3, 0)},
+		"dir/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: time.Unix(4, 0)},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected modification times mismatch")
+	}
+
+	now := time.Now()
+	fixedA := fstest.FixedTimeFS(a, now)
+	fixedB := fstest.FixedTimeFS(b, now)
+
+	if err := fstest.EqualFS(fixedA, fixedB); err != nil {
+		t.Error(err)
+	}
+
+	info, err := fs.Stat(fixedA, "dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(now) {
+		t.Errorf("modification time mismatch: want=%v got=%v", now, info.ModTime())
+	}
+	if info.Mode() != 0644 || info.Size() != 12 {
+		t.Errorf("file information mismatch: mode=%s size=%d", info.Mode(), info.Size())
+	}
+
+	data, err := fs.ReadFile(fixedA, "dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Hello World!" {
+		t.Errorf("file content mismatch: %q", data)
+	}
+
+	if err := fstest.TestFS(fixedA, "dir/file"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFixedTimeFSInfo(t *testing.T) {
+	if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+		t.Skip("system-specific file information is not supported on " + runtime.GOOS)
+	}
+	now := time.Unix(1700000000, 123456789)
+	fsys := fstest.FixedTimeFS(fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: time.Unix(1, 0)},
+	}, now)
+
+	info, err := fs.Stat(fsys, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for what, got := range map[string]time.Time{
+		"modification": fsinfo.ModTime(info),
+		"access":       fsinfo.AccessTime(info),
+		"change":       fsinfo.ChangeTime(info),
+	} {
+		if !got.Equal(now) {
+			t.Errorf("%s time mismatch: want=%v got=%v", what, now, got)
+		}
+	}
+	if mode := fsinfo.Mode(info); mode != fsinfo.FileMode(0644) {
+		t.Errorf("file mode mismatch: want=%o got=%o", fsinfo.FileMode(0644), mode)
+	}
+}
+
+// sharedDirFS returns the same slice from every call to ReadDir.
+type sharedDirFS struct {
+	fstest.MapFS
+	entries []fs.DirEntry
+}
+
+func (fsys *sharedDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fsys.entries, nil
+}
+
+func TestFixedTimeFSSharedEntries(t *testing.T) {
+	base := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, ModTime: time.Unix(1, 0)},
+	}
+	entries, err := fs.ReadDir(base, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := &sharedDirFS{base, entries}
+	entry := entries[0]
+
+	if _, err := fs.ReadDir(fstest.FixedTimeFS(shared, time.Unix(2, 0)), "."); err != nil {
+		t.Fatal(err)
+	}
+	if shared.entries[0] != entry {
+		t.Error("the directory entries of the base file system were modified")
+	}
+}
+
+func TestTruncateTimeFS(t *testing.T) {
+	modTime := time.Date(2023, 6, 1, 12, 30, 45, 123456789, time.UTC)
+	a := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime},
+	}
+	b := fstest.MapFS{
+		"file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime.Truncate(time.Second)},
+	}
+
+	if err := fstest.EqualFS(a, b); err == nil {
+		t.Error("expected modification times mismatch")
+	}
+	if err := fstest.EqualFS(fstest.TruncateTimeFS(a, time.Second), b); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.EqualFSWith(a, b, fstest.ModTimeTolerance(time.Second)); err != nil {
+		t.Error(err)
+	}
+
+	fsys := fstest.TruncateTimeFS(a, 2*time.Second)
+	info, err := fs.Stat(fsys, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 6, 1, 12, 30, 44, 0, time.UTC); !info.ModTime().Equal(want) {
+		t.Errorf("modification time mismatch: want=%v got=%v", want, info.ModTime())
+	}
+	if info.Name() != "file" || info.Size() != 12 || info.Mode() != 0644 {
+		t.Errorf("file information changed: %v %d %v", info.Name(), info.Size(), info.Mode())
+	}
+	// The information of directory entries and opened files is truncated
+	// consistently with Stat.
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("unexpected entries: %v (%v)", entries, err)
+	}
+	entryInfo, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entryInfo.ModTime().Equal(info.ModTime()) {
+		t.Errorf("directory entry time mismatch: want=%v got=%v", info.ModTime(), entryInfo.ModTime())
+	}
+	f, err := fsys.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileInfo.ModTime().Equal(info.ModTime()) {
+		t.Errorf("opened file time mismatch: want=%v got=%v", info.ModTime(), fileInfo.ModTime())
+	}
+	if err := fstest.EqualFS(fstest.TruncateTimeFS(a, 0), a); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build darwin || linux
+
+package fstest
+
+import (
+	"syscall"
+	"time"
+)
+
+// Sys returns a copy of the system-specific data of the underlying file
+// information with the altered times, so they are visible to the functions of
+// fsinfo. When the underlying file system does not expose a *syscall.Stat_t,
+// one is synthesized from the file information.
+func (info *timeInfo) Sys() any {
+	stat := new(syscall.Stat_t)
+	if sys, ok := info.FileInfo.Sys().(*syscall.Stat_t); ok && sys != nil {
+		*stat = *sys
+	} else {
+		setStatMode(stat, info.Mode())
+		stat.Size = info.Size()
+		if sys, ok := info.FileInfo.Sys().(*MapFileSys); ok && sys != nil {
+			stat.Ino = sys.Ino
+			stat.Uid = uint32(sys.Uid)
+			stat.Gid = uint32(sys.Gid)
+		}
+	}
+	setStatTimes(stat, info.ModTime(), info.AccessTime(), info.ChangeTime())
+	return stat
+}
+
+func timespec(t time.Time) syscall.Timespec {
+	return syscall.Timespec{Sec: t.Unix(), Nsec: int64(t.Nanosecond())}
+}
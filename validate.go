@@ -0,0 +1,150 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	errInvalidPath = errors.New("invalid path")
+	errDuplicate   = errors.New("duplicate entry")
+	errNilFile     = errors.New("missing file information")
+	// errNotDir reports that a path names something else than a directory,
+	// and errParentNotDir that one of the parents of a path does.
+	errNotDir       = errors.New("not a directory")
+	errParentNotDir = errors.New("parent is not a directory")
+)
+
+// PrepareMapFS returns a copy of fsys which is ready to be passed to TestFS.
+//
+// The keys of the returned map are cleaned (leading slashes, "./" prefixes and
+// trailing slashes are removed), and parent directories which were implicit in
+// fsys are added as explicit entries with mode 0755. The standard TestFS
+// expects the information of a directory to be consistent whether it was
+// obtained by listing its parent or by opening it, which synthesized
+// directories do not guarantee.
+//
+// The function returns an error listing all the problems found if fsys cannot
+// be represented as a valid file system, for example when two keys refer to
+// the same path once cleaned, or when a regular file is the parent of another
+// entry.
+func PrepareMapFS(fsys MapFS) (MapFS, error) {
+	prepared, err := normalizeMapFS("prepare", fsys)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMapFS("prepare", prepared); err != nil {
+		return nil, err
+	}
+	for name := range prepared {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if prepared[dir] != nil {
+				break
+			}
+			prepared[dir] = &MapFile{Mode: fs.ModeDir | 0755}
+		}
+	}
+	return prepared, nil
+}
+
+// NormalizeMapFS returns a copy of fsys with cleaned keys: leading slashes,
+// "./" prefixes, trailing slashes, and other redundant elements are removed, so
+// that keys like "/dir/file" or "./dir/" refer to the entries that they were
+// meant to. The entries are shared with fsys.
+//
+// The function returns an error listing the keys which cannot be cleaned into
+// valid paths (e.g. "../file" or the empty string), and the keys which refer to
+// the same path once cleaned.
+func NormalizeMapFS(fsys MapFS) (MapFS, error) {
+	return normalizeMapFS("normalize", fsys)
+}
+
+func normalizeMapFS(op string, fsys MapFS) (MapFS, error) {
+	normalized := make(MapFS, len(fsys))
+	sources := make(map[string]string, len(fsys))
+	var errs []error
+
+	for _, key := range sortedKeys(fsys) {
+		name, ok := normalizePath(key)
+		if !ok {
+			errs = append(errs, mapfsError(op, key, errInvalidPath))
+			continue
+		}
+		if prev, exists := sources[name]; exists {
+			errs = append(errs, mapfsError(op, key, fmt.Errorf("%w of %q", errDuplicate, prev)))
+			continue
+		}
+		sources[name] = key
+		normalized[name] = fsys[key]
+	}
+
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return normalized, nil
+}
+
+// ValidateMapFS checks that fsys represents a valid file system, returning an
+// error listing all the problems found, or nil if there are none.
+//
+// The keys of fsys must be valid paths according to fs.ValidPath, which
+// excludes the keys with leading slashes, "./" prefixes, or trailing slashes:
+// such entries are silently ignored by the methods of MapFS. The errors
+// reporting invalid keys mention the cleaned path when there is one, which
+// NormalizeMapFS can be used to apply. The function also reports the keys
+// with nil values, and the entries whose parent is not a directory.
+func ValidateMapFS(fsys MapFS) error {
+	return validateMapFS("validate", fsys)
+}
+
+func validateMapFS(op string, fsys MapFS) error {
+	var errs []error
+
+	for _, name := range sortedKeys(fsys) {
+		if !fs.ValidPath(name) {
+			err := errInvalidPath
+			if cleaned, ok := normalizePath(name); ok {
+				err = fmt.Errorf("%w, use %q", errInvalidPath, cleaned)
+			}
+			errs = append(errs, mapfsError(op, name, err))
+			continue
+		}
+		if fsys[name] == nil {
+			errs = append(errs, mapfsError(op, name, errNilFile))
+			continue
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if parent := fsys[dir]; parent != nil && !parent.Mode.IsDir() {
+				errs = append(errs, mapfsError(op, name, fmt.Errorf("%w: %s", errParentNotDir, dir)))
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func normalizePath(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	name = path.Clean(strings.TrimLeft(name, "/"))
+	return name, fs.ValidPath(name)
+}
+
+func mapfsError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func sortedKeys(fsys MapFS) []string {
+	keys := make([]string, 0, len(fsys))
+	for key := range fsys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
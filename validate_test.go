@@ -0,0 +1,104 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestPrepareMapFS(t *testing.T) {
+	fsys, err := fstest.PrepareMapFS(fstest.MapFS{
+		"/a/b/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"./a/c/":    &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "a/b", "a/b/file", "a/c"} {
+		if fsys[name] == nil {
+			t.Errorf("%s: missing entry", name)
+		}
+	}
+	if err := fstest.TestFS(fsys, "a/b/file", "a/c"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrepareMapFSErrors(t *testing.T) {
+	_, err := fstest.PrepareMapFS(fstest.MapFS{
+		"file":      &fstest.MapFile{Mode: 0644},
+		"./file":    &fstest.MapFile{Mode: 0644},
+		"../escape": &fstest.MapFile{Mode: 0644},
+	})
+	if err == nil {
+		t.Fatal("expected an error for duplicate and invalid entries")
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a path error, got %v", err)
+	}
+
+	_, err = fstest.PrepareMapFS(fstest.MapFS{
+		"file":       &fstest.MapFile{Mode: 0644},
+		"file/child": &fstest.MapFile{Mode: 0644},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a regular file with children")
+	}
+}
+
+func TestValidateMapFS(t *testing.T) {
+	tests := []struct {
+		scenario string
+		key      string
+		cleaned  string
+	}{
+		{"leading slash", "/dir/file", "dir/file"},
+		{"dot prefix", "./dir/file", "dir/file"},
+		{"trailing slash", "dir/file/", "dir/file"},
+		{"double slash", "dir//file", "dir/file"},
+		{"dot element", "dir/./file", "dir/file"},
+		{"dot-dot element", "dir/../dir/file", "dir/file"},
+	}
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"dir":    &fstest.MapFile{Mode: fs.ModeDir | 0755},
+				test.key: &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+			}
+			var pathErr *fs.PathError
+			err := fstest.ValidateMapFS(fsys)
+			if !errors.As(err, &pathErr) || pathErr.Path != test.key {
+				t.Fatalf("expected an error for %q, got %v", test.key, err)
+			}
+
+			normalized, err := fstest.NormalizeMapFS(fsys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized[test.cleaned] != fsys[test.key] {
+				t.Errorf("%q was not normalized to %q", test.key, test.cleaned)
+			}
+			if err := fstest.ValidateMapFS(normalized); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	for _, key := range []string{"", "../escape"} {
+		if _, err := fstest.NormalizeMapFS(fstest.MapFS{key: &fstest.MapFile{}}); err == nil {
+			t.Errorf("expected an error normalizing %q", key)
+		}
+	}
+
+	err := fstest.ValidateMapFS(fstest.MapFS{
+		"file":       &fstest.MapFile{Mode: 0644},
+		"file/child": &fstest.MapFile{Mode: 0644},
+		"nil":        nil,
+	})
+	if err == nil {
+		t.Error("expected an error for a regular file with children and a nil entry")
+	}
+}
@@ -0,0 +1,182 @@
+package fstest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// WhitespaceMode represents the normalizations applied to the content of text
+// files by WithWhitespaceInsensitive.
+type WhitespaceMode int
+
+const (
+	// TrailingWhitespace ignores the whitespace at the end of lines, and the
+	// blank lines at the end of files.
+	TrailingWhitespace WhitespaceMode = iota
+	// LineWhitespace ignores the whitespace at the beginning and end of lines,
+	// and collapses the runs of whitespace within lines to a single space.
+	// Line breaks are preserved.
+	LineWhitespace
+	// AllWhitespace ignores the whitespace at the beginning and end of files,
+	// and collapses all the runs of whitespace, including line breaks, to a
+	// single space.
+	AllWhitespace
+)
+
+// WithWhitespaceInsensitive configures the comparison to normalize the
+// whitespace of text files according to mode before comparing their content.
+//
+// Whitespace characters are spaces, tabs, carriage returns, vertical tabs, and
+// form feeds, as well as line feeds for AllWhitespace; lines are terminated by
+// line feeds. Files are considered text when http.DetectContentType detects a
+// "text/" content type from the first 512 bytes of the files on both sides;
+// the content of other files is compared unchanged. Since the normalization
+// changes the sizes of files, the sizes of text files are not compared.
+//
+// By default, text files which only differ in whitespace are equal; use the
+// ReportWhitespaceChanges option to report them as differences of kind
+// WhitespaceChanged.
+func WithWhitespaceInsensitive(mode WhitespaceMode) EqualOption {
+	return func(opts *equalOptions) { opts.whitespace = &mode }
+}
+
+// ReportWhitespaceChanges configures the comparison to report text files which
+// only differ in whitespace as differences of kind WhitespaceChanged, allowing
+// callers to tell formatting changes apart from changes of content, which are
+// still reported with the kind ContentChanged.
+//
+// The option has no effect unless WithWhitespaceInsensitive is also passed.
+// Since it requires comparing the files twice, text files which are equal once
+// normalized are read a second time.
+func ReportWhitespaceChanges() EqualOption {
+	return func(opts *equalOptions) { opts.reportWhitespace = true }
+}
+
+const sniffLen = 512
+
+// normalize returns readers producing the content of source and target with
+// the whitespace normalized, if both are text files.
+func (opts *equalOptions) normalize(source, target io.Reader) (io.Reader, io.Reader, bool) {
+	sourcePrefix, sourceErr := sniffPrefix(source)
+	targetPrefix, targetErr := sniffPrefix(target)
+	source = &prefixReader{sourcePrefix, sourceErr, source}
+	target = &prefixReader{targetPrefix, targetErr, target}
+	if !isText(sourcePrefix) || !isText(targetPrefix) {
+		return source, target, false
+	}
+	mode := *opts.whitespace
+	return newWhitespaceReader(source, mode), newWhitespaceReader(target, mode), true
+}
+
+func sniffPrefix(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, sniffLen)
+	n, err := readFull(r, prefix)
+	return prefix[:n], err
+}
+
+func isText(prefix []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(prefix), "text/")
+}
+
+// whitespaceReader normalizes the whitespace of the content of a reader.
+type whitespaceReader struct {
+	r    *bufio.Reader
+	mode WhitespaceMode
+	// Bytes ready to be returned by Read.
+	out []byte
+	// Whitespace seen but not yet known to be trailing or not. Only the
+	// TrailingWhitespace mode retains the actual characters (including line
+	// breaks), other modes replace runs of whitespace with a single space.
+	pending []byte
+	// Set at the beginning of lines (or files for AllWhitespace), where
+	// whitespace is dropped by LineWhitespace and AllWhitespace.
+	start bool
+	err   error
+}
+
+func newWhitespaceReader(r io.Reader, mode WhitespaceMode) *whitespaceReader {
+	return &whitespaceReader{r: bufio.NewReader(r), mode: mode, start: true}
+}
+
+func (r *whitespaceReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if len(r.out) != 0 {
+			c := copy(b[n:], r.out)
+			r.out = r.out[c:]
+			n += c
+			continue
+		}
+		if r.err != nil {
+			break
+		}
+		c, err := r.r.ReadByte()
+		if err != nil {
+			// Whitespace pending at the end of the content is trailing.
+			r.pending, r.err = nil, err
+			continue
+		}
+		r.next(c)
+	}
+	if n == 0 && len(b) != 0 {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+func (r *whitespaceReader) next(c byte) {
+	switch {
+	case c == '\n' && r.mode == TrailingWhitespace:
+		// Line breaks remain pending since they may be trailing at the end
+		// of the file, but the whitespace preceding them is dropped.
+		r.pending = append(bytes.TrimRight(r.pending, whitespace), c)
+	case c == '\n' && r.mode == LineWhitespace:
+		r.pending = r.pending[:0]
+		r.out = append(r.out[:0], c)
+		r.start = true
+	case isWhitespace(c) || c == '\n':
+		switch {
+		case r.mode == TrailingWhitespace:
+			r.pending = append(r.pending, c)
+		case !r.start:
+			r.pending = append(r.pending[:0], ' ')
+		}
+	default:
+		r.out = append(append(r.out[:0], r.pending...), c)
+		r.pending = r.pending[:0]
+		r.start = false
+	}
+}
+
+const whitespace = " \t\r\v\f"
+
+func isWhitespace(c byte) bool {
+	return strings.IndexByte(whitespace, c) >= 0
+}
+
+// equalWhitespace compares the raw contents of files which were found equal
+// once normalized, to report the files only differing in whitespace.
+func equalWhitespace(source, target fs.FS, name string, buf []byte, opts *equalOptions) error {
+	sourceFile, err := source.Open(name)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	targetFile, err := target.Open(name)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+	sourceData, targetData, _, _, err := opts.contents(sourceFile, targetFile, false)
+	if err != nil {
+		return equalError(name, err)
+	}
+	if err := equalData(sourceData, targetData, buf, opts); err != nil {
+		return equalErrorf(name, WhitespaceChanged, "file content only differs in whitespace")
+	}
+	return nil
+}
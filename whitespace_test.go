@@ -0,0 +1,82 @@
+package fstest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithWhitespaceInsensitive(t *testing.T) {
+	const text = "key: value\n\tnested: 42\n"
+
+	tests := []struct {
+		scenario string
+		data     string
+		equal    []fstest.WhitespaceMode
+	}{
+		{
+			scenario: "trailing spaces",
+			data:     "key: value  \n\tnested: 42\t\n\n",
+			equal:    []fstest.WhitespaceMode{fstest.TrailingWhitespace, fstest.AllWhitespace},
+		},
+		{
+			scenario: "carriage returns",
+			data:     "key: value\r\n\tnested: 42\r\n",
+			equal:    []fstest.WhitespaceMode{fstest.TrailingWhitespace, fstest.LineWhitespace, fstest.AllWhitespace},
+		},
+		{
+			scenario: "indentation",
+			data:     "key:   value\n    nested: 42\n",
+			equal:    []fstest.WhitespaceMode{fstest.LineWhitespace, fstest.AllWhitespace},
+		},
+		{
+			scenario: "line breaks",
+			data:     "key: value nested: 42",
+			equal:    []fstest.WhitespaceMode{fstest.AllWhitespace},
+		},
+		{
+			scenario: "content",
+			data:     "key: value\n\tnested: 24\n",
+		},
+	}
+
+	a := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte(text)}}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			b := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte(test.data)}}
+
+			for _, mode := range []fstest.WhitespaceMode{fstest.TrailingWhitespace, fstest.LineWhitespace, fstest.AllWhitespace} {
+				equal := false
+				for _, m := range test.equal {
+					equal = equal || m == mode
+				}
+				err := fstest.EqualFSWith(a, b, fstest.WithWhitespaceInsensitive(mode))
+				if equal && err != nil {
+					t.Errorf("mode %d: %v", mode, err)
+				}
+				if !equal && !errors.Is(err, fstest.ErrContentMismatch) {
+					t.Errorf("mode %d: expected a content mismatch, got %v", mode, err)
+				}
+
+				err = fstest.EqualFSWith(a, b, fstest.WithWhitespaceInsensitive(mode), fstest.ReportWhitespaceChanges())
+				if equal && !errors.Is(err, fstest.ErrWhitespaceMismatch) {
+					t.Errorf("mode %d: expected a whitespace mismatch, got %v", mode, err)
+				}
+				if !equal && !errors.Is(err, fstest.ErrContentMismatch) {
+					t.Errorf("mode %d: expected a content mismatch, got %v", mode, err)
+				}
+			}
+		})
+	}
+
+	binary := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("\x00\x01 \x02")}}
+	spaced := fstest.MapFS{"file": &fstest.MapFile{Mode: 0644, Data: []byte("\x00\x01  \x02")}}
+	if err := fstest.EqualFSWith(binary, spaced, fstest.WithWhitespaceInsensitive(fstest.AllWhitespace)); err == nil {
+		t.Error("expected the whitespace of binary files to be compared")
+	}
+	if err := fstest.EqualFSWith(a, a, fstest.WithWhitespaceInsensitive(fstest.AllWhitespace), fstest.ReportWhitespaceChanges()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,149 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// WritableFS is an interface implemented by file systems supporting
+// modifications, such as MapFS.
+type WritableFS interface {
+	fs.FS
+	Mkdir(name string, perm fs.FileMode) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// Touch sets the access and modification times of the given paths of fsys to
+// t, or of all the files of fsys (except its root) if no paths are given.
+//
+// All the paths are touched even if errors occur, the returned error lists the
+// paths that could not be touched.
+func Touch(fsys WritableFS, t time.Time, paths ...string) error {
+	if len(paths) == 0 {
+		err := fs.WalkDir(fsys, ".", func(name string, _ fs.DirEntry, err error) error {
+			if err == nil && name != "." {
+				paths = append(paths, name)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, name := range paths {
+		if err := fsys.Chtimes(name, t, t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// symlinkFS is implemented by the writable file systems supporting the creation
+// of symbolic links, such as MapFS.
+type symlinkFS interface {
+	Symlink(oldname, newname string) error
+}
+
+// chmodFS is implemented by the writable file systems supporting changes of
+// permissions, such as MapFS.
+type chmodFS interface {
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// CopyFS copies the files of src into dst, recreating the directories, regular
+// files, and symbolic links of src with their permission bits and modification
+// times. Directories which already exist in dst are reused, and regular files
+// which already exist are overwritten.
+//
+// Symbolic links are created with the Symlink method of dst; the function
+// returns an error wrapping ErrSymlinkUnsupported if src contains symbolic
+// links and dst does not have such a method. The modification times of
+// symbolic links, which Chtimes would apply to the files they refer to, and
+// of the root directory are not copied. Other types of files cannot be created
+// through WritableFS, the function returns an error if src contains any.
+//
+// When dst has a Chmod method, like MapFS, directories are created with all the
+// owner permissions and their permission bits are restored after their content
+// was copied, so directories lacking the write permission can be copied.
+// Otherwise, copying them fails when dst enforces permissions, since the files
+// they contain cannot be created.
+func CopyFS(dst WritableFS, src fs.FS) error {
+	type dirTime struct {
+		name    string
+		perm    fs.FileMode
+		chmod   bool
+		modTime time.Time
+	}
+	var dirs []dirTime
+	c, canChmod := dst.(chmodFS)
+	buf := make([]byte, equalFSBufSize)
+
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch typ := d.Type(); typ {
+		case fs.ModeDir:
+			perm := info.Mode().Perm()
+			chmod := canChmod && perm&0700 != 0700
+			if chmod {
+				perm |= 0700
+			}
+			if err := dst.Mkdir(name, perm); err != nil {
+				if dir, statErr := fs.Stat(dst, name); statErr != nil || !dir.IsDir() {
+					return err
+				}
+				chmod = false
+			}
+			dirs = append(dirs, dirTime{name, info.Mode().Perm(), chmod, info.ModTime()})
+			return nil
+		case fs.ModeSymlink:
+			target, err := readLink(src, name)
+			if err != nil {
+				return err
+			}
+			s, ok := dst.(symlinkFS)
+			if !ok {
+				return &fs.PathError{Op: "symlink", Path: name, Err: ErrSymlinkUnsupported}
+			}
+			return s.Symlink(target, name)
+		case 0:
+			data, err := readFile(src, name, info.Size(), buf)
+			if err != nil {
+				return err
+			}
+			if err := dst.WriteFile(name, data, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return dst.Chtimes(name, info.ModTime(), info.ModTime())
+		default:
+			return &fs.PathError{Op: "copy", Path: name, Err: fmt.Errorf("unsupported file type: %v", typ)}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// The permissions and times of directories are set last, starting from the
+	// deepest ones, since creating files may update the modification time of
+	// directories.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if dirs[i].chmod {
+			if err := c.Chmod(dirs[i].name, dirs[i].perm); err != nil {
+				return err
+			}
+		}
+		if err := dst.Chtimes(dirs[i].name, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
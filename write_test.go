@@ -0,0 +1,95 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestTouch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir/a": &fstest.MapFile{Mode: 0644, Data: []byte("A")},
+		"dir/b": &fstest.MapFile{Mode: 0644, Data: []byte("B")},
+		"c":     &fstest.MapFile{Mode: 0644, Data: []byte("C")},
+	}
+
+	stale := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := fstest.Touch(fsys, stale); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir", "dir/a", "dir/b", "c"} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(stale) {
+			t.Errorf("%s: modification time mismatch: want=%v got=%v", name, stale, info.ModTime())
+		}
+	}
+
+	// Touching the directory synthesized by the map does not prevent the
+	// creation of files in it.
+	if err := fsys.WriteFile("dir/c", []byte("C"), 0644); err != nil {
+		t.Error(err)
+	}
+
+	fresh := stale.Add(time.Hour)
+	err := fstest.Touch(fsys, fresh, "dir/a", "missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected an error for the missing file, got %v", err)
+	}
+	if !fsys["dir/a"].ModTime.Equal(fresh) {
+		t.Errorf("dir/a: modification time was not updated")
+	}
+	if !fsys["dir/b"].ModTime.Equal(stale) {
+		t.Errorf("dir/b: modification time was updated")
+	}
+}
+
+func TestCopyFS(t *testing.T) {
+	src := fstest.MapFS{
+		"bin":        &fstest.MapFile{Mode: 0700 | fs.ModeDir, ModTime: time.Unix(1, 0)},
+		"bin/tool":   &fstest.MapFile{Mode: 0755, Data: []byte("#!/bin/sh"), ModTime: time.Unix(2, 0)},
+		"data":       &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: time.Unix(3, 0)},
+		"data/empty": &fstest.MapFile{Mode: 0755 | fs.ModeDir, ModTime: time.Unix(4, 0)},
+		"data/file":  &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!"), ModTime: time.Unix(5, 0)},
+		"data/link":  &fstest.MapFile{Mode: 0777 | fs.ModeSymlink, Data: []byte("file")},
+	}
+
+	dst := fstest.MapFS{}
+	if err := fstest.CopyFS(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(src, dst); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.CheckNoAliasing(src, dst); err != nil {
+		t.Error(err)
+	}
+
+	err := fstest.CopyFS(fstest.NoSymlinkFS(fstest.MapFS{}).(fstest.WritableFS), src)
+	if !errors.Is(err, fstest.ErrSymlinkUnsupported) {
+		t.Errorf("expected ErrSymlinkUnsupported, got %v", err)
+	}
+
+	// Directories synthesized by the map, and directories lacking the write
+	// permission, are copied with their files.
+	src = fstest.MapFS{
+		"a/b/c/file": &fstest.MapFile{Mode: 0644, Data: []byte("nested"), ModTime: time.Unix(6, 0)},
+		"ro":         &fstest.MapFile{Mode: 0500 | fs.ModeDir, ModTime: time.Unix(7, 0)},
+		"ro/file":    &fstest.MapFile{Mode: 0400, Data: []byte("read-only"), ModTime: time.Unix(8, 0)},
+	}
+	dst = fstest.MapFS{}
+	if err := fstest.CopyFS(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.EqualFS(src, dst); err != nil {
+		t.Error(err)
+	}
+	if err := dst.WriteFile("ro/new", nil, 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected the permissions of ro to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// XattrFS is an interface implemented by file systems exposing the extended
+// attributes of files, such as MapFS.
+type XattrFS interface {
+	fs.FS
+	// ListXattr returns the names of the extended attributes of the named
+	// file.
+	ListXattr(name string) ([]string, error)
+	// GetXattr returns the value of the extended attribute attr of the named
+	// file.
+	GetXattr(name, attr string) ([]byte, error)
+}
+
+// CompareXattrs configures the comparison to compare the extended attributes
+// of files, reporting differences of kind XattrsChanged which list the names
+// of the attributes missing, added, or changed in the target.
+//
+// The attributes are only compared when both file systems implement XattrFS,
+// the check is skipped otherwise. Note that the file systems wrapped by the
+// FollowSymlinks and ImplicitDirs options do not implement XattrFS.
+func CompareXattrs() EqualOption {
+	return func(opts *equalOptions) { opts.compareXattrs = true }
+}
+
+func equalXattrs(source, target fs.FS, name string) error {
+	sourceXattrs, ok := source.(XattrFS)
+	if !ok {
+		return nil
+	}
+	targetXattrs, ok := target.(XattrFS)
+	if !ok {
+		return nil
+	}
+	sourceAttrs, err := readXattrs(sourceXattrs, name)
+	if err != nil {
+		return err
+	}
+	targetAttrs, err := readXattrs(targetXattrs, name)
+	if err != nil {
+		return err
+	}
+
+	var missing, extra, changed []string
+	for _, attr := range sortedAttrs(sourceAttrs) {
+		value, ok := targetAttrs[attr]
+		switch {
+		case !ok:
+			missing = append(missing, attr)
+		case !bytes.Equal(value, sourceAttrs[attr]):
+			changed = append(changed, attr)
+		}
+	}
+	for _, attr := range sortedAttrs(targetAttrs) {
+		if _, ok := sourceAttrs[attr]; !ok {
+			extra = append(extra, attr)
+		}
+	}
+
+	var msgs []string
+	if len(missing) != 0 {
+		msgs = append(msgs, fmt.Sprintf("missing in target: %v", missing))
+	}
+	if len(extra) != 0 {
+		msgs = append(msgs, fmt.Sprintf("extra in target: %v", extra))
+	}
+	if len(changed) != 0 {
+		msgs = append(msgs, fmt.Sprintf("changed: %v", changed))
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return equalErrorf(name, XattrsChanged, "extended attributes mismatch: %s", strings.Join(msgs, ", "))
+}
+
+func readXattrs(fsys XattrFS, name string) (map[string][]byte, error) {
+	attrs, err := fsys.ListXattr(name)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(attrs))
+	for _, attr := range attrs {
+		value, err := fsys.GetXattr(name, attr)
+		if err != nil {
+			return nil, err
+		}
+		values[attr] = value
+	}
+	return values, nil
+}
+
+func sortedAttrs(attrs map[string][]byte) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cloneXattrs(attrs map[string][]byte) map[string][]byte {
+	if attrs == nil {
+		return nil
+	}
+	clone := make(map[string][]byte, len(attrs))
+	for name, value := range attrs {
+		clone[name] = bytes.Clone(value)
+	}
+	return clone
+}
+
+var errNoXattr = errors.New("no such extended attribute")
+
+// ListXattr returns the sorted names of the extended attributes of the named
+// file, which are configured by the Xattrs field of MapFileSys.
+func (fsys MapFS) ListXattr(name string) ([]string, error) {
+	attrs, err := fsys.xattrs("listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	return sortedAttrs(attrs), nil
+}
+
+// GetXattr returns a copy of the value of the extended attribute attr of the
+// named file, or an error if the file has no such attribute.
+func (fsys MapFS) GetXattr(name, attr string) ([]byte, error) {
+	attrs, err := fsys.xattrs("getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := attrs[attr]
+	if !ok {
+		return nil, &fs.PathError{Op: "getxattr", Path: name, Err: errNoXattr}
+	}
+	return bytes.Clone(value), nil
+}
+
+func (fsys MapFS) xattrs(op, name string) (map[string][]byte, error) {
+	if _, err := fs.Stat(fsys, name); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: errors.Unwrap(err)}
+	}
+	mapfsMutex.RLock()
+	defer mapfsMutex.RUnlock()
+	if sys := fsys.sys(name); sys != nil {
+		return sys.Xattrs, nil
+	}
+	return nil, nil
+}
+
+var (
+	_ XattrFS = (MapFS)(nil)
+)
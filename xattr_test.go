@@ -0,0 +1,65 @@
+package fstest_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCompareXattrs(t *testing.T) {
+	xattrs := func(attrs map[string][]byte) *fstest.MapFileSys {
+		return &fstest.MapFileSys{Xattrs: attrs}
+	}
+	a := fstest.MapFS{
+		"dir": &fstest.MapFile{Mode: 0755 | fs.ModeDir, Sys: xattrs(map[string][]byte{
+			"user.dir": []byte("dir"),
+		})},
+		"dir/file": &fstest.MapFile{Mode: 0644, Sys: xattrs(map[string][]byte{
+			"user.a":           []byte("a"),
+			"user.b":           []byte("b"),
+			"security.selinux": []byte("system_u:object_r:etc_t:s0"),
+		})},
+	}
+	b := a.Clone()
+
+	if err := fstest.EqualFSWith(a, b, fstest.CompareXattrs()); err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := b.ListXattr("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"security.selinux", "user.a", "user.b"}; !reflect.DeepEqual(attrs, want) {
+		t.Errorf("attributes mismatch: want=%q got=%q", want, attrs)
+	}
+	if _, err := b.GetXattr("dir/file", "user.c"); err == nil {
+		t.Error("expected an error getting a missing attribute")
+	}
+	if _, err := b.ListXattr("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	sys := b["dir/file"].Sys.(*fstest.MapFileSys)
+	delete(sys.Xattrs, "user.a")
+	sys.Xattrs["user.b"] = []byte("B")
+	sys.Xattrs["user.c"] = []byte("c")
+
+	if err := fstest.EqualFS(a, b); err != nil {
+		t.Errorf("attributes compared without the option: %v", err)
+	}
+	err = fstest.EqualFSWith(a, b, fstest.CompareXattrs())
+	if !errors.Is(err, fstest.ErrXattrsMismatch) {
+		t.Fatalf("expected an xattrs mismatch, got %v", err)
+	}
+	const want = "equal dir/file: extended attributes mismatch: missing in target: [user.a], extra in target: [user.c], changed: [user.b]"
+	if err.Error() != want {
+		t.Errorf("error message mismatch:\nwant: %s\ngot:  %s", want, err)
+	}
+
+	if err := fstest.EqualFSWith(a, fstest.ReadOnlyFS(b), fstest.CompareXattrs()); err != nil {
+		t.Errorf("attributes compared with a file system not exposing them: %v", err)
+	}
+}
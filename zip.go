@@ -0,0 +1,62 @@
+package fstest
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ReadMapFSFromZip reads the zip archive of the given size from r and returns
+// a MapFS holding its entries.
+//
+// The modes of entries are obtained from the external attributes of the zip
+// headers; entries stored with the unix symbolic link mode are loaded as
+// symbolic links, their content being the link target. Modification times are
+// obtained from the Modified field of the headers. The directories which only
+// appear implicitly in the names of entries are added with the permissions
+// 0755 and no modification time. The function returns an error if the archive
+// contains names which are not valid paths.
+func ReadMapFSFromZip(r io.ReaderAt, size int64) (MapFS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	fsys := make(MapFS, len(zr.File))
+	for _, f := range zr.File {
+		name := path.Clean(strings.TrimPrefix(f.Name, "/"))
+		if !fs.ValidPath(name) {
+			return nil, &fs.PathError{Op: "unzip", Path: f.Name, Err: fs.ErrInvalid}
+		}
+		if name == "." {
+			continue
+		}
+		file := &MapFile{Mode: f.Mode(), ModTime: f.Modified}
+		if !file.Mode.IsDir() {
+			if file.Data, err = readZipFile(f); err != nil {
+				return nil, &fs.PathError{Op: "unzip", Path: name, Err: err}
+			}
+		}
+		fsys[name] = file
+	}
+	var implicit []string
+	for name := range fsys {
+		for dir := path.Dir(name); dir != "." && fsys[dir] == nil; dir = path.Dir(dir) {
+			implicit = append(implicit, dir)
+		}
+	}
+	for _, dir := range implicit {
+		fsys[dir] = &MapFile{Mode: fs.ModeDir | 0755}
+	}
+	return fsys, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
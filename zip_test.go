@@ -0,0 +1,62 @@
+package fstest_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fstest"
+)
+
+func TestReadMapFSFromZip(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []struct {
+		name string
+		mode fs.FileMode
+		data string
+	}{
+		{"dir/file.txt", 0644, "Hello World!"},
+		{"dir/sub/", fs.ModeDir | 0750, ""},
+		{"bin/run", 0755, "#!/bin/sh"},
+		{"link", fs.ModeSymlink | 0777, "dir/file.txt"},
+	}
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(entry.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := fstest.ReadMapFSFromZip(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := fstest.MapFS{
+		"dir":          &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"dir/file.txt": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!"), ModTime: modTime},
+		"dir/sub":      &fstest.MapFile{Mode: fs.ModeDir | 0750, ModTime: modTime},
+		"bin":          &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"bin/run":      &fstest.MapFile{Mode: 0755, Data: []byte("#!/bin/sh"), ModTime: modTime},
+		"link":         &fstest.MapFile{Mode: fs.ModeSymlink | 0777, Data: []byte("dir/file.txt"), ModTime: modTime},
+	}
+	if err := fstest.EqualFS(want, fsys); err != nil {
+		t.Error(err)
+	}
+	if added, removed, changed := want.Diff(fsys); len(added)+len(removed)+len(changed) != 0 {
+		t.Errorf("entries mismatch: added=%q removed=%q changed=%q", added, removed, changed)
+	}
+}